@@ -2,8 +2,14 @@ package classic
 
 import (
 	"context"
+	"fmt"
 	"log"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/cloudinit"
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+	"packer-plugin-ibmcloud/builder/ibmcloud/workspace"
+
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -35,9 +41,21 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	}
 
 	// Return the placeholder for the generated data that will become available to provisioners and post-processors.
-	// If the builder doesn't generate any data, just return an empty slice of string: []string{}
-	buildGeneratedData := []string{}
-	return buildGeneratedData, nil, nil
+	return generatedDataKeys, nil, nil
+}
+
+// generatedDataKeys are the build.* variables the steps publish through
+// packerbuilderdata: the instance details (stepCreateInstance), its addresses
+// (stepGrabPublicIP) and the captured image (stepCaptureImage).
+var generatedDataKeys = []string{
+	"InstanceID",
+	"InstanceName",
+	"Datacenter",
+	"PublicIP",
+	"PrivateIP",
+	"SourceImageID",
+	"SourceOSCode",
+	"ImageID",
 }
 
 // Run executes a SoftLayer Packer build and returns a packer.Artifact
@@ -47,6 +65,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 
 	// Create the client
 	client := SoftlayerClient{}.New(b.config.Username, b.config.APIKey)
+	ui = redact.UI(ui)
 
 	// Set up the state which is used to share state between the steps
 	state := new(multistep.BasicStateBag)
@@ -55,11 +74,39 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
-	// Set the value of the generated data that will become available to provisioners.
+	// The steps fill in the generated data that becomes available to provisioners.
 	// To share the data with post-processors, use the StateData in the artifact.
-	state.Put("generated_data", map[string]interface{}{
-		"GeneratedMockData": "mock-build-data",
+	state.Put("generated_data", map[string]interface{}{})
+
+	// Every resource the steps create is recorded in the ledger, so one a killed
+	// build leaves behind can be deleted by the cleanup subcommand.
+	resourceLedger, err := ledger.New(b.config.ResourceLedgerDir, &ledger.Ledger{
+		Builder: "classic",
+		BuildID: b.config.BuildID,
+		Region:  b.config.DatacenterName,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] Error creating the resource ledger: %s", err)
+	}
+	state.Put("ledger", resourceLedger)
+
+	// The key pair and any other file the steps generate are written to a
+	// private directory of the build, removed when it ends.
+	buildWorkspace, err := workspace.New(b.config.SSHKeyOutputDir, b.config.BuildID)
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] Error creating the build's working directory: %s", err)
+	}
+	defer buildWorkspace.Remove()
+	state.Put("workspace", buildWorkspace)
+
+	// Provisioning waits for cloud-init, or Cloudbase-Init, to run the user
+	// data when wait_for_cloud_init is set.
+	waitForCloudInit := &cloudinit.StepWait{
+		Enabled: b.config.WaitForCloudInit,
+		Timeout: b.config.CloudInitTimeout,
+		Windows: b.config.Comm.Type == "winrm",
+		Sudo:    b.config.Comm.Type == "ssh" && b.config.Comm.SSHUsername != "root",
+	}
 
 	// Build the steps
 	steps := []multistep.Step{}
@@ -74,6 +121,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 				WinRMConfig: winRMConfig,
 			},
 			new(stepWaitforInstance),
+			waitForCloudInit,
 			new(commonsteps.StepProvision),
 			new(stepCaptureImage),
 		}
@@ -90,6 +138,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 				Host:      sshCommHost,
 				SSHConfig: sshConfig,
 			},
+			waitForCloudInit,
 			new(commonsteps.StepProvision),
 			new(stepCaptureImage),
 		}
@@ -98,6 +147,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	// Create the runner which will run the steps we just build
 	b.runner = &multistep.BasicRunner{Steps: steps}
 	b.runner.Run(ctx, state)
+	resourceLedger.Finish(ui)
 
 	// If there was an error, return that
 	if rawErr, ok := state.GetOk("error"); ok {
@@ -12,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 )
 
@@ -153,13 +155,13 @@ func (s SoftlayerClient) generateRequestBody(params ...interface{}) (*bytes.Buff
 		Parameters: params,
 	}
 
-	log.Printf("SoftLayerRequest: %s", softlayerRequest)
 	body, err := json.Marshal(softlayerRequest)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Generated a request: %s", body)
+	// The body is not logged: it carries the instance's user data.
+	log.Printf("Generated a request of %d bytes", len(body))
 
 	return bytes.NewBuffer(body), nil
 }
@@ -173,44 +175,39 @@ func (s SoftlayerClient) hasErrors(body map[string]interface{}) error {
 }
 
 func (s SoftlayerClient) doRawHttpRequest(path string, requestType string, requestBody *bytes.Buffer) ([]byte, error) {
-	url := fmt.Sprintf("https://%s:%s@%s/%s", s.user, s.apiKey, SOFTLAYER_API_URL, path)
-	log.Printf("Sending new request to softlayer: %s", url)
+	// The credentials go in the Authorization header rather than the URL, so
+	// the URL can be logged.
+	url := fmt.Sprintf("https://%s/%s", SOFTLAYER_API_URL, path)
+	log.Printf("Sending new request to softlayer: %s %s", requestType, url)
 
 	// Create the request object
-	var lastResponse http.Response
+	var req *http.Request
+	var err error
 	switch requestType {
 	case "POST", "DELETE":
-		req, err := http.NewRequest(requestType, url, requestBody)
-
-		if err != nil {
-			return nil, err
-		}
-		resp, err := s.http.Do(req)
-
-		if err != nil {
-			return nil, err
-		} else {
-			lastResponse = *resp
-		}
+		req, err = http.NewRequest(requestType, url, requestBody)
 	case "GET":
-		resp, err := http.Get(url)
-
-		if err != nil {
-			return nil, err
-		} else {
-			lastResponse = *resp
-		}
+		req, err = http.NewRequest(requestType, url, nil)
 	default:
 		return nil, fmt.Errorf("[ERROR] Undefined request type '%s', only GET/POST/DELETE are available", requestType)
 	}
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(s.user, s.apiKey)
 
-	responseBody, err := ioutil.ReadAll(lastResponse.Body)
-	lastResponse.Body.Close()
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Received response from SoftLayer: %s", responseBody)
+	// The body is not logged: it can carry the instance's passwords.
+	log.Printf("Received response from SoftLayer: %s (%d bytes)", resp.Status, len(responseBody))
 	return responseBody, nil
 }
 
@@ -220,7 +217,6 @@ func (s SoftlayerClient) doHttpRequest(path string, requestType string, requestB
 		err := fmt.Errorf("[ERROR] Failed to get proper HTTP response from SoftLayer API. Error: %s", err)
 		return nil, err
 	}
-	log.Printf("ResponseBody: %s", responseBody)
 
 	var decodedResponse interface{}
 	err = json.Unmarshal(responseBody, &decodedResponse)
@@ -254,7 +250,6 @@ func (s SoftlayerClient) doModifiedHttpRequest(path string, requestType string,
 		err := fmt.Errorf("[Error] Failed to get proper HTTP response from SoftLayer API")
 		return nil, err
 	}
-	log.Printf("ResponseBody: %s", responseBody)
 
 	var decodedResponse interface{}
 	err = json.Unmarshal(responseBody, &decodedResponse)
@@ -403,6 +398,7 @@ func (s SoftlayerClient) GrabCredentials(instanceID string, state multistep.Stat
 		}
 		if fmt.Sprintf("%v", result["operatingSystem"]["passwords"]["password"]) != "" {
 			//ui.Say(fmt.Sprintf("Found password on attempt %v", i))
+			redact.Secrets(fmt.Sprintf("%v", result["operatingSystem"]["passwords"]["password"]))
 			return fmt.Sprintf("%v", result["operatingSystem"]["passwords"]["username"]), fmt.Sprintf("%v", result["operatingSystem"]["passwords"]["password"]), nil
 		}
 		time.Sleep(waitDuration)
@@ -464,6 +460,18 @@ func (s SoftlayerClient) getInstancePublicIp(instanceId string) (string, error)
 	return string(ipAddress), nil
 }
 
+func (s SoftlayerClient) getInstancePrivateIp(instanceId string) (string, error) {
+	response, err := s.doRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s/getPrimaryBackendIpAddress.json", instanceId), "GET", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var validIp = regexp.MustCompile(`[0-9]{1,4}\.[0-9]{1,4}\.[0-9]{1,4}\.[0-9]{1,4}`)
+	ipAddress := validIp.Find(response)
+
+	return string(ipAddress), nil
+}
+
 func (s SoftlayerClient) getBlockDevices(instanceId string) ([]interface{}, error) {
 	data, err := s.doHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s/getBlockDevices.json?objectMask=mask.diskImage.name", instanceId), "GET", nil)
 	if err != nil {
@@ -7,11 +7,16 @@ import (
 	"os"
 	"time"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+	"packer-plugin-ibmcloud/builder/ibmcloud/sshkey"
+
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
 )
 
 type Config struct {
@@ -38,14 +43,36 @@ type Config struct {
 	PublicVlanId                   int64   `mapstructure:"public_vlan_id"`
 	InstanceNetworkSpeed           int     `mapstructure:"instance_network_speed"`
 	ProvisioningSshKeyId           int64   `mapstructure:"provisioning_ssh_key_id"`
+	SshKeyType                     string  `mapstructure:"ssh_key_type"`
+	SshKeyBits                     int     `mapstructure:"ssh_key_bits"`
 	InstancePublicSecurityGroupIds []int64 `mapstructure:"public_security_groups"`
 	UserDataFilePath               string  `mapstructure:"user_data_file_path"`
 
+	// WaitForCloudInit holds provisioning until cloud-init, or Cloudbase-Init
+	// on Windows, has run the user data, for at most CloudInitTimeout.
+	WaitForCloudInit bool          `mapstructure:"wait_for_cloud_init"`
+	CloudInitTimeout time.Duration `mapstructure:"cloud_init_timeout"`
+
+	// ResourceLedgerDir is where the build records the resources it creates
+	// (see package ledger).
+	ResourceLedgerDir string `mapstructure:"resource_ledger_dir"`
+	// SSHKeyOutputDir keeps the SSH key pair the build generates, in a
+	// directory named after the build ID (see package workspace).
+	SSHKeyOutputDir string `mapstructure:"ssh_key_output_dir"`
+	BuildID         string `mapstructure-to-hcl2:",skip"`
+
 	RawStateTimeout string              `mapstructure:"instance_state_timeout"`
 	StateTimeout    time.Duration       `mapstructure-to-hcl2:",skip"`
 	ctx             interpolate.Context `mapstructure-to-hcl2:",skip"`
 }
 
+// classicKeyBits are the key types and sizes the builder generates.
+var classicKeyBits = map[string][]int{
+	sshkey.RSA:     {2048, 3072, 4096},
+	sshkey.ECDSA:   {256, 384, 521},
+	sshkey.ED25519: {256},
+}
+
 // Prepare processes the build configuration parameters.
 func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	err := config.Decode(c, &config.DecodeOpts{
@@ -99,6 +126,12 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		if c.Comm.SSHUsername == "" {
 			c.Comm.SSHUsername = "root"
 		}
+		// The key pair is only generated for SSH builds.
+		keyType, bits, err := sshkey.Check(c.SshKeyType, c.SshKeyBits, classicKeyBits)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("[ERROR] %s", err))
+		}
+		c.SshKeyType, c.SshKeyBits = keyType, bits
 	}
 
 	var byFlavor = true
@@ -166,7 +199,20 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
-	//log.Println(common.ScrubConfig(self.config, c.APIKey, c.Username))
+	if c.CloudInitTimeout == 0 {
+		c.CloudInitTimeout = 30 * time.Minute
+	}
+	if c.WaitForCloudInit && c.Comm.Type != "ssh" && c.Comm.Type != "winrm" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("[ERROR] wait_for_cloud_init requires the ssh or winrm communicator"))
+	}
+
+	c.BuildID = uuid.TimeOrderedUUID()
+	if c.ResourceLedgerDir == "" {
+		c.ResourceLedgerDir = ledger.DefaultDir()
+	}
+
+	redact.Secrets(c.APIKey, c.Comm.SSHPassword, c.Comm.WinRMPassword)
 
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
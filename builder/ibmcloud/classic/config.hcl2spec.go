@@ -86,8 +86,14 @@ type FlatConfig struct {
 	PublicVlanId                   *int64            `mapstructure:"public_vlan_id" cty:"public_vlan_id" hcl:"public_vlan_id"`
 	InstanceNetworkSpeed           *int              `mapstructure:"instance_network_speed" cty:"instance_network_speed" hcl:"instance_network_speed"`
 	ProvisioningSshKeyId           *int64            `mapstructure:"provisioning_ssh_key_id" cty:"provisioning_ssh_key_id" hcl:"provisioning_ssh_key_id"`
+	SshKeyType                     *string           `mapstructure:"ssh_key_type" cty:"ssh_key_type" hcl:"ssh_key_type"`
+	SshKeyBits                     *int              `mapstructure:"ssh_key_bits" cty:"ssh_key_bits" hcl:"ssh_key_bits"`
 	InstancePublicSecurityGroupIds []int64           `mapstructure:"public_security_groups" cty:"public_security_groups" hcl:"public_security_groups"`
 	UserDataFilePath               *string           `mapstructure:"user_data_file_path" cty:"user_data_file_path" hcl:"user_data_file_path"`
+	WaitForCloudInit               *bool             `mapstructure:"wait_for_cloud_init" cty:"wait_for_cloud_init" hcl:"wait_for_cloud_init"`
+	CloudInitTimeout               *string           `mapstructure:"cloud_init_timeout" cty:"cloud_init_timeout" hcl:"cloud_init_timeout"`
+	ResourceLedgerDir              *string           `mapstructure:"resource_ledger_dir" cty:"resource_ledger_dir" hcl:"resource_ledger_dir"`
+	SSHKeyOutputDir                *string           `mapstructure:"ssh_key_output_dir" cty:"ssh_key_output_dir" hcl:"ssh_key_output_dir"`
 	RawStateTimeout                *string           `mapstructure:"instance_state_timeout" cty:"instance_state_timeout" hcl:"instance_state_timeout"`
 }
 
@@ -179,8 +185,14 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"public_vlan_id":               &hcldec.AttrSpec{Name: "public_vlan_id", Type: cty.Number, Required: false},
 		"instance_network_speed":       &hcldec.AttrSpec{Name: "instance_network_speed", Type: cty.Number, Required: false},
 		"provisioning_ssh_key_id":      &hcldec.AttrSpec{Name: "provisioning_ssh_key_id", Type: cty.Number, Required: false},
+		"ssh_key_type":                 &hcldec.AttrSpec{Name: "ssh_key_type", Type: cty.String, Required: false},
+		"ssh_key_bits":                 &hcldec.AttrSpec{Name: "ssh_key_bits", Type: cty.Number, Required: false},
 		"public_security_groups":       &hcldec.AttrSpec{Name: "public_security_groups", Type: cty.List(cty.Number), Required: false},
 		"user_data_file_path":          &hcldec.AttrSpec{Name: "user_data_file_path", Type: cty.String, Required: false},
+		"wait_for_cloud_init":          &hcldec.AttrSpec{Name: "wait_for_cloud_init", Type: cty.Bool, Required: false},
+		"cloud_init_timeout":           &hcldec.AttrSpec{Name: "cloud_init_timeout", Type: cty.String, Required: false},
+		"resource_ledger_dir":          &hcldec.AttrSpec{Name: "resource_ledger_dir", Type: cty.String, Required: false},
+		"ssh_key_output_dir":           &hcldec.AttrSpec{Name: "ssh_key_output_dir", Type: cty.String, Required: false},
 		"instance_state_timeout":       &hcldec.AttrSpec{Name: "instance_state_timeout", Type: cty.String, Required: false},
 	}
 	return s
@@ -0,0 +1,58 @@
+package classic
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Resource types of the classic builder's ledger.
+const (
+	ledgerInstance = "instance"
+	ledgerSshKey   = "ssh_key"
+)
+
+// CleanupLedger deletes the instance and SSH key a classic build recorded in
+// its ledger but did not delete, and marks them deleted in the ledger. The
+// instance goes first, as stepCreateInstance's Cleanup runs before the key's.
+func CleanupLedger(l *ledger.Ledger, username, apiKey string, ui packer.Ui, timeout time.Duration) error {
+	client := SoftlayerClient{}.New(username, apiKey)
+	pending := l.Pending()
+	var errs []error
+	for _, typ := range []string{ledgerInstance, ledgerSshKey} {
+		for _, r := range pending {
+			if r.Type != typ {
+				continue
+			}
+			ui.Say(fmt.Sprintf("Deleting %s %s (%s)...", r.Type, r.Name, r.ID))
+			var err error
+			switch r.Type {
+			case ledgerInstance:
+				// An instance with active transactions cannot be destroyed.
+				if err = client.waitForInstanceReady(r.ID, timeout); err == nil {
+					err = client.DestroyInstance(r.ID)
+				}
+			case ledgerSshKey:
+				var keyId int64
+				if keyId, err = strconv.ParseInt(r.ID, 10, 64); err == nil {
+					err = client.DestroySshKey(keyId)
+				}
+			}
+			if err != nil {
+				err := fmt.Errorf("[ERROR] Error deleting %s %s (%s): %s", r.Type, r.Name, r.ID, err)
+				ui.Error(err.Error())
+				errs = append(errs, err)
+				continue
+			}
+			if err := l.Deleted(r.Type, r.ID); err != nil {
+				ui.Error(fmt.Sprintf("Warning: could not record the deletion of %s %s in %s: %s", r.Type, r.ID, l.Path(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 type stepCaptureImage struct{}
@@ -64,6 +65,8 @@ func (s *stepCaptureImage) Run(_ context.Context, state multistep.StateBag) mult
 	}
 
 	state.Put("image_id", imageId)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("ImageID", imageId)
 
 	ui.Say(fmt.Sprintf("Waiting for image (%s) to finish its creation...", imageId))
 
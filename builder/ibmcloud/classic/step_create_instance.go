@@ -6,8 +6,11 @@ import (
 	"log"
 	"os"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 type stepCreateInstance struct {
@@ -68,8 +71,16 @@ func (s *stepCreateInstance) Run(_ context.Context, state multistep.StateBag) mu
 
 	state.Put("instance_data", instanceData)
 	s.instanceId = instanceData["globalIdentifier"].(string)
+	ledger.RecordCreated(state, ledgerInstance, s.instanceId, config.InstanceName)
 	ui.Say(fmt.Sprintf("Created instance, id: '%s'", instanceData["globalIdentifier"].(string)))
 
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("InstanceID", s.instanceId)
+	generatedData.Put("InstanceName", config.InstanceName)
+	generatedData.Put("Datacenter", config.DatacenterName)
+	generatedData.Put("SourceImageID", config.BaseImageId)
+	generatedData.Put("SourceOSCode", config.BaseOsCode)
+
 	return multistep.ActionContinue
 }
 
@@ -97,5 +108,7 @@ func (s *stepCreateInstance) Cleanup(state multistep.StateBag) {
 	if err != nil {
 		log.Printf("Error destroying instance: %v", err.Error())
 		ui.Error(fmt.Sprintf("Error cleaning up the instance. Please delete the instance (%s) manually", s.instanceId))
+		return
 	}
+	ledger.RecordDeleted(state, ledgerInstance, s.instanceId)
 }
@@ -2,20 +2,20 @@ package classic
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+	"packer-plugin-ibmcloud/builder/ibmcloud/sshkey"
+	"packer-plugin-ibmcloud/builder/ibmcloud/workspace"
+
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
-	"golang.org/x/crypto/ssh"
 )
 
 type stepCreateSshKey struct {
@@ -26,92 +26,44 @@ type stepCreateSshKey struct {
 func (s *stepCreateSshKey) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packer.Ui)
 	client := state.Get("client").(*SoftlayerClient)
+	config := state.Get("config").(Config)
+	buildWorkspace := state.Get("workspace").(*workspace.Workspace)
 
-	ui.Say("Creating SSH Public and Private Key Pair...")
-	keysDirectory := "ssh_keys/"
-	privatefilepath := keysDirectory + "id_rsa"
-	publicfilepath := keysDirectory + "id_rsa.pub"
+	ui.Say(fmt.Sprintf("Creating %d bits %s SSH Public and Private Key Pair...", config.SshKeyBits, config.SshKeyType))
+	privatefilepath := buildWorkspace.Path("id_" + config.SshKeyType)
+	publicfilepath := privatefilepath + ".pub"
 
-	// Create a new directory in the current working directory, if it does not exist
-	if _, err := os.Stat(keysDirectory); os.IsNotExist(err) {
-		err := os.Mkdir(keysDirectory, 0755)
-		if err != nil {
-			err := fmt.Errorf("[ERROR] Error, cannot create SSH Keys folder: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			// log.Fatalf(err.Error())
-			return multistep.ActionHalt
-		}
-	}
-
-	// Creating new RSA Private key
-	rsaKey, err := rsa.GenerateKey(rand.Reader, 2014)
+	pair, err := sshkey.Generate(config.SshKeyType, config.SshKeyBits)
 	if err != nil {
-		err := fmt.Errorf("[ERROR] Error, cannot generate Private SSH Key: %s", err)
+		err := fmt.Errorf("[ERROR] Error, cannot generate SSH Key Pair: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
-	privDer := x509.MarshalPKCS1PrivateKey(rsaKey)
-	privBlk := pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   privDer,
-	}
+	redact.Secrets(string(pair.PrivateKey))
 
 	// Writing Private key to file
-	if privatefilepath != "" {
-		ui.Say(fmt.Sprintf("Writing Private SSH Key to a file %s", privatefilepath))
-		privatekey := string(pem.EncodeToMemory(&privBlk))
-		privateKey := []byte(fmt.Sprintf("%s\n", privatekey))
-		err = ioutil.WriteFile(privatefilepath, privateKey, 0600)
-		if err != nil {
-			err := fmt.Errorf("[ERROR] Failed to write Private SSH Key to file: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-		err = os.Chmod(privatefilepath, 0600)
-		if err != nil {
-			err := fmt.Errorf("[ERROR] Failed to edit Private SSH Key's permission: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-	}
-	// Set the private key in the statebag for later
-	state.Put("ssh_private_key", string(pem.EncodeToMemory(&privBlk)))
-
-	// Creating new RSA Public key
-	pub, err := ssh.NewPublicKey(&rsaKey.PublicKey)
+	ui.Say(fmt.Sprintf("Writing Private SSH Key to a file %s", privatefilepath))
+	err = os.WriteFile(privatefilepath, pair.PrivateKey, 0600)
 	if err != nil {
-		err := fmt.Errorf("[ERROR] Error, cannot generate SSH Public Key: %s", err)
+		err := fmt.Errorf("[ERROR] Failed to write Private SSH Key to file: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
-	publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	// Set the private key in the statebag for later
+	state.Put("ssh_private_key", string(pair.PrivateKey))
 
 	// Writing Public key to file
-	if publicfilepath != "" {
-		ui.Say(fmt.Sprintf("Writing Public SSH Key to a file %s", publicfilepath))
-		pubkey := string(publicKey)
-		pubKey := []byte(fmt.Sprintf("%s\n", pubkey))
-		err = ioutil.WriteFile(publicfilepath, pubKey, 0600)
-		if err != nil {
-			err := fmt.Errorf("[ERROR] Failed to write Public SSH Key to file: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-		err = os.Chmod(publicfilepath, 0600)
-		if err != nil {
-			err := fmt.Errorf("[ERROR] Failed to edit Public SSH Key's permission: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
+	ui.Say(fmt.Sprintf("Writing Public SSH Key to a file %s", publicfilepath))
+	err = os.WriteFile(publicfilepath, pair.PublicKey, 0600)
+	if err != nil {
+		err := fmt.Errorf("[ERROR] Failed to write Public SSH Key to file: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
 	}
+	publicKey := strings.TrimSpace(string(pair.PublicKey))
 
 	// The name of the public key
 	label := fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
@@ -124,6 +76,7 @@ func (s *stepCreateSshKey) Run(_ context.Context, state multistep.StateBag) mult
 
 	s.keyId = keyId
 	state.Put("ssh_key_id", keyId)
+	ledger.RecordCreated(state, ledgerSshKey, strconv.FormatInt(keyId, 10), label)
 	ui.Say(fmt.Sprintf("Created SSH key with id '%d'", keyId))
 	ui.Say("Public and Private SSH Key Pair successfully created.")
 	return multistep.ActionContinue
@@ -142,16 +95,26 @@ func (s *stepCreateSshKey) Cleanup(state multistep.StateBag) {
 	if err2 != nil {
 		log.Printf("Error cleaning up ssh key: %v", err2.Error())
 		ui.Error(fmt.Sprintf("Error cleaning up ssh key. Please delete the key (%d) manually", s.keyId))
+	} else {
+		ledger.RecordDeleted(state, ledgerSshKey, strconv.FormatInt(s.keyId, 10))
 	}
 
-	ui.Say("Deleting Directory with Public and Private SSH Key Pair...")
-	err := os.RemoveAll("ssh_keys")
-	if err != nil {
-		err := fmt.Errorf("[ERROR] Failed to delete SSH Key folder: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
+	buildWorkspace := state.Get("workspace").(*workspace.Workspace)
+	if buildWorkspace.Kept() {
+		ui.Say(fmt.Sprintf("Keeping Public and Private SSH Key Pair in %s", buildWorkspace.Dir))
 		return
 	}
+	ui.Say("Deleting Public and Private SSH Key Pair files...")
+	config := state.Get("config").(Config)
+	privatefilepath := buildWorkspace.Path("id_" + config.SshKeyType)
+	for _, file := range []string{privatefilepath, privatefilepath + ".pub"} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			err := fmt.Errorf("[ERROR] Failed to delete SSH Key file: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return
+		}
+	}
 
 	ui.Say("Public and Private SSH Key Pair successfully deleted.")
 }
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 type stepGrabPublicIP struct{}
@@ -30,6 +32,16 @@ func (s *stepGrabPublicIP) Run(_ context.Context, state multistep.StateBag) mult
 
 	ui.Say(fmt.Sprintf("Grabbed IP Address: %s", ipAddress))
 
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("PublicIP", ipAddress)
+	// The private address is only informational (build.PrivateIP), so a failed
+	// lookup is logged rather than failing the build.
+	if privateIp, err := client.getInstancePrivateIp(instanceID); err != nil {
+		log.Printf("Failed to fetch Private IP address for instance '%s': %s", instanceID, err)
+	} else {
+		generatedData.Put("PrivateIP", privateIp)
+	}
+
 	if config.Comm.Type == "winrm" {
 		config.Comm.WinRMHost = ipAddress
 	} else if config.Comm.Type == "ssh" {
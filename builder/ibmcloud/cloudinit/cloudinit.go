@@ -0,0 +1,134 @@
+// Package cloudinit holds provisioning until the instance's user data has
+// been run by cloud-init, or Cloudbase-Init on Windows, so provisioners do not
+// race it for package manager locks or find its changes half made.
+package cloudinit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+	"unicode/utf16"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The exit statuses of the wait command other than 0.
+const (
+	// degraded is cloud-init's status when it finished with recoverable
+	// errors, such as deprecated configuration keys.
+	degraded = 2
+	// timedOut is the status of timeout(1), and of the Windows command, when
+	// the agent has not finished in time.
+	timedOut = 124
+)
+
+// linuxCommand waits for cloud-init, printing its status, and prints the tail
+// of its output log when it failed. Instances without cloud-init are not
+// waited for.
+const linuxCommand = `if ! command -v cloud-init >/dev/null 2>&1; then echo "cloud-init is not installed, not waiting for it"; exit 0; fi; ` +
+	`timeout %d cloud-init status --wait --long; status=$?; ` +
+	`if [ $status -ne 0 ] && [ $status -ne 2 ]; then echo "Last lines of /var/log/cloud-init-output.log:"; tail -n 50 /var/log/cloud-init-output.log; fi; ` +
+	`exit $status`
+
+// windowsScript waits for the Cloudbase-Init service to stop, which it does
+// once its plugins have run, and prints the tail of its log when one of them
+// logged an error.
+const windowsScript = `$ErrorActionPreference = 'Stop'
+if (-not (Get-Service cloudbase-init -ErrorAction SilentlyContinue)) {
+  Write-Output 'Cloudbase-Init is not installed, not waiting for it'
+  exit 0
+}
+$deadline = (Get-Date).AddSeconds(%d)
+while ((Get-Service cloudbase-init).Status -ne 'Stopped') {
+  if ((Get-Date) -gt $deadline) {
+    exit 124
+  }
+  Start-Sleep -Seconds 5
+}
+$log = "$env:ProgramFiles\Cloudbase Solutions\Cloudbase-Init\log\cloudbase-init.log"
+if ((Test-Path $log) -and (Select-String -Path $log -Pattern ' ERROR ' -Quiet)) {
+  Write-Output "Last lines of ${log}:"
+  Get-Content $log -Tail 50
+  exit 1
+}
+Write-Output 'Cloudbase-Init has run'
+`
+
+// StepWait waits for cloud-init, or Cloudbase-Init, to finish when Enabled.
+// It runs between communicator.StepConnect and commonsteps.StepProvision.
+type StepWait struct {
+	Enabled bool
+	// Timeout bounds the wait; the build fails when it expires.
+	Timeout time.Duration
+	// Windows waits for Cloudbase-Init instead of cloud-init.
+	Windows bool
+	// Sudo runs the wait through sudo, for an ssh user other than root.
+	Sudo bool
+}
+
+func (s *StepWait) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+	ui := state.Get("ui").(packer.Ui)
+	comm := state.Get("communicator").(packer.Communicator)
+
+	agent := "cloud-init"
+	if s.Windows {
+		agent = "Cloudbase-Init"
+	}
+	ui.Say(fmt.Sprintf("Waiting up to %s for %s to finish...", s.Timeout, agent))
+	cmd := &packer.RemoteCmd{Command: s.command()}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		err := fmt.Errorf("[ERROR] Error waiting for %s: %s", agent, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	switch status := cmd.ExitStatus(); status {
+	case 0:
+		ui.Say(fmt.Sprintf("%s finished!", agent))
+		return multistep.ActionContinue
+	case degraded:
+		if !s.Windows {
+			ui.Say("cloud-init finished with recoverable errors, see its status above.")
+			return multistep.ActionContinue
+		}
+		fallthrough
+	default:
+		var err error
+		if status == timedOut {
+			err = fmt.Errorf("[ERROR] %s did not finish within %s", agent, s.Timeout)
+		} else {
+			err = fmt.Errorf("[ERROR] %s failed with exit status %d, see its output above", agent, status)
+		}
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+}
+
+func (s *StepWait) Cleanup(state multistep.StateBag) {}
+
+// command returns the remote command that waits for the agent.
+func (s *StepWait) command() string {
+	seconds := int(s.Timeout.Seconds())
+	if s.Windows {
+		// An encoded command is passed through WinRM without any quoting.
+		script := utf16.Encode([]rune(fmt.Sprintf(windowsScript, seconds)))
+		encoded := make([]byte, 0, 2*len(script))
+		for _, r := range script {
+			encoded = append(encoded, byte(r), byte(r>>8))
+		}
+		return "powershell -NoProfile -NonInteractive -EncodedCommand " + base64.StdEncoding.EncodeToString(encoded)
+	}
+
+	command := "sh -c '" + fmt.Sprintf(linuxCommand, seconds) + "'"
+	if s.Sudo {
+		command = "sudo -n " + command
+	}
+	return command
+}
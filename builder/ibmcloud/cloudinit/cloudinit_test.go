@@ -0,0 +1,90 @@
+package cloudinit
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// decodeWindowsCommand returns the script of a Windows wait command.
+func decodeWindowsCommand(t *testing.T, command string) string {
+	raw, err := base64.StdEncoding.DecodeString(command[strings.LastIndex(command, " ")+1:])
+	if err != nil {
+		t.Fatalf("the command is not encoded: %s", err)
+	}
+	script := make([]uint16, len(raw)/2)
+	for i := range script {
+		script[i] = uint16(raw[2*i]) | uint16(raw[2*i+1])<<8
+	}
+	return string(utf16.Decode(script))
+}
+
+func TestStepWait(t *testing.T) {
+	cases := []struct {
+		name       string
+		step       StepWait
+		exitStatus int
+		wantAction multistep.StepAction
+		wantErr    string
+	}{
+		{name: "disabled", step: StepWait{}, wantAction: multistep.ActionContinue},
+		{name: "done", step: StepWait{Enabled: true, Timeout: time.Minute}, wantAction: multistep.ActionContinue},
+		{name: "degraded", step: StepWait{Enabled: true, Timeout: time.Minute}, exitStatus: 2, wantAction: multistep.ActionContinue},
+		{name: "error", step: StepWait{Enabled: true, Timeout: time.Minute}, exitStatus: 1, wantAction: multistep.ActionHalt,
+			wantErr: "cloud-init failed with exit status 1"},
+		{name: "timeout", step: StepWait{Enabled: true, Timeout: time.Minute}, exitStatus: 124, wantAction: multistep.ActionHalt,
+			wantErr: "cloud-init did not finish within 1m0s"},
+		{name: "windows error", step: StepWait{Enabled: true, Timeout: time.Minute, Windows: true}, exitStatus: 2, wantAction: multistep.ActionHalt,
+			wantErr: "Cloudbase-Init failed with exit status 2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			comm := &packer.MockCommunicator{StartExitStatus: tc.exitStatus}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packer.TestUi(t))
+			state.Put("communicator", comm)
+
+			if action := tc.step.Run(context.Background(), state); action != tc.wantAction {
+				t.Fatalf("expected %v, got %v: %v", tc.wantAction, action, state.Get("error"))
+			}
+			if comm.StartCalled != tc.step.Enabled {
+				t.Errorf("wait command run: %t, want %t", comm.StartCalled, tc.step.Enabled)
+			}
+			err, _ := state.GetOk("error")
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.(error).Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	linux := (&StepWait{Timeout: 30 * time.Minute}).command()
+	if !strings.HasPrefix(linux, "sh -c '") || !strings.Contains(linux, "timeout 1800 cloud-init status --wait --long") {
+		t.Errorf("unexpected command %q", linux)
+	}
+	if sudo := (&StepWait{Timeout: 30 * time.Minute, Sudo: true}).command(); sudo != "sudo -n "+linux {
+		t.Errorf("got %q, want %q", sudo, "sudo -n "+linux)
+	}
+
+	windows := (&StepWait{Timeout: 10 * time.Minute, Windows: true}).command()
+	if !strings.HasPrefix(windows, "powershell -NoProfile -NonInteractive -EncodedCommand ") {
+		t.Fatalf("unexpected command %q", windows)
+	}
+	script := decodeWindowsCommand(t, windows)
+	for _, want := range []string{"Get-Service cloudbase-init", "AddSeconds(600)", "exit 124"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("the script does not contain %q:\n%s", want, script)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package ledger records the resources a build creates in a JSON file as soon
+// as the API returns them, and marks them deleted when the build's steps tear
+// them down. If Packer is killed before its Cleanup methods run, the file is
+// left behind listing exactly what still exists, and the plugin's cleanup
+// subcommand can finish the teardown from it.
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Resource is one resource a build created.
+type Resource struct {
+	// Type is builder specific, e.g. "instance" or "floating_ip".
+	Type      string     `json:"type"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Ledger is the record of one build. Everything but the resources is written
+// once, when the build starts, and tells the cleanup subcommand where the
+// resources live.
+type Ledger struct {
+	// Builder is "vpc" or "classic".
+	Builder   string    `json:"builder"`
+	BuildID   string    `json:"build_id"`
+	Region    string    `json:"region,omitempty"`
+	Endpoint  string    `json:"endpoint,omitempty"`
+	IAMURL    string    `json:"iam_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Resources []Resource `json:"resources"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultDir is where ledgers are written unless resource_ledger_dir is set.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "packer-plugin-ibmcloud", "ledger")
+}
+
+// New starts the ledger of a build in dir, named after the build ID, and
+// writes it so a directory that cannot be written fails the build up front.
+func New(dir string, l *Ledger) (*Ledger, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating ledger directory: %s", err)
+	}
+	l.path = filepath.Join(dir, l.BuildID+".json")
+	l.CreatedAt = time.Now().UTC()
+	l.Resources = []Resource{}
+	if err := l.save(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Open reads the ledger at path.
+func Open(path string) (*Ledger, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &Ledger{path: path}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("parsing ledger %s: %s", path, err)
+	}
+	return l, nil
+}
+
+// List opens every ledger in dir. A missing directory has no ledgers.
+func List(dir string) ([]*Ledger, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	ledgers := []*Ledger{}
+	for _, path := range paths {
+		l, err := Open(path)
+		if err != nil {
+			return nil, err
+		}
+		ledgers = append(ledgers, l)
+	}
+	return ledgers, nil
+}
+
+// Path is the file the ledger is written to.
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Created records a resource the build just created.
+func (l *Ledger) Created(typ, id, name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Resources = append(l.Resources, Resource{Type: typ, ID: id, Name: name, CreatedAt: time.Now().UTC()})
+	return l.save()
+}
+
+// Deleted marks a recorded resource as deleted. Unknown resources are ignored.
+func (l *Ledger) Deleted(typ, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, r := range l.Resources {
+		if r.Type == typ && r.ID == id && r.DeletedAt == nil {
+			now := time.Now().UTC()
+			l.Resources[i].DeletedAt = &now
+			return l.save()
+		}
+	}
+	return nil
+}
+
+// Pending returns the resources that have not been deleted, in the order they
+// were created.
+func (l *Ledger) Pending() []Resource {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pending := []Resource{}
+	for _, r := range l.Resources {
+		if r.DeletedAt == nil {
+			pending = append(pending, r)
+		}
+	}
+	return pending
+}
+
+// Close removes the ledger file once every resource in it has been deleted,
+// and otherwise leaves it for the cleanup subcommand. It reports whether the
+// file was kept.
+func (l *Ledger) Close() (kept bool, err error) {
+	if len(l.Pending()) > 0 {
+		return true, nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// Finish is called once a build's steps have cleaned up. It closes the ledger
+// and, when resources were left behind, tells the user how to delete them.
+func (l *Ledger) Finish(ui packer.Ui) {
+	kept, err := l.Close()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Warning: could not remove the resource ledger %s: %s", l.path, err))
+	}
+	if kept {
+		ui.Error(fmt.Sprintf("Some resources created by this build were not deleted; they are recorded in %s. Delete them with: packer-plugin-ibmcloud cleanup -ledger %s", l.path, l.path))
+	}
+}
+
+// save writes the ledger through a temporary file and a rename, so a crash
+// mid-write never leaves a truncated ledger behind.
+func (l *Ledger) save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".ledger-*")
+	if err != nil {
+		return fmt.Errorf("writing ledger %s: %s", l.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing ledger %s: %s", l.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing ledger %s: %s", l.path, err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("writing ledger %s: %s", l.path, err)
+	}
+	return nil
+}
+
+// RecordCreated adds a resource to the build's ledger, stored in the state bag
+// under "ledger". Builds without a ledger (the post-processors) are a no-op. A
+// failed write is reported but does not fail the build: the resource exists
+// either way, and the step's own Cleanup still deletes it.
+func RecordCreated(state multistep.StateBag, typ, id, name string) {
+	l, ok := state.Get("ledger").(*Ledger)
+	if !ok {
+		return
+	}
+	if err := l.Created(typ, id, name); err != nil {
+		state.Get("ui").(packer.Ui).Error(fmt.Sprintf("Warning: could not record %s %s in the resource ledger: %s", typ, id, err))
+	}
+}
+
+// RecordDeleted marks a resource deleted in the build's ledger; see RecordCreated.
+func RecordDeleted(state multistep.StateBag, typ, id string) {
+	l, ok := state.Get("ledger").(*Ledger)
+	if !ok {
+		return
+	}
+	if err := l.Deleted(typ, id); err != nil {
+		state.Get("ui").(packer.Ui).Error(fmt.Sprintf("Warning: could not record the deletion of %s %s in the resource ledger: %s", typ, id, err))
+	}
+}
@@ -0,0 +1,88 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestLedgerRecordsResources(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ledger")
+	l, err := New(dir, &Ledger{Builder: "vpc", BuildID: "build-1", Region: "us-south"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if l.Path() != filepath.Join(dir, "build-1.json") {
+		t.Errorf("unexpected ledger path %s", l.Path())
+	}
+	info, err := os.Stat(l.Path())
+	if err != nil {
+		t.Fatalf("the ledger should be written when the build starts: %s", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("ledger permissions = %v, want 0600", info.Mode().Perm())
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packer.TestUi(t))
+	state.Put("ledger", l)
+	RecordCreated(state, "key", "key-1", "packer-vpc-ssh-key-1")
+	RecordCreated(state, "instance", "vsi-1", "packer-vpc-vsi-1")
+	RecordDeleted(state, "instance", "vsi-1")
+	RecordDeleted(state, "instance", "unknown")
+
+	// A crashed build leaves the file behind; a later process reads it back.
+	reopened, err := Open(l.Path())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if reopened.Builder != "vpc" || reopened.BuildID != "build-1" || reopened.Region != "us-south" {
+		t.Errorf("unexpected ledger header: %+v", reopened)
+	}
+	pending := reopened.Pending()
+	if len(pending) != 1 || pending[0].Type != "key" || pending[0].ID != "key-1" {
+		t.Fatalf("expected only the key to be pending, got %+v", pending)
+	}
+
+	if kept, err := reopened.Close(); err != nil || !kept {
+		t.Fatalf("a ledger with pending resources must be kept, got kept=%v err=%v", kept, err)
+	}
+	if err := reopened.Deleted("key", "key-1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if kept, err := reopened.Close(); err != nil || kept {
+		t.Fatalf("a ledger with nothing pending must be removed, got kept=%v err=%v", kept, err)
+	}
+	if _, err := os.Stat(l.Path()); !os.IsNotExist(err) {
+		t.Errorf("expected the ledger file to be removed, got %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"build-1", "build-2"} {
+		if _, err := New(dir, &Ledger{Builder: "classic", BuildID: id}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	ledgers, err := List(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(ledgers) != 2 || ledgers[0].BuildID != "build-1" || ledgers[1].BuildID != "build-2" {
+		t.Errorf("unexpected ledgers: %+v", ledgers)
+	}
+
+	if ledgers, err := List(filepath.Join(dir, "missing")); err != nil || len(ledgers) != 0 {
+		t.Errorf("a missing directory should have no ledgers, got %v, %v", ledgers, err)
+	}
+}
+
+func TestRecordWithoutLedger(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	RecordCreated(state, "instance", "vsi-1", "")
+	RecordDeleted(state, "instance", "vsi-1")
+}
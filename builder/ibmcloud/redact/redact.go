@@ -0,0 +1,104 @@
+// Package redact keeps credentials out of the plugin's output. Values
+// registered with Secrets are replaced by "<sensitive>" in the plugin
+// process's log output, once Logs has routed it through Packer's secret
+// filter, and in everything said through a UI wrapped by UI.
+//
+// Packer core only filters the sensitive variables of the template; the API
+// keys read from the environment and the passwords and keys generated during
+// a build are only known to the plugin, so it has to filter them itself.
+package redact
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// minLineLength is the shortest line of a multi-line secret registered on its
+// own, so short, common lines do not scrub unrelated output.
+const minLineLength = 16
+
+// Secrets registers values with packer.LogSecretFilter. Each line of a
+// multi-line value, such as a PEM encoded private key, is registered as well,
+// except for the PEM armor, so output carrying part of one is scrubbed too.
+func Secrets(values ...string) {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		packer.LogSecretFilter.Set(value)
+		if !strings.Contains(value, "\n") {
+			continue
+		}
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) >= minLineLength && !strings.HasPrefix(line, "-----") {
+				packer.LogSecretFilter.Set(line)
+			}
+		}
+	}
+}
+
+// Filter returns s with every registered secret replaced.
+func Filter(s string) string {
+	return packer.LogSecretFilter.FilterString(s)
+}
+
+// Logs sends the standard logger's output to w through packer.LogSecretFilter.
+// The plugin calls it once, before serving builds.
+func Logs(w io.Writer) {
+	packer.LogSecretFilter.SetOutput(w)
+	log.SetOutput(&packer.LogSecretFilter)
+}
+
+// UI wraps ui so every message said through it is filtered.
+func UI(ui packer.Ui) packer.Ui {
+	if _, ok := ui.(*redactedUI); ok {
+		return ui
+	}
+	return &redactedUI{ui}
+}
+
+type redactedUI struct {
+	packer.Ui
+}
+
+func (u *redactedUI) Ask(query string) (string, error) {
+	return u.Ui.Ask(Filter(query))
+}
+
+func (u *redactedUI) Askf(query string, args ...any) (string, error) {
+	return u.Ui.Ask(Filter(fmt.Sprintf(query, args...)))
+}
+
+func (u *redactedUI) Say(message string) {
+	u.Ui.Say(Filter(message))
+}
+
+func (u *redactedUI) Sayf(message string, args ...any) {
+	u.Ui.Say(Filter(fmt.Sprintf(message, args...)))
+}
+
+func (u *redactedUI) Message(message string) {
+	u.Ui.Message(Filter(message))
+}
+
+func (u *redactedUI) Error(message string) {
+	u.Ui.Error(Filter(message))
+}
+
+func (u *redactedUI) Errorf(message string, args ...any) {
+	u.Ui.Error(Filter(fmt.Sprintf(message, args...)))
+}
+
+func (u *redactedUI) Machine(category string, args ...string) {
+	filtered := make([]string, len(args))
+	for i, arg := range args {
+		filtered[i] = Filter(arg)
+	}
+	u.Ui.Machine(category, filtered...)
+}
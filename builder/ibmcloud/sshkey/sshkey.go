@@ -0,0 +1,143 @@
+// Package sshkey generates the SSH key pairs the builders connect to their
+// instances with, and checks ssh_key_type and ssh_key_bits against the key
+// sizes each builder's key API accepts.
+package sshkey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The key types of ssh_key_type.
+const (
+	RSA     = "rsa"
+	ECDSA   = "ecdsa"
+	ED25519 = "ed25519"
+)
+
+// Types lists the key types in the order they are offered.
+var Types = []string{RSA, ECDSA, ED25519}
+
+// DefaultBits is the size of a key of each type when ssh_key_bits is unset.
+var DefaultBits = map[string]int{RSA: 2048, ECDSA: 256, ED25519: 256}
+
+// Pair is a generated key pair.
+type Pair struct {
+	// PrivateKey is PEM encoded: PKCS #1 for RSA keys, SEC 1 for ECDSA keys
+	// and OpenSSH for ed25519 keys.
+	PrivateKey []byte
+	// PublicKey is in the authorized_keys format.
+	PublicKey []byte
+}
+
+// Check validates keyType and bits against accepted, the sizes a builder
+// accepts for each type, and returns the type and size to generate: rsa
+// unless keyType is set, and the type's default size unless bits is.
+func Check(keyType string, bits int, accepted map[string][]int) (string, int, error) {
+	if keyType == "" {
+		keyType = RSA
+	}
+	sizes, ok := accepted[keyType]
+	if !ok {
+		types := []string{}
+		for _, t := range Types {
+			if _, ok := accepted[t]; ok {
+				types = append(types, t)
+			}
+		}
+		return "", 0, fmt.Errorf("ssh_key_type must be one of %s, not %q", strings.Join(types, ", "), keyType)
+	}
+	if bits == 0 {
+		bits = DefaultBits[keyType]
+	}
+	if !slices.Contains(sizes, bits) {
+		names := []string{}
+		for _, size := range sizes {
+			names = append(names, strconv.Itoa(size))
+		}
+		return "", 0, fmt.Errorf("ssh_key_bits must be %s for %s keys, not %d", strings.Join(names, " or "), keyType, bits)
+	}
+	return keyType, bits, nil
+}
+
+// TypeAndBits returns the type and size of a public key, as Check takes them.
+// The type is "" for a kind of key no builder generates.
+func TypeAndBits(key ssh.PublicKey) (string, int) {
+	cryptoKey, ok := key.(ssh.CryptoPublicKey)
+	if !ok {
+		return "", 0
+	}
+	switch k := cryptoKey.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return RSA, k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return ECDSA, k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return ED25519, 256
+	}
+	return "", 0
+}
+
+// Generate creates a key pair of keyType and bits, as returned by Check.
+func Generate(keyType string, bits int) (*Pair, error) {
+	var signer crypto.Signer
+	var block *pem.Block
+	switch keyType {
+	case RSA:
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	case ECDSA:
+		curves := map[int]elliptic.Curve{256: elliptic.P256(), 384: elliptic.P384(), 521: elliptic.P521()}
+		curve, ok := curves[bits]
+		if !ok {
+			return nil, fmt.Errorf("there is no %d bits ecdsa curve", bits)
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	case ED25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+		block, err = ssh.MarshalPrivateKey(key, "")
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+
+	publicKey, err := ssh.NewPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Pair{
+		PrivateKey: pem.EncodeToMemory(block),
+		PublicKey:  ssh.MarshalAuthorizedKey(publicKey),
+	}, nil
+}
@@ -3,13 +3,23 @@ package vpc
 import (
 	"fmt"
 	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // Artifact represents a Image volume as the result of a Packer build.
 type Artifact struct {
 	imageName string
 	imageId   string
-	client    *IBMCloudClient
+
+	// Connection details for Destroy. The artifact outlives the build's state
+	// bag (and its VPC client), so it keeps what it needs to build its own.
+	apiKey      string
+	iamEndpoint string
+	endpoint    string
+	timeout     time.Duration
 
 	// StateData should store data such as GeneratedData to be shared with post-processors
 	StateData map[string]interface{}
@@ -39,9 +49,31 @@ func (a *Artifact) State(name string) interface{} {
 	return a.StateData[name]
 }
 
-// Destroy destroys the VPC image represented by the artifact.
+// Destroy destroys the VPC image represented by the artifact and waits until
+// it is gone.
 func (a *Artifact) Destroy() error {
 	log.Printf("Destroying image: %s", a.String())
-	// err := artifact.client.destroyImage(artifact.imageId)
+	return DestroyImage(a.apiKey, a.iamEndpoint, a.endpoint, a.imageId, a.timeout)
+}
+
+// DestroyImage deletes the image at the VPC endpoint and waits until it is
+// gone. It serves artifacts, whose Destroy runs after the build's state bag
+// (and its VPC client) is gone.
+func DestroyImage(apiKey, iamEndpoint, endpoint, imageID string, timeout time.Duration) error {
+	vpcService, err := NewVPCService(apiKey, iamEndpoint, endpoint)
+	if err != nil {
+		return fmt.Errorf("[ERROR] Error creating VPC service %s", err)
+	}
+
+	// Destroy is called without a UI, so the wait's progress goes to the log.
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packer.BasicUi{Writer: log.Writer(), ErrorWriter: log.Writer()})
+	state.Put("vpcService", vpcService)
+
+	client := IBMCloudClient{}.New(apiKey)
+	if err := client.destroyImage(imageID, timeout, state); err != nil {
+		return err
+	}
+	log.Printf("Image %s destroyed", imageID)
 	return nil
 }
@@ -0,0 +1,102 @@
+package vpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// imageDeleteHandler serves the three calls destroyImage makes: the instance
+// list (usersJSON is the "instances" array), DELETE /images/{id} answered with
+// deleteStatus, and GET /images/{id}, which reports the image gone (404).
+func imageDeleteHandler(usersJSON string, deleteStatus int, deletes *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/instances"):
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"instances":` + usersJSON + `,"limit":50}`))
+		case r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, "/images/img-1"):
+			atomic.AddInt32(deletes, 1)
+			w.WriteHeader(deleteStatus)
+			_, _ = w.Write([]byte(`{}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/images/img-1"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"not_found"}]}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}
+}
+
+func newDestroyImageState(t *testing.T, url string) *multistep.BasicStateBag {
+	t.Helper()
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packer.TestUi(t))
+	state.Put("vpcService", newTestVpcService(t, url))
+	return state
+}
+
+func TestDestroyImageDeletesAndWaits(t *testing.T) {
+	var deletes int32
+	srv := httptest.NewServer(imageDeleteHandler(`[]`, http.StatusAccepted, &deletes))
+	defer srv.Close()
+
+	client := IBMCloudClient{}
+	if err := client.destroyImage("img-1", 30*time.Second, newDestroyImageState(t, srv.URL)); err != nil {
+		t.Fatalf("destroyImage returned error: %s", err)
+	}
+	if got := atomic.LoadInt32(&deletes); got != 1 {
+		t.Errorf("expected exactly one DELETE, got %d", got)
+	}
+}
+
+// An image that still backs an instance must not be deleted: the caller gets an
+// error naming the instance and no DELETE is issued.
+func TestDestroyImageRefusesImageInUse(t *testing.T) {
+	var deletes int32
+	users := `[{"id":"i-1","name":"web-1","image":{"id":"img-1"}},{"id":"i-2","name":"db-1","image":{"id":"img-2"}}]`
+	srv := httptest.NewServer(imageDeleteHandler(users, http.StatusAccepted, &deletes))
+	defer srv.Close()
+
+	client := IBMCloudClient{}
+	err := client.destroyImage("img-1", 30*time.Second, newDestroyImageState(t, srv.URL))
+	if err == nil {
+		t.Fatal("expected an error for an image still used by an instance")
+	}
+	if !strings.Contains(err.Error(), "web-1") || strings.Contains(err.Error(), "db-1") {
+		t.Errorf("error should name only the instance using the image, got: %s", err)
+	}
+	if got := atomic.LoadInt32(&deletes); got != 0 {
+		t.Errorf("expected no DELETE for an image in use, got %d", got)
+	}
+}
+
+func TestDestroyImageAlreadyGone(t *testing.T) {
+	var deletes int32
+	srv := httptest.NewServer(imageDeleteHandler(`[]`, http.StatusNotFound, &deletes))
+	defer srv.Close()
+
+	client := IBMCloudClient{}
+	if err := client.destroyImage("img-1", 30*time.Second, newDestroyImageState(t, srv.URL)); err != nil {
+		t.Fatalf("an already-deleted image should not be an error, got: %s", err)
+	}
+}
+
+func TestDestroyImageDeleteFails(t *testing.T) {
+	var deletes int32
+	srv := httptest.NewServer(imageDeleteHandler(`[]`, http.StatusConflict, &deletes))
+	defer srv.Close()
+
+	client := IBMCloudClient{}
+	if err := client.destroyImage("img-1", 30*time.Second, newDestroyImageState(t, srv.URL)); err == nil {
+		t.Fatal("expected an error when the DELETE is rejected")
+	}
+}
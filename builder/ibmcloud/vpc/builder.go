@@ -4,11 +4,17 @@ import (
 	"context"
 	"fmt"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/cloudinit"
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+	"packer-plugin-ibmcloud/builder/ibmcloud/workspace"
+
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 const BuilderId = "ibmcloud.vpc.builder"
@@ -57,14 +63,34 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	}
 
 	// Return the placeholder for the generated data that will become available to provisioners and post-processors.
-	// If the builder doesn't generate any data, just return an empty slice of string: []string{}
-	buildGeneratedData := []string{}
-	return buildGeneratedData, nil, nil
+	return generatedDataKeys, nil, nil
+}
+
+// generatedDataKeys are the build.* variables the steps publish through
+// packerbuilderdata: the build ID the temporary resources are tagged with
+// (Run), the instance details once it is ACTIVE
+// (recordInstanceData), the floating IP (stepGetIP) and the captured image
+// (stepCaptureImage).
+var generatedDataKeys = []string{
+	"BuildID",
+	"InstanceID",
+	"InstanceName",
+	"Zone",
+	"VpcID",
+	"SubnetID",
+	"PrivateIP",
+	"FloatingIP",
+	"SourceImageID",
+	"SourceImageName",
+	"ResourceGroupID",
+	"ImageID",
+	"ImageCRN",
 }
 
 // Run executes a IBMCloud Packer build and returns a packer.Artifact
 func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
 	client := IBMCloudClient{}.New(b.config.IBMApiKey)
+	ui = redact.UI(ui)
 
 	// Set up the state which is used to share state between the steps
 	state := new(multistep.BasicStateBag)
@@ -73,11 +99,43 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
-	// Set the value of the generated data that will become available to provisioners.
+	// The steps fill in the generated data that becomes available to provisioners.
 	// To share the data with post-processors, use the StateData in the artifact.
-	state.Put("generated_data", map[string]interface{}{
-		"GeneratedMockData": "mock-build-data",
+	state.Put("generated_data", map[string]interface{}{})
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("BuildID", b.config.BuildID)
+
+	// Every resource the steps create is recorded in the ledger, so one a killed
+	// build leaves behind can be deleted by the cleanup subcommand.
+	resourceLedger, err := ledger.New(b.config.ResourceLedgerDir, &ledger.Ledger{
+		Builder:  "vpc",
+		BuildID:  b.config.BuildID,
+		Region:   b.config.Region,
+		Endpoint: b.config.Endpoint,
+		IAMURL:   b.config.IAMEndpoint,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] Error creating the resource ledger: %s", err)
+	}
+	state.Put("ledger", resourceLedger)
+
+	// The key pair and any other file the steps generate are written to a
+	// private directory of the build, removed when it ends.
+	buildWorkspace, err := workspace.New(b.config.SSHKeyOutputDir, b.config.BuildID)
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] Error creating the build's working directory: %s", err)
+	}
+	defer buildWorkspace.Remove()
+	state.Put("workspace", buildWorkspace)
+
+	// Provisioning waits for cloud-init, or Cloudbase-Init, to run the user
+	// data when wait_for_cloud_init is set.
+	waitForCloudInit := &cloudinit.StepWait{
+		Enabled: b.config.WaitForCloudInit,
+		Timeout: b.config.CloudInitTimeout,
+		Windows: b.config.Comm.Type == "winrm",
+		Sudo:    b.config.Comm.Type == "ssh" && b.config.Comm.SSHUsername != "root",
+	}
 
 	// Build the steps
 	steps := []multistep.Step{}
@@ -100,9 +158,13 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 				Host:        winRMCommHost,
 				WinRMConfig: winRMConfig,
 			},
+			waitForCloudInit,
 			new(commonsteps.StepProvision),
+			new(stepGeneralize),
 			new(StepCreateVPCServiceInstance),
 			new(stepRebootInstance),
+			new(stepWinRMTeardown),
+			new(stepShutdown),
 			new(stepCaptureImage),
 		}
 	} else if b.config.Comm.Type == "ssh" {
@@ -123,9 +185,12 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 				Host:      sshCommHost,
 				SSHConfig: sshConfig,
 			},
+			waitForCloudInit,
 			new(commonsteps.StepProvision),
+			new(stepGeneralize),
 			new(StepCreateVPCServiceInstance),
 			new(stepRebootInstance),
+			new(stepShutdown),
 			new(stepCaptureImage),
 		}
 	}
@@ -133,6 +198,7 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	// Create the runner which will run the steps we just build
 	b.runner = &multistep.BasicRunner{Steps: steps}
 	b.runner.Run(ctx, state)
+	resourceLedger.Finish(ui)
 
 	// Fail the build if a step recorded an error or halted before producing an
 	// image (see buildResultError for why returning (nil, nil) here is wrong).
@@ -144,7 +210,12 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	artifact := &Artifact{
 		imageName: b.config.ImageName,
 		imageId:   state.Get("image_id").(string),
-		client:    client,
+
+		apiKey:      b.config.IBMApiKey,
+		iamEndpoint: b.config.IAMEndpoint,
+		endpoint:    b.config.Endpoint,
+		timeout:     b.config.StateTimeout,
+
 		// Add the builder generated data to the artifact StateData so that post-processors can access them.
 		StateData: map[string]interface{}{
 			"ibmApiKey":        b.config.IBMApiKey,
@@ -153,7 +224,15 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			"iam_url":          b.config.IAMEndpoint,
 			"image_id":         state.Get("image_id").(string),
 			"image_name":       b.config.ImageName,
+			"generated_data":   state.Get("generated_data"),
 		},
 	}
+	// secure_boot, confidential_compute_mode and allowed_use; see
+	// imageCapabilities.
+	if capabilities, ok := state.Get("image_capabilities").(map[string]interface{}); ok {
+		for key, value := range capabilities {
+			artifact.StateData[key] = value
+		}
+	}
 	return artifact, nil
 }
@@ -1,16 +1,22 @@
 package vpc
 
 import (
+	"crypto/rsa"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
+	"strings"
 	"time"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	globaltaggingv1 "github.com/IBM/platform-services-go-sdk/globaltaggingv1"
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
 )
 
 // defaultPollInterval is the wait between status polls when a caller does not
@@ -54,8 +60,8 @@ var capacityStatusReasonCodes = map[string]bool{
 
 // capacityError marks an instance-start failure that a different zone might
 // satisfy. stepCreateInstance uses errors.As to decide whether to fall through
-// to the next subnet.
-type capacityError struct{ msg string }
+// to the next subnet. code is the instance status-reason code.
+type capacityError struct{ msg, code string }
 
 func (e *capacityError) Error() string { return e.msg }
 
@@ -86,7 +92,7 @@ func newInstanceFailedError(reasons []vpcv1.InstanceStatusReason) error {
 	}
 	full := fmt.Sprintf("[ERROR] Instance returned failed status. Status Reason - %s: %s", code, message)
 	if isCapacity {
-		return &capacityError{msg: full}
+		return &capacityError{msg: full, code: code}
 	}
 	return fmt.Errorf("%s", full)
 }
@@ -246,8 +252,84 @@ func (client IBMCloudClient) isResourceDown(resourceID string, resourceType stri
 	return down, nil
 }
 
+func (client IBMCloudClient) waitForResourceDeleted(resourceID string, resourceType string, timeout time.Duration, state multistep.StateBag) error {
+	return client.pollUntil(resourceID, resourceType, "deleted", timeout, state, client.isResourceDeleted)
+}
+
+// isResourceDeleted reports whether the resource is gone (the API answers 404).
+// Only images are supported; they pass through "deleting" before disappearing,
+// and a "failed" image means the delete did not go through.
+func (client IBMCloudClient) isResourceDeleted(resourceID string, resourceType string, state multistep.StateBag) (bool, error) {
+	svc := vpcService(state)
+	if resourceType == "images" {
+		image, response, err := svc.GetImage(svc.NewGetImageOptions(resourceID))
+		if err != nil {
+			if response != nil && response.StatusCode == 404 {
+				return true, nil
+			}
+			return false, fmt.Errorf("[ERROR] Error occurred while getting image information. Error: %s", err)
+		}
+		if image.Status != nil && *image.Status == "failed" {
+			return false, fmt.Errorf("[ERROR] Image %s went into failed state while being deleted", resourceID)
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("[ERROR] Waiting for deletion is not supported for %s", resourceType)
+}
+
+// imageUsers returns the names of the instances whose boot image is imageID.
+// The instance list has no image filter, so every page is walked.
+func imageUsers(svc *vpcv1.VpcV1, imageID string) ([]string, error) {
+	pager, err := svc.NewInstancesPager(&vpcv1.ListInstancesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	instances, err := pager.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var users []string
+	for _, instance := range instances {
+		if instance.Image != nil && instance.Image.ID != nil && *instance.Image.ID == imageID {
+			users = append(users, *instance.Name)
+		}
+	}
+	return users, nil
+}
+
+// destroyImage deletes a VPC image and waits until the API no longer returns
+// it. An image that is still the boot image of an instance is refused rather
+// than deleted out from under it. An image that is already gone is not an error.
+func (client IBMCloudClient) destroyImage(imageID string, timeout time.Duration, state multistep.StateBag) error {
+	svc := vpcService(state)
+
+	users, err := imageUsers(svc, imageID)
+	if err != nil {
+		return fmt.Errorf("[ERROR] Error listing the instances that use image %s: %s", imageID, err)
+	}
+	if len(users) > 0 {
+		return fmt.Errorf("[ERROR] Image %s is still used by instances %v; delete them before deleting the image", imageID, users)
+	}
+
+	response, err := svc.DeleteImage(svc.NewDeleteImageOptions(imageID))
+	if err != nil {
+		if response != nil && response.StatusCode == 404 {
+			log.Printf("Image %s was already deleted or does not exist", imageID)
+			return nil
+		}
+		return fmt.Errorf("[ERROR] Error deleting image %s: %s", imageID, err)
+	}
+
+	if err := client.waitForResourceDeleted(imageID, "images", timeout, state); err != nil {
+		return fmt.Errorf("[ERROR] Error waiting for image %s to be deleted: %s", imageID, err)
+	}
+	return nil
+}
+
 // Perfomr actions (stops, reboot, etc.) over an instance
-func (client IBMCloudClient) manageInstance(resourceID string, action string, state multistep.StateBag) (string, error) {
+// manageInstance performs action on the instance, forcibly when force is set:
+// queued actions are dropped and a stop powers the instance off at once.
+func (client IBMCloudClient) manageInstance(resourceID string, action string, force bool, state multistep.StateBag) (string, error) {
 	ui := state.Get("ui").(packer.Ui)
 
 	var vpcService *vpcv1.VpcV1
@@ -260,6 +342,9 @@ func (client IBMCloudClient) manageInstance(resourceID string, action string, st
 	options := &vpcv1.CreateInstanceActionOptions{}
 	options.SetInstanceID(resourceID)
 	options.SetType(action)
+	if force {
+		options.SetForce(true)
+	}
 	response, _, err := vpcService.CreateInstanceAction(options)
 	if err != nil {
 		err := fmt.Errorf("[ERROR] Failed to perform %s action over instance. Error: %s", action, err)
@@ -307,9 +392,7 @@ func (client IBMCloudClient) createFloatingIP(state multistep.StateBag) (*vpcv1.
 	instanceResourceGroup := instanceData.ResourceGroup
 	instanceResourceGroupID := *instanceResourceGroup.ID
 
-	networkInterfaces := instanceData.NetworkInterfaces
-	instanceNetworkInterface := networkInterfaces[0]
-	networkInterfaceID := *instanceNetworkInterface.ID
+	networkInterfaceID := primaryNetworkTargetID(instanceData)
 
 	options := &vpcv1.CreateFloatingIPOptions{}
 	options.SetFloatingIPPrototype(&vpcv1.FloatingIPPrototype{
@@ -355,39 +438,53 @@ func (client IBMCloudClient) GrabCredentials(instanceID string, state multistep.
 		log.Println(err.Error())
 		return "", "", err
 	}
+	redact.Secrets(windowsPassword)
 
 	return "Administrator", windowsPassword, nil
 }
 
 // Decrypt Password - Following documentation https://cloud.ibm.com/docs/vpc?topic=vpc-vsi_is_connecting_windows
+// The password is decrypted in memory with the build's RSA private key, so
+// neither openssl nor a working file is needed.
 func (client IBMCloudClient) DecryptPassword(encryptedPwd []byte, state multistep.StateBag) (string, error) {
 	ui := state.Get("ui").(packer.Ui)
 
-	///// Step 1: Create working folder "data" and store bas64 password on data/decoded_pwd.txt
-	_ = os.Mkdir("data", 0755)
-	file, err := os.Create("data/decoded_pwd.txt")
+	pathPrivateKey := state.Get("PRIVATE_KEY").(string)
+	privateKey, err := os.ReadFile(pathPrivateKey)
 	if err != nil {
-		err := fmt.Errorf("[ERROR] Failed writing decoded password. Error: %s", err)
+		err := fmt.Errorf("[ERROR] Failed reading the private SSH key. Error: %s", err)
 		ui.Error(err.Error())
 		log.Println(err.Error())
 		return "", err
 	}
-	file.Write(encryptedPwd)
-	file.Close()
-
-	///// Step 2: Decrypt the decoded password using the RSA private key
-	pathPrivateKey := state.Get("PRIVATE_KEY").(string)
-	password, err := exec.Command("openssl", "pkeyutl", "-in", "data/decoded_pwd.txt", "-decrypt", "-inkey", pathPrivateKey).Output()
+	password, err := decryptWindowsPassword(privateKey, encryptedPwd)
 	if err != nil {
 		err := fmt.Errorf("[ERROR] Failed decrypting the decoded password. Error: %s", err)
 		ui.Error(err.Error())
 		log.Println(err.Error())
 		return "", err
 	}
+	return password, nil
+}
 
-	///// Step 3: Delete working dir
-	defer os.RemoveAll("data")
-	return string(password), err
+// decryptWindowsPassword decrypts the encrypted_password of an instance's
+// initialization with the private key whose public key was given to the
+// instance, in any format ssh_private_key_file accepts. IBM Cloud pads it with
+// PKCS #1 v1.5, as openssl pkeyutl expects by default.
+func decryptWindowsPassword(privateKey, encryptedPwd []byte) (string, error) {
+	parsed, err := ssh.ParseRawPrivateKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("reading the private key: %s", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("a %T private key cannot decrypt the password; use an RSA key", parsed)
+	}
+	password, err := rsa.DecryptPKCS1v15(nil, key, encryptedPwd)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
 }
 
 func (client IBMCloudClient) createSSHKeyVPC(state multistep.StateBag) (*vpcv1.Key, error) {
@@ -407,8 +504,9 @@ func (client IBMCloudClient) createSSHKeyVPC(state multistep.StateBag) (*vpcv1.K
 	state.Put("ssh_public_key", publicKey)
 
 	options := &vpcv1.CreateKeyOptions{}
-	if config.SshKeyType != "" && config.SshKeyType == "ed25519" {
-		options.SetType("ed25519")
+	// The type follows the key, which may be the user's own.
+	if strings.HasPrefix(publicKey, ssh.KeyAlgoED25519) {
+		options.SetType(vpcv1.KeyTypeEd25519Const)
 	}
 	options.SetName(config.VpcSshKeyName)
 	options.SetPublicKey(publicKey)
@@ -468,7 +566,59 @@ func (client IBMCloudClient) createSecurityGroup(state multistep.StateBag, secur
 	return securityGroup, nil
 }
 
-func (client IBMCloudClient) createRule(rule vpcv1.CreateSecurityGroupRuleOptions, state multistep.StateBag) (*vpcv1.SecurityGroupRuleSecurityGroupRuleProtocolTcpudp, error) {
+// attachUserTags attaches user tags to the resources with the given CRNs
+// through the Global Tagging API.
+func (client IBMCloudClient) attachUserTags(state multistep.StateBag, tags []string, crns ...string) error {
+	config := state.Get("config").(Config)
+
+	optGlbTag := globaltaggingv1.GlobalTaggingV1Options{
+		Authenticator: &core.IamAuthenticator{
+			ApiKey: client.IBMApiKey,
+			URL:    config.IAMEndpoint,
+		},
+	}
+	if config.GhostEndpoint != "" {
+		optGlbTag.URL = config.GhostEndpoint
+	}
+	taggingService, err := globaltaggingv1.NewGlobalTaggingV1(&optGlbTag)
+	if err != nil {
+		return fmt.Errorf("creating global tagging client: %s", err)
+	}
+
+	resources := []globaltaggingv1.Resource{}
+	for i := range crns {
+		resources = append(resources, globaltaggingv1.Resource{ResourceID: &crns[i]})
+	}
+	options := taggingService.NewAttachTagOptions()
+	options.SetResources(resources)
+	options.SetTagNames(tags)
+	options.SetTagType(globaltaggingv1.AttachTagOptionsTagTypeUserConst)
+	results, resp, err := taggingService.AttachTag(options)
+	if err != nil {
+		return fmt.Errorf("attaching tags %v: %s\n%s", tags, err, resp)
+	}
+	// The call succeeds as a whole even when individual resources fail.
+	for _, result := range results.Results {
+		if result.IsError != nil && *result.IsError {
+			return fmt.Errorf("attaching tags %v to %s failed", tags, stringValue(result.ResourceID))
+		}
+	}
+	return nil
+}
+
+// tagTemporaryResources attaches the build's temporary resource tags (see
+// Config.temporaryResourceTags) to resources the builder just created, for
+// those whose create API takes no user tags. The build deletes its resources
+// whether they are tagged or not, so callers only warn when this fails (e.g.
+// without Global Tagging permissions): the cleanup sweep is all it costs.
+func (client IBMCloudClient) tagTemporaryResources(state multistep.StateBag, crns ...string) error {
+	config := state.Get("config").(Config)
+	return client.attachUserTags(state, config.temporaryResourceTags(), crns...)
+}
+
+// createRule creates a security group rule of any protocol and returns its
+// ID.
+func (client IBMCloudClient) createRule(rule vpcv1.CreateSecurityGroupRuleOptions, state multistep.StateBag) (string, error) {
 	ui := state.Get("ui").(packer.Ui)
 
 	var vpcService *vpcv1.VpcV1
@@ -476,16 +626,14 @@ func (client IBMCloudClient) createRule(rule vpcv1.CreateSecurityGroupRuleOption
 		vpcService = state.Get("vpcService").(*vpcv1.VpcV1)
 	}
 
-	securityGroupRuleIntf, _, err := vpcService.CreateSecurityGroupRule(&rule)
-	securityGroupRule := securityGroupRuleIntf.(*vpcv1.SecurityGroupRuleSecurityGroupRuleProtocolTcpudp)
-
+	securityGroupRule, _, err := vpcService.CreateSecurityGroupRule(&rule)
 	if err != nil {
 		err := fmt.Errorf("[ERROR] Error sending the HTTP request that creates a Security Group's rule. Error: %s", err)
 		ui.Error(err.Error())
 		log.Println(err.Error())
-		return nil, err
+		return "", err
 	}
-	return securityGroupRule, nil
+	return securityGroupRuleID(securityGroupRule), nil
 }
 
 func (client IBMCloudClient) addNetworkInterfaceToSecurityGroup(securityGroupID string, networkInterfaceID string, state multistep.StateBag) (*vpcv1.SecurityGroupTargetReference, error) {
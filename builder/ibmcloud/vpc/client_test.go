@@ -1,9 +1,16 @@
 package vpc
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -11,6 +18,7 @@ import (
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
 )
 
 // newTestVpcService builds a vpcv1.VpcV1 pointed at an httptest server with a
@@ -224,3 +232,137 @@ func TestNewInstanceFailedError(t *testing.T) {
 		})
 	}
 }
+
+// newTestTaggingState returns a state bag whose config points the IAM and
+// Global Tagging endpoints at url.
+func newTestTaggingState(url string) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("config", Config{
+		IAMEndpoint:           url,
+		GhostEndpoint:         url,
+		BuildID:               "build-1",
+		TemporaryResourceTags: []string{"cost-center:cc1234"},
+	})
+	return state
+}
+
+// globalTaggingHandler serves an IAM token and records the attach_tag request.
+func globalTaggingHandler(t *testing.T, got *map[string]interface{}, results string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/identity/token":
+			_, _ = w.Write([]byte(`{"access_token":"token","refresh_token":"refresh","token_type":"Bearer","expires_in":3600,"expiration":4102444800}`))
+		case "/v3/tags/attach":
+			if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+				t.Errorf("decoding attach request: %s", err)
+			}
+			if tagType := r.URL.Query().Get("tag_type"); tagType != "user" {
+				t.Errorf("tag_type = %q, want user", tagType)
+			}
+			_, _ = w.Write([]byte(results))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestTagTemporaryResources(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(globalTaggingHandler(t, &got, `{"results":[{"resource_id":"crn:a","is_error":false},{"resource_id":"crn:b","is_error":false}]}`))
+	defer srv.Close()
+
+	err := IBMCloudClient{IBMApiKey: "key"}.tagTemporaryResources(newTestTaggingState(srv.URL), "crn:a", "crn:b")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tags, _ := json.Marshal(got["tag_names"])
+	if string(tags) != `["cost-center:cc1234","packer-build-id:build-1"]` {
+		t.Errorf("tag_names = %s", tags)
+	}
+	resources, _ := json.Marshal(got["resources"])
+	if string(resources) != `[{"resource_id":"crn:a"},{"resource_id":"crn:b"}]` {
+		t.Errorf("resources = %s", resources)
+	}
+}
+
+func TestTagTemporaryResourcesPartialFailure(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(globalTaggingHandler(t, &got, `{"results":[{"resource_id":"crn:a","is_error":false},{"resource_id":"crn:b","is_error":true}]}`))
+	defer srv.Close()
+
+	err := IBMCloudClient{IBMApiKey: "key"}.tagTemporaryResources(newTestTaggingState(srv.URL), "crn:a", "crn:b")
+	if err == nil || !strings.Contains(err.Error(), "crn:b") {
+		t.Fatalf("expected an error naming crn:b, got %v", err)
+	}
+}
+
+func TestDecryptWindowsPassword(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	pkcs8Der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Der})
+	// The format ssh-keygen writes by default.
+	openSSHBlock, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	openSSH := pem.EncodeToMemory(openSSHBlock)
+
+	password := []byte("Pa$$w0rd-1234")
+	v15, err := rsa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, privateKey := range map[string][]byte{
+		"pkcs1 key":   pkcs1,
+		"pkcs8 key":   pkcs8,
+		"openssh key": openSSH,
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := decryptWindowsPassword(privateKey, v15)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != string(password) {
+				t.Errorf("got %q, want %q", got, password)
+			}
+		})
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDer, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edOpenSSH, err := ssh.MarshalPrivateKey(edKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		key     []byte
+		wantErr string
+	}{
+		"not a key":           {[]byte("id_rsa"), "reading the private key"},
+		"ed25519 key":         {pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDer}), "use an RSA key"},
+		"openssh ed25519 key": {pem.EncodeToMemory(edOpenSSH), "use an RSA key"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := decryptWindowsPassword(tc.key, v15)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
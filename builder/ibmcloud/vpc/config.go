@@ -1,24 +1,35 @@
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,MetadataServiceConfig,UserDataPart,SecurityGroupRule
 package vpc
 
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"regexp"
 	"slices"
 	"time"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+	"packer-plugin-ibmcloud/builder/ibmcloud/sshkey"
+
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/shutdowncommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+	"golang.org/x/crypto/ssh"
 )
 
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 	Comm                communicator.Config `mapstructure:",squash"`
 
+	shutdowncommand.ShutdownConfig `mapstructure:",squash"`
+
 	IBMApiKey                 string   `mapstructure:"api_key"`
 	Region                    string   `mapstructure:"region"`
 	Endpoint                  string   `mapstructure:"vpc_endpoint_url"`
@@ -31,6 +42,7 @@ type Config struct {
 	SubnetID                  string   `mapstructure:"subnet_id"`
 	SubnetIDs                 []string `mapstructure:"subnet_ids"`
 	SshKeyType                string   `mapstructure:"ssh_key_type"`
+	SshKeyBits                int      `mapstructure:"ssh_key_bits"`
 	CatalogOfferingCRN        string   `mapstructure:"catalog_offering_crn"`
 	CatalogOfferingVersionCRN string   `mapstructure:"catalog_offering_version_crn"`
 	ResourceGroupID           string   `mapstructure:"resource_group_id"`
@@ -49,12 +61,73 @@ type Config struct {
 	VSIDataIops               int      `mapstructure:"vsi_data_vol_iops"`
 	VSIDataBandwidth          int      `mapstructure:"vsi_data_vol_bandwidth"`
 	VSIProfile                string   `mapstructure:"vsi_profile"`
+	VSIProfiles               []string `mapstructure:"vsi_profiles"`
 	VSIInterface              string   `mapstructure:"vsi_interface"`
 	VSIUserDataFile           string   `mapstructure:"vsi_user_data_file"`
 	VSIUserDataString         string   `mapstructure:"vsi_user_data"`
 
+	// VSIUserDataParts are assembled, in order, into multipart MIME user
+	// data, instead of vsi_user_data or vsi_user_data_file. Their contents
+	// are interpolated with build values when the instance is created (see
+	// userDataTemplateData).
+	VSIUserDataParts []UserDataPart `mapstructure:"vsi_user_data_part"`
+
+	// WinRMBootstrap generates user data enabling a WinRM HTTPS listener
+	// with a self-signed certificate, which the build connects to; the
+	// listener is removed again before capture (see stepWinRMTeardown).
+	WinRMBootstrap bool `mapstructure:"winrm_bootstrap"`
+
+	// WaitForCloudInit holds provisioning until cloud-init, or Cloudbase-Init
+	// on Windows, has run the user data, for at most CloudInitTimeout.
+	WaitForCloudInit bool          `mapstructure:"wait_for_cloud_init"`
+	CloudInitTimeout time.Duration `mapstructure:"cloud_init_timeout"`
+
+	// VSINetworkAttachment is "network_interface" to connect the instance
+	// through a legacy primary_network_interface, or
+	// "virtual_network_interface" for a primary_network_attachment with a
+	// virtual network interface (VNI) created with, and deleted with, it.
+	VSINetworkAttachment string `mapstructure:"vsi_network_attachment"`
+	// VNIProtocolStateFilteringMode is the VNI's protocol_state_filtering_mode.
+	VNIProtocolStateFilteringMode string `mapstructure:"vni_protocol_state_filtering_mode"`
+
+	// EnableSecureBoot and ConfidentialComputeMode are set on the builder
+	// instance; unset, the vsi_profile's defaults apply.
+	EnableSecureBoot        *bool  `mapstructure:"enable_secure_boot"`
+	ConfidentialComputeMode string `mapstructure:"confidential_compute_mode"`
+
+	// MetadataService configures the builder instance's metadata service, from
+	// which provisioners can read an IAM token for the trusted profile below.
+	MetadataService MetadataServiceConfig `mapstructure:"metadata_service"`
+	// TrustedProfileID or TrustedProfileName is the default trusted profile of
+	// the builder instance. A name is resolved to an ID in stepVerifyInput.
+	TrustedProfileID   string `mapstructure:"trusted_profile_id"`
+	TrustedProfileName string `mapstructure:"trusted_profile_name"`
+
+	// DedicatedHostID, DedicatedHostGroupID or PlacementGroupID is the
+	// builder instance's placement target. A dedicated host or group pins the
+	// build to its zone; subnets in other zones are not used.
+	DedicatedHostID      string `mapstructure:"dedicated_host_id"`
+	DedicatedHostGroupID string `mapstructure:"dedicated_host_group_id"`
+	PlacementGroupID     string `mapstructure:"placement_group_id"`
+	// ReservationAffinityPolicy is "automatic", "disabled" or "manual". With
+	// "manual", the instance is started from the capacity of ReservationID,
+	// which also pins the build to the reservation's zone.
+	ReservationID             string `mapstructure:"reservation_id"`
+	ReservationAffinityPolicy string `mapstructure:"reservation_affinity_policy"`
+
 	ImageName string   `mapstructure:"image_name"`
 	ImageTags []string `mapstructure:"tags"`
+	// KeepImageOnFailure leaves an image captured by a build that later halts or
+	// is cancelled in the account instead of deleting it, for debugging.
+	KeepImageOnFailure bool `mapstructure:"keep_image_on_failure"`
+
+	// Generalize is the built-in profile, "linux" or "windows", run by
+	// stepGeneralize to strip the instance's identity before its image is
+	// captured. GeneralizeCommands run before it, also with no profile set.
+	// GeneralizeUnattendFile is the unattend file sysprep is run with.
+	Generalize             string   `mapstructure:"generalize"`
+	GeneralizeCommands     []string `mapstructure:"generalize_commands"`
+	GeneralizeUnattendFile string   `mapstructure:"generalize_unattend_file"`
 
 	// Security Group Rule Configuration
 	SkipCreateDefaultSecurityGroupRule bool     `mapstructure:"skip_create_default_security_group_rule"`
@@ -62,10 +135,46 @@ type Config struct {
 	SecurityGroupRuleRemoteAddress     []string `mapstructure:"security_group_rule_remote_address"`
 	SecurityGroupRuleRemoteID          []string `mapstructure:"security_group_rule_remote_id"`
 
+	// Without security_group_rule_remote_*, the rule is opened to
+	// TemporarySecurityGroupSourceCIDRs, else to the build host's public IP
+	// as answered by PublicIPDiscoveryURL with
+	// TemporarySecurityGroupSourcePublicIP, else to the ssh_bastion_host
+	// (see securityGroupRemotes).
+	TemporarySecurityGroupSourceCIDRs    []string `mapstructure:"temporary_security_group_source_cidrs"`
+	TemporarySecurityGroupSourcePublicIP bool     `mapstructure:"temporary_security_group_source_public_ip"`
+	PublicIPDiscoveryURL                 string   `mapstructure:"public_ip_discovery_url"`
+	// SecurityGroupRules are created in the security group alongside the
+	// communicator's rule, and deleted again when the build ends.
+	SecurityGroupRules []SecurityGroupRule `mapstructure:"security_group_rule"`
+
+	// TemporaryResourceTags are user tags attached to every resource the
+	// builder creates and deletes again (instance, volumes, virtual network
+	// interface, floating IP, SSH key and security group), alongside a packer-build-id tag naming the run.
+	TemporaryResourceTags []string `mapstructure:"temporary_resource_tags"`
+	// TemporaryResourceNamePrefix starts the names of those resources.
+	TemporaryResourceNamePrefix string `mapstructure:"temporary_resource_name_prefix"`
+
+	// SshKeyID or SshKeyName name an existing VPC key whose private key is
+	// the communicator's ssh_private_key_file; the build then neither
+	// generates nor uploads a key. ExtraSshKeyIDs are existing keys injected
+	// into the instance as well.
+	SshKeyID       string   `mapstructure:"vpc_ssh_key_id"`
+	SshKeyName     string   `mapstructure:"vpc_ssh_key_name"`
+	ExtraSshKeyIDs []string `mapstructure:"vpc_ssh_key_ids"`
+
+	// ResourceLedgerDir is where the build records the resources it creates
+	// (see package ledger).
+	ResourceLedgerDir string `mapstructure:"resource_ledger_dir"`
+	// SSHKeyOutputDir keeps the SSH key pair the build generates, in a
+	// directory named after the build ID (see package workspace).
+	SSHKeyOutputDir string `mapstructure:"ssh_key_output_dir"`
+
+	BuildID           string `mapstructure-to-hcl2:",skip"`
 	VSIName           string `mapstructure-to-hcl2:",skip"`
 	VpcSshKeyName     string `mapstructure-to-hcl2:",skip"`
 	SecurityGroupName string `mapstructure-to-hcl2:",skip"`
 	FloatingIPName    string `mapstructure-to-hcl2:",skip"`
+	VNIName           string `mapstructure-to-hcl2:",skip"`
 
 	RawStateTimeout string              `mapstructure:"timeout"`
 	StateTimeout    time.Duration       `mapstructure-to-hcl2:",skip"`
@@ -83,12 +192,78 @@ type Config struct {
 	VPCLog     string `mapstructure:"logging"`
 }
 
+// MetadataServiceConfig is the metadata_service block.
+type MetadataServiceConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Protocol is "http" or "https"; IBM Cloud defaults to http.
+	Protocol string `mapstructure:"protocol"`
+	// ResponseHopLimit is the IP hop limit of the responses, 1 to 64.
+	ResponseHopLimit int `mapstructure:"response_hop_limit"`
+}
+
+// UserDataPart is a vsi_user_data_part block.
+type UserDataPart struct {
+	// ContentType is "cloud-config", "shell-script" or "powershell".
+	ContentType string `mapstructure:"content_type"`
+	// Content or File is the part.
+	Content string `mapstructure:"content"`
+	File    string `mapstructure:"file"`
+}
+
+// SecurityGroupRule is a security_group_rule block.
+type SecurityGroupRule struct {
+	// Direction is "inbound", the default, or "outbound".
+	Direction string `mapstructure:"direction"`
+	// Protocol is "tcp", "udp", "icmp" or "all".
+	Protocol string `mapstructure:"protocol"`
+	// PortMin and PortMax are the range of tcp or udp ports, all of them when
+	// unset. PortMax defaults to PortMin.
+	PortMin int `mapstructure:"port_min"`
+	PortMax int `mapstructure:"port_max"`
+	// ICMPType and ICMPCode are the icmp traffic allowed, all of it when
+	// unset.
+	ICMPType *int `mapstructure:"icmp_type"`
+	ICMPCode *int `mapstructure:"icmp_code"`
+	// Remote is the CIDR block, IP address or security group ID the traffic
+	// comes from or goes to. An inbound rule defaults to the sources of the
+	// communicator's rule (see securityGroupRemotes), an outbound one to
+	// 0.0.0.0/0.
+	Remote string `mapstructure:"remote"`
+}
+
+// Values of vsi_network_attachment.
+const (
+	networkAttachmentInterface = "network_interface"
+	networkAttachmentVNI       = "virtual_network_interface"
+)
+
+// reservationAffinityManual is the reservation_affinity_policy that pins the
+// builder instance to reservation_id.
+const reservationAffinityManual = "manual"
+
+// vpcKeyBits are the key types and sizes the VPC key API accepts.
+var vpcKeyBits = map[string][]int{sshkey.RSA: {2048, 4096}, sshkey.ED25519: {256}}
+
+var (
+	temporaryResourceNamePrefixRegexp = regexp.MustCompile(`^[a-z][-a-z0-9]*$`)
+	userTagRegexp                     = regexp.MustCompile(`^[A-Za-z0-9 _\-.:]{1,128}$`)
+)
+
+// temporaryResourceTags returns the user tags attached to the resources the
+// builder creates for the build: temporary_resource_tags and packer-build-id.
+func (c *Config) temporaryResourceTags() []string {
+	return append(slices.Clone(c.TemporaryResourceTags), buildIDTagPrefix+c.BuildID)
+}
+
 // Prepare processes the build configuration parameters.
 func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	err := config.Decode(c, &config.DecodeOpts{
 		Interpolate:        true,
 		InterpolateContext: &c.ctx,
-		InterpolateFilter:  &interpolate.RenderFilter{},
+		InterpolateFilter: &interpolate.RenderFilter{
+			// Rendered when the instance is created, with build values.
+			Exclude: []string{"vsi_user_data_part"},
+		},
 	}, raws...)
 
 	if err != nil {
@@ -99,11 +274,25 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 
 	// Check for required configurations that will display errors if not specified
 	var errs *packer.MultiError
+	// winrm_bootstrap connects to the HTTPS listener it enables, whose
+	// certificate is self-signed.
+	if c.WinRMBootstrap {
+		if c.Comm.Type != "winrm" {
+			errs = packer.MultiErrorAppend(errs, errors.New("winrm_bootstrap requires the winrm communicator"))
+		}
+		if c.Comm.WinRMPort != 0 && c.Comm.WinRMPort != winRMBootstrapPort {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("winrm_port must be %d with winrm_bootstrap", winRMBootstrapPort))
+		}
+		c.Comm.WinRMUseSSL = true
+		c.Comm.WinRMInsecure = true
+	}
 	errs = packer.MultiErrorAppend(errs, c.Comm.Prepare(&c.ctx)...)
+	errs = packer.MultiErrorAppend(errs, c.ShutdownConfig.Prepare(&c.ctx)...)
 
 	if c.IBMApiKey == "" {
 		errs = packer.MultiErrorAppend(errs, errors.New("an ibm_api_key must be specified"))
 	}
+	redact.Secrets(c.IBMApiKey, c.Comm.SSHPassword, c.Comm.WinRMPassword)
 
 	if c.Region == "" {
 		errs = packer.MultiErrorAppend(errs, errors.New("a region must be specified"))
@@ -230,14 +419,96 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		errs = packer.MultiErrorAppend(errs, errors.New("only one of (vsi_base_image_id or vsi_base_image_name) or (catalog_offering_crn or catalog_offering_version_crn) or vsi_boot_volume_id or vsi_boot_snapshot_id is required"))
 	}
 
-	if c.VSIProfile == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("a vsi_profile must be specified"))
+	// Exactly one of vsi_profile / vsi_profiles. vsi_profiles is the fallback
+	// form: on a capacity failure the builder tries every subnet with the first
+	// profile, then every subnet with the next one, and so on.
+	switch {
+	case len(c.VSIProfiles) > 0 && c.VSIProfile != "":
+		errs = packer.MultiErrorAppend(errs, errors.New("only one of vsi_profile or vsi_profiles can be specified"))
+	case len(c.VSIProfiles) == 0 && c.VSIProfile == "":
+		errs = packer.MultiErrorAppend(errs, errors.New("a vsi_profile or vsi_profiles must be specified"))
+	}
+	for _, profile := range c.VSIProfiles {
+		if profile == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("vsi_profiles must not contain empty entries"))
+			break
+		}
+	}
+	// Normalize to the list form, as for subnet_ids: the build steps read
+	// VSIProfiles exclusively.
+	if len(c.VSIProfiles) == 0 && c.VSIProfile != "" {
+		c.VSIProfiles = []string{c.VSIProfile}
 	}
 
 	if c.VSIInterface == "" {
 		c.VSIInterface = "public"
 	}
 
+	if c.VSINetworkAttachment == "" {
+		c.VSINetworkAttachment = networkAttachmentInterface
+	}
+	if c.VSINetworkAttachment != networkAttachmentInterface && c.VSINetworkAttachment != networkAttachmentVNI {
+		errs = packer.MultiErrorAppend(errs, errors.New("vsi_network_attachment must be one of: network_interface, virtual_network_interface"))
+	}
+	if c.ConfidentialComputeMode != "" && !slices.Contains([]string{"disabled", "sgx", "tdx"}, c.ConfidentialComputeMode) {
+		errs = packer.MultiErrorAppend(errs, errors.New("confidential_compute_mode must be one of: disabled, sgx, tdx"))
+	}
+
+	if c.MetadataService.Protocol != "" && c.MetadataService.Protocol != "http" && c.MetadataService.Protocol != "https" {
+		errs = packer.MultiErrorAppend(errs, errors.New("metadata_service protocol must be one of: http, https"))
+	}
+	if c.MetadataService.ResponseHopLimit != 0 && (c.MetadataService.ResponseHopLimit < 1 || c.MetadataService.ResponseHopLimit > 64) {
+		errs = packer.MultiErrorAppend(errs, errors.New("metadata_service response_hop_limit must be between 1 and 64"))
+	}
+	if !c.MetadataService.Enabled && (c.MetadataService.Protocol != "" || c.MetadataService.ResponseHopLimit != 0) {
+		errs = packer.MultiErrorAppend(errs, errors.New("metadata_service protocol and response_hop_limit require enabled to be true"))
+	}
+	if c.TrustedProfileID != "" && c.TrustedProfileName != "" {
+		errs = packer.MultiErrorAppend(errs, errors.New("only one of trusted_profile_id or trusted_profile_name can be specified"))
+	}
+	// The profile's IAM tokens are only handed out by the metadata service, so
+	// a profile without it would be unusable from the instance.
+	if (c.TrustedProfileID != "" || c.TrustedProfileName != "") && !c.MetadataService.Enabled {
+		errs = packer.MultiErrorAppend(errs, errors.New("trusted_profile_id/trusted_profile_name require metadata_service to be enabled"))
+	}
+
+	placementTargets := 0
+	for _, id := range []string{c.DedicatedHostID, c.DedicatedHostGroupID, c.PlacementGroupID} {
+		if id != "" {
+			placementTargets++
+		}
+	}
+	if placementTargets > 1 {
+		errs = packer.MultiErrorAppend(errs, errors.New("only one of dedicated_host_id, dedicated_host_group_id or placement_group_id can be specified"))
+	}
+	// A reservation is only ever used through a manual policy, so it is the
+	// default when one is given.
+	if c.ReservationID != "" && c.ReservationAffinityPolicy == "" {
+		c.ReservationAffinityPolicy = reservationAffinityManual
+	}
+	if c.ReservationAffinityPolicy != "" && !slices.Contains([]string{"automatic", "disabled", reservationAffinityManual}, c.ReservationAffinityPolicy) {
+		errs = packer.MultiErrorAppend(errs, errors.New("reservation_affinity_policy must be one of: automatic, disabled, manual"))
+	}
+	if c.ReservationAffinityPolicy == reservationAffinityManual && c.ReservationID == "" {
+		errs = packer.MultiErrorAppend(errs, errors.New("reservation_affinity_policy 'manual' requires a reservation_id"))
+	}
+	if c.ReservationID != "" && c.ReservationAffinityPolicy != reservationAffinityManual {
+		errs = packer.MultiErrorAppend(errs, errors.New("reservation_id requires reservation_affinity_policy to be 'manual'"))
+	}
+	// Instances on dedicated hosts cannot consume reservations.
+	if c.ReservationID != "" && (c.DedicatedHostID != "" || c.DedicatedHostGroupID != "") {
+		errs = packer.MultiErrorAppend(errs, errors.New("reservation_id cannot be combined with dedicated_host_id or dedicated_host_group_id"))
+	}
+
+	if c.VNIProtocolStateFilteringMode != "" {
+		if c.VSINetworkAttachment != networkAttachmentVNI {
+			errs = packer.MultiErrorAppend(errs, errors.New("vni_protocol_state_filtering_mode requires vsi_network_attachment to be 'virtual_network_interface'"))
+		}
+		if !slices.Contains([]string{"auto", "enabled", "disabled"}, c.VNIProtocolStateFilteringMode) {
+			errs = packer.MultiErrorAppend(errs, errors.New("vni_protocol_state_filtering_mode must be one of: auto, enabled, disabled"))
+		}
+	}
+
 	// Check for mutual exclusion of User data input via file or as a string.
 	if c.VSIUserDataFile != "" && c.VSIUserDataString != "" {
 		errs = packer.MultiErrorAppend(
@@ -251,6 +522,25 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
+	if len(c.VSIUserDataParts) > 0 && (c.VSIUserDataFile != "" || c.VSIUserDataString != "") {
+		errs = packer.MultiErrorAppend(errs, errors.New("vsi_user_data_part cannot be combined with vsi_user_data or vsi_user_data_file"))
+	}
+	if c.WinRMBootstrap && (c.VSIUserDataFile != "" || c.VSIUserDataString != "") {
+		errs = packer.MultiErrorAppend(errs, errors.New("winrm_bootstrap cannot be combined with vsi_user_data or vsi_user_data_file: use vsi_user_data_part blocks, which run after the bootstrap"))
+	}
+	for i, part := range c.VSIUserDataParts {
+		if _, ok := userDataPartTypes[part.ContentType]; !ok {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("vsi_user_data_part %d: content_type must be one of cloud-config, shell-script, powershell, not '%s'", i+1, part.ContentType))
+		}
+		if (part.Content == "") == (part.File == "") {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("vsi_user_data_part %d: exactly one of content or file must be set", i+1))
+		} else if part.File != "" {
+			if _, err := os.Stat(part.File); err != nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("vsi_user_data_part %d: %s", i+1, err))
+			}
+		}
+	}
+
 	if c.ImageName == "" {
 		c.ImageName = fmt.Sprintf("packer-vpc-%d", currentTime.Unix())
 	}
@@ -265,6 +555,111 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
+	// The key pair is generated unless the user brings a private key of
+	// their own, which is uploaded as the temporary key unless it belongs to
+	// an existing VPC key. Either way the key must be one the VPC key API
+	// takes, and for Windows an RSA key: the Administrator password is
+	// encrypted with the public key.
+	if c.Comm.SSHPrivateKeyFile == "" {
+		keyType, bits, err := sshkey.Check(c.SshKeyType, c.SshKeyBits, vpcKeyBits)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		} else {
+			c.SshKeyType, c.SshKeyBits = keyType, bits
+			if c.Comm.Type == "winrm" && keyType != sshkey.RSA {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("ssh_key_type '%s' cannot be used with the winrm communicator: the Windows Administrator password can only be decrypted with an rsa key", keyType))
+			}
+		}
+	} else {
+		if c.SshKeyType != "" || c.SshKeyBits != 0 {
+			errs = packer.MultiErrorAppend(errs, errors.New("ssh_key_type and ssh_key_bits cannot be set with ssh_private_key_file: no key pair is generated"))
+		}
+		if privateKey, err := c.Comm.ReadSSHPrivateKeyFile(); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		} else if signer, err := ssh.ParsePrivateKey(privateKey); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("ssh_private_key_file %s is not a usable private key: %s", c.Comm.SSHPrivateKeyFile, err))
+		} else {
+			keyType, bits := sshkey.TypeAndBits(signer.PublicKey())
+			if c.SshKeyID == "" && c.SshKeyName == "" {
+				if keyType == "" {
+					errs = packer.MultiErrorAppend(errs, fmt.Errorf("ssh_private_key_file %s cannot be uploaded as a VPC key: it is a %s key", c.Comm.SSHPrivateKeyFile, signer.PublicKey().Type()))
+				} else if _, _, err := sshkey.Check(keyType, bits, vpcKeyBits); err != nil {
+					errs = packer.MultiErrorAppend(errs, fmt.Errorf("ssh_private_key_file %s cannot be uploaded as a VPC key: %s", c.Comm.SSHPrivateKeyFile, err))
+				}
+			}
+			if c.Comm.Type == "winrm" && keyType != sshkey.RSA {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("ssh_private_key_file %s cannot be used with the winrm communicator: the Windows Administrator password can only be decrypted with an rsa key", c.Comm.SSHPrivateKeyFile))
+			}
+		}
+	}
+	if c.SshKeyID != "" && c.SshKeyName != "" {
+		errs = packer.MultiErrorAppend(errs, errors.New("only one of vpc_ssh_key_id or vpc_ssh_key_name can be specified"))
+	}
+	if (c.SshKeyID != "" || c.SshKeyName != "") && c.Comm.SSHPrivateKeyFile == "" {
+		errs = packer.MultiErrorAppend(errs, errors.New("ssh_private_key_file must be set with vpc_ssh_key_id or vpc_ssh_key_name: the builder connects with the existing key's private key"))
+	}
+	if slices.Contains(c.ExtraSshKeyIDs, "") {
+		errs = packer.MultiErrorAppend(errs, errors.New("vpc_ssh_key_ids cannot contain empty entries"))
+	}
+
+	// The linux profile runs through ssh and the windows one through winrm,
+	// as do generalize_commands.
+	switch c.Generalize {
+	case "":
+	case generalizeLinux:
+		if c.Comm.Type != "ssh" {
+			errs = packer.MultiErrorAppend(errs, errors.New("generalize 'linux' requires the ssh communicator"))
+		}
+	case generalizeWindows:
+		if c.Comm.Type != "winrm" {
+			errs = packer.MultiErrorAppend(errs, errors.New("generalize 'windows' requires the winrm communicator"))
+		}
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("generalize must be 'linux' or 'windows', not '%s'", c.Generalize))
+	}
+	if len(c.GeneralizeCommands) > 0 && c.Comm.Type != "ssh" && c.Comm.Type != "winrm" {
+		errs = packer.MultiErrorAppend(errs, errors.New("generalize_commands require the ssh or winrm communicator"))
+	}
+	if c.GeneralizeUnattendFile != "" {
+		if c.Generalize != generalizeWindows {
+			errs = packer.MultiErrorAppend(errs, errors.New("generalize_unattend_file can only be set with generalize 'windows'"))
+		} else if _, err := os.Stat(c.GeneralizeUnattendFile); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("generalize_unattend_file: %s", err))
+		}
+	}
+
+	if c.CloudInitTimeout == 0 {
+		c.CloudInitTimeout = 30 * time.Minute
+	}
+	if c.WaitForCloudInit && c.Comm.Type != "ssh" && c.Comm.Type != "winrm" {
+		errs = packer.MultiErrorAppend(errs, errors.New("wait_for_cloud_init requires the ssh or winrm communicator"))
+	}
+
+	for _, cidr := range c.TemporarySecurityGroupSourceCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("temporary_security_group_source_cidrs entry '%s' is not a CIDR block", cidr))
+		}
+	}
+	if c.TemporarySecurityGroupSourcePublicIP {
+		if len(c.SecurityGroupRuleRemoteCIDR)+len(c.SecurityGroupRuleRemoteAddress)+len(c.SecurityGroupRuleRemoteID)+len(c.TemporarySecurityGroupSourceCIDRs) > 0 {
+			errs = packer.MultiErrorAppend(errs, errors.New("temporary_security_group_source_public_ip cannot be combined with security_group_rule_remote_* or temporary_security_group_source_cidrs"))
+		}
+		if c.Comm.Type == "ssh" && c.Comm.SSHBastionHost != "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("temporary_security_group_source_public_ip cannot be used with ssh_bastion_host: the instance is reached from the bastion host"))
+		}
+	}
+	if c.SkipCreateDefaultSecurityGroupRule && (c.TemporarySecurityGroupSourcePublicIP || len(c.TemporarySecurityGroupSourceCIDRs) > 0) {
+		errs = packer.MultiErrorAppend(errs, errors.New("temporary_security_group_source_public_ip and temporary_security_group_source_cidrs cannot be set with skip_create_default_security_group_rule"))
+	}
+	for i := range c.SecurityGroupRules {
+		for _, err := range c.SecurityGroupRules[i].prepare() {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("security_group_rule %d: %s", i+1, err))
+		}
+	}
+	if c.PublicIPDiscoveryURL == "" {
+		c.PublicIPDiscoveryURL = defaultPublicIPDiscoveryURL
+	}
+
 	if c.RawStateTimeout == "" {
 		c.RawStateTimeout = "2m"
 	}
@@ -276,13 +671,45 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 	c.StateTimeout = StateTimeout
 
-	// Naming temporary infrastructure created during packer execution
-	UniqueID := "packer-vpc"
+	// Naming temporary infrastructure created during packer execution. The
+	// longest name, <prefix>-security-group-<nanoseconds>, must fit the 63
+	// characters VPC allows.
+	if c.TemporaryResourceNamePrefix == "" {
+		c.TemporaryResourceNamePrefix = "packer-vpc"
+	}
+	if !temporaryResourceNamePrefixRegexp.MatchString(c.TemporaryResourceNamePrefix) || len(c.TemporaryResourceNamePrefix) > 28 {
+		errs = packer.MultiErrorAppend(errs, errors.New("temporary_resource_name_prefix must start with a lowercase letter, contain only lowercase letters, digits and hyphens, and be at most 28 characters"))
+	}
 	timestamp := time.Now().UnixNano()
-	c.VSIName = fmt.Sprintf("%s-vsi-%d", UniqueID, timestamp)
-	c.VpcSshKeyName = fmt.Sprintf("%s-ssh-key-%d", UniqueID, timestamp)
-	c.SecurityGroupName = fmt.Sprintf("%s-security-group-%d", UniqueID, timestamp)
-	c.FloatingIPName = fmt.Sprintf("%s-floating-ip-%d", UniqueID, timestamp)
+	c.VSIName = fmt.Sprintf("%s-vsi-%d", c.TemporaryResourceNamePrefix, timestamp)
+	c.VpcSshKeyName = fmt.Sprintf("%s-ssh-key-%d", c.TemporaryResourceNamePrefix, timestamp)
+	c.SecurityGroupName = fmt.Sprintf("%s-security-group-%d", c.TemporaryResourceNamePrefix, timestamp)
+	c.FloatingIPName = fmt.Sprintf("%s-floating-ip-%d", c.TemporaryResourceNamePrefix, timestamp)
+	c.VNIName = fmt.Sprintf("%s-vni-%d", c.TemporaryResourceNamePrefix, timestamp)
+
+	for _, tag := range c.TemporaryResourceTags {
+		if !userTagRegexp.MatchString(tag) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("temporary_resource_tags entry %q is not a valid tag: use at most 128 letters, digits, spaces, and the characters _ - . :", tag))
+		}
+	}
+	// Every temporary resource is also tagged with the build's ID, so one left
+	// behind by a crashed or interrupted run can be traced back to it.
+	c.BuildID = uuid.TimeOrderedUUID()
+	if c.ResourceLedgerDir == "" {
+		c.ResourceLedgerDir = ledger.DefaultDir()
+	}
+
+	// The parts are assembled again when the instance is created, with the
+	// build's public key and zone, which only add a few hundred bytes; a
+	// single vsi_user_data or vsi_user_data_file must fit the limit as is.
+	if errs == nil || len(errs.Errors) == 0 {
+		data := userDataTemplateData{BuildID: c.BuildID, InstanceName: c.VSIName, ImageName: c.ImageName}
+		if userData, err := buildUserData(c, data); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		} else if userData != nil && len(*userData) > maxUserDataSize {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("the user data is %d bytes, more than the %d VPC accepts", len(*userData), maxUserDataSize))
+		}
+	}
 
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
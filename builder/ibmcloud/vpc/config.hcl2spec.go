@@ -10,109 +10,144 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName                    *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType                  *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion                  *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug                        *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce                        *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError                      *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars                     map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars                []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	Type                               *string           `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect                 *string           `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                            *string           `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                            *int              `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername                        *string           `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword                        *string           `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName                     *string           `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName            *string           `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType            *string           `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits            *int              `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                         []string          `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys             *bool             `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos                        []string          `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile                  *string           `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile                 *string           `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                             *bool             `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                         *string           `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout                     *string           `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth                       *bool             `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding          *bool             `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts               *int              `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost                     *string           `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort                     *int              `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth                *bool             `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername                 *string           `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword                 *string           `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive              *bool             `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile           *string           `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile          *string           `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod              *string           `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost                       *string           `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort                       *int              `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername                   *string           `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword                   *string           `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval               *string           `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout                *string           `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels                   []string          `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels                    []string          `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey                       []byte            `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey                      []byte            `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                          *string           `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword                      *string           `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                          *string           `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy                       *bool             `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                          *int              `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout                       *string           `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL                        *bool             `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure                      *bool             `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM                       *bool             `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	IBMApiKey                          *string           `mapstructure:"api_key" cty:"api_key" hcl:"api_key"`
-	Region                             *string           `mapstructure:"region" cty:"region" hcl:"region"`
-	Endpoint                           *string           `mapstructure:"vpc_endpoint_url" cty:"vpc_endpoint_url" hcl:"vpc_endpoint_url"`
-	RCEndpoint                         *string           `mapstructure:"rc_endpoint_url" cty:"rc_endpoint_url" hcl:"rc_endpoint_url"`
-	GhostEndpoint                      *string           `mapstructure:"ghost_endpoint_url" cty:"ghost_endpoint_url" hcl:"ghost_endpoint_url"`
-	EncryptionKeyCRN                   *string           `mapstructure:"encryption_key_crn" cty:"encryption_key_crn" hcl:"encryption_key_crn"`
-	IAMEndpoint                        *string           `mapstructure:"iam_url" cty:"iam_url" hcl:"iam_url"`
-	SubnetID                           *string           `mapstructure:"subnet_id" cty:"subnet_id" hcl:"subnet_id"`
-	SubnetIDs                          []string          `mapstructure:"subnet_ids" cty:"subnet_ids" hcl:"subnet_ids"`
-	SshKeyType                         *string           `mapstructure:"ssh_key_type" cty:"ssh_key_type" hcl:"ssh_key_type"`
-	CatalogOfferingCRN                 *string           `mapstructure:"catalog_offering_crn" cty:"catalog_offering_crn" hcl:"catalog_offering_crn"`
-	CatalogOfferingVersionCRN          *string           `mapstructure:"catalog_offering_version_crn" cty:"catalog_offering_version_crn" hcl:"catalog_offering_version_crn"`
-	ResourceGroupID                    *string           `mapstructure:"resource_group_id" cty:"resource_group_id" hcl:"resource_group_id"`
-	ResourceGroupName                  *string           `mapstructure:"resource_group_name" cty:"resource_group_name" hcl:"resource_group_name"`
-	SecurityGroupID                    *string           `mapstructure:"security_group_id" cty:"security_group_id" hcl:"security_group_id"`
-	VSIBaseImageID                     *string           `mapstructure:"vsi_base_image_id" cty:"vsi_base_image_id" hcl:"vsi_base_image_id"`
-	VSIBaseImageName                   *string           `mapstructure:"vsi_base_image_name" cty:"vsi_base_image_name" hcl:"vsi_base_image_name"`
-	VSIBootCapacity                    *int              `mapstructure:"vsi_boot_vol_capacity" cty:"vsi_boot_vol_capacity" hcl:"vsi_boot_vol_capacity"`
-	VSIBootProfile                     *string           `mapstructure:"vsi_boot_vol_profile" cty:"vsi_boot_vol_profile" hcl:"vsi_boot_vol_profile"`
-	VSIBootIops                        *int              `mapstructure:"vsi_boot_vol_iops" cty:"vsi_boot_vol_iops" hcl:"vsi_boot_vol_iops"`
-	VSIBootBandwidth                   *int              `mapstructure:"vsi_boot_vol_bandwidth" cty:"vsi_boot_vol_bandwidth" hcl:"vsi_boot_vol_bandwidth"`
-	VSIBootVolumeID                    *string           `mapstructure:"vsi_boot_volume_id" cty:"vsi_boot_volume_id" hcl:"vsi_boot_volume_id"`
-	VSIBootSnapshotID                  *string           `mapstructure:"vsi_boot_snapshot_id" cty:"vsi_boot_snapshot_id" hcl:"vsi_boot_snapshot_id"`
-	VSIDataCapacity                    *int              `mapstructure:"vsi_data_vol_capacity" cty:"vsi_data_vol_capacity" hcl:"vsi_data_vol_capacity"`
-	VSIDataProfile                     *string           `mapstructure:"vsi_data_vol_profile" cty:"vsi_data_vol_profile" hcl:"vsi_data_vol_profile"`
-	VSIDataIops                        *int              `mapstructure:"vsi_data_vol_iops" cty:"vsi_data_vol_iops" hcl:"vsi_data_vol_iops"`
-	VSIDataBandwidth                   *int              `mapstructure:"vsi_data_vol_bandwidth" cty:"vsi_data_vol_bandwidth" hcl:"vsi_data_vol_bandwidth"`
-	VSIProfile                         *string           `mapstructure:"vsi_profile" cty:"vsi_profile" hcl:"vsi_profile"`
-	VSIInterface                       *string           `mapstructure:"vsi_interface" cty:"vsi_interface" hcl:"vsi_interface"`
-	VSIUserDataFile                    *string           `mapstructure:"vsi_user_data_file" cty:"vsi_user_data_file" hcl:"vsi_user_data_file"`
-	VSIUserDataString                  *string           `mapstructure:"vsi_user_data" cty:"vsi_user_data" hcl:"vsi_user_data"`
-	ImageName                          *string           `mapstructure:"image_name" cty:"image_name" hcl:"image_name"`
-	ImageTags                          []string          `mapstructure:"tags" cty:"tags" hcl:"tags"`
-	SkipCreateDefaultSecurityGroupRule *bool             `mapstructure:"skip_create_default_security_group_rule" cty:"skip_create_default_security_group_rule" hcl:"skip_create_default_security_group_rule"`
-	SecurityGroupRuleRemoteCIDR        []string          `mapstructure:"security_group_rule_remote_cidr" cty:"security_group_rule_remote_cidr" hcl:"security_group_rule_remote_cidr"`
-	SecurityGroupRuleRemoteAddress     []string          `mapstructure:"security_group_rule_remote_address" cty:"security_group_rule_remote_address" hcl:"security_group_rule_remote_address"`
-	SecurityGroupRuleRemoteID          []string          `mapstructure:"security_group_rule_remote_id" cty:"security_group_rule_remote_id" hcl:"security_group_rule_remote_id"`
-	RawStateTimeout                    *string           `mapstructure:"timeout" cty:"timeout" hcl:"timeout"`
-	ImageID                            *string           `mapstructure:"image_id" cty:"image_id" hcl:"image_id"`
-	ImageExportJobName                 *string           `mapstructure:"image_export_job_name" cty:"image_export_job_name" hcl:"image_export_job_name"`
-	ExportTimeout                      *string           `mapstructure:"export_timeout" cty:"export_timeout" hcl:"export_timeout"`
-	StorageBucketName                  *string           `mapstructure:"storage_bucket_name" cty:"storage_bucket_name" hcl:"storage_bucket_name"`
-	StorageBucketCRN                   *string           `mapstructure:"storage_bucket_crn" cty:"storage_bucket_crn" hcl:"storage_bucket_crn"`
-	Format                             *string           `mapstructure:"format" cty:"format" hcl:"format"`
-	SkipReboot                         *bool             `mapstructure:"skip_reboot" cty:"skip_reboot" hcl:"skip_reboot"`
-	VPCLog                             *string           `mapstructure:"logging" cty:"logging" hcl:"logging"`
+	PackerBuildName                      *string                    `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType                    *string                    `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion                    *string                    `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug                          *bool                      `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce                          *bool                      `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError                        *string                    `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars                       map[string]string          `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars                  []string                   `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	Type                                 *string                    `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect                   *string                    `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                              *string                    `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                              *int                       `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername                          *string                    `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword                          *string                    `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName                       *string                    `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName              *string                    `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType              *string                    `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits              *int                       `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                           []string                   `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys               *bool                      `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos                          []string                   `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile                    *string                    `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile                   *string                    `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                               *bool                      `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                           *string                    `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout                       *string                    `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth                         *bool                      `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding            *bool                      `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts                 *int                       `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost                       *string                    `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort                       *int                       `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth                  *bool                      `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername                   *string                    `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword                   *string                    `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive                *bool                      `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile             *string                    `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile            *string                    `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod                *string                    `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost                         *string                    `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort                         *int                       `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername                     *string                    `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword                     *string                    `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval                 *string                    `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout                  *string                    `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels                     []string                   `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels                      []string                   `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey                         []byte                     `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey                        []byte                     `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                            *string                    `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword                        *string                    `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                            *string                    `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy                         *bool                      `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                            *int                       `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout                         *string                    `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL                          *bool                      `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure                        *bool                      `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM                         *bool                      `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	ShutdownCommand                      *string                    `mapstructure:"shutdown_command" required:"false" cty:"shutdown_command" hcl:"shutdown_command"`
+	ShutdownTimeout                      *string                    `mapstructure:"shutdown_timeout" required:"false" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
+	IBMApiKey                            *string                    `mapstructure:"api_key" cty:"api_key" hcl:"api_key"`
+	Region                               *string                    `mapstructure:"region" cty:"region" hcl:"region"`
+	Endpoint                             *string                    `mapstructure:"vpc_endpoint_url" cty:"vpc_endpoint_url" hcl:"vpc_endpoint_url"`
+	RCEndpoint                           *string                    `mapstructure:"rc_endpoint_url" cty:"rc_endpoint_url" hcl:"rc_endpoint_url"`
+	GhostEndpoint                        *string                    `mapstructure:"ghost_endpoint_url" cty:"ghost_endpoint_url" hcl:"ghost_endpoint_url"`
+	EncryptionKeyCRN                     *string                    `mapstructure:"encryption_key_crn" cty:"encryption_key_crn" hcl:"encryption_key_crn"`
+	IAMEndpoint                          *string                    `mapstructure:"iam_url" cty:"iam_url" hcl:"iam_url"`
+	SubnetID                             *string                    `mapstructure:"subnet_id" cty:"subnet_id" hcl:"subnet_id"`
+	SubnetIDs                            []string                   `mapstructure:"subnet_ids" cty:"subnet_ids" hcl:"subnet_ids"`
+	SshKeyType                           *string                    `mapstructure:"ssh_key_type" cty:"ssh_key_type" hcl:"ssh_key_type"`
+	SshKeyBits                           *int                       `mapstructure:"ssh_key_bits" cty:"ssh_key_bits" hcl:"ssh_key_bits"`
+	CatalogOfferingCRN                   *string                    `mapstructure:"catalog_offering_crn" cty:"catalog_offering_crn" hcl:"catalog_offering_crn"`
+	CatalogOfferingVersionCRN            *string                    `mapstructure:"catalog_offering_version_crn" cty:"catalog_offering_version_crn" hcl:"catalog_offering_version_crn"`
+	ResourceGroupID                      *string                    `mapstructure:"resource_group_id" cty:"resource_group_id" hcl:"resource_group_id"`
+	ResourceGroupName                    *string                    `mapstructure:"resource_group_name" cty:"resource_group_name" hcl:"resource_group_name"`
+	SecurityGroupID                      *string                    `mapstructure:"security_group_id" cty:"security_group_id" hcl:"security_group_id"`
+	VSIBaseImageID                       *string                    `mapstructure:"vsi_base_image_id" cty:"vsi_base_image_id" hcl:"vsi_base_image_id"`
+	VSIBaseImageName                     *string                    `mapstructure:"vsi_base_image_name" cty:"vsi_base_image_name" hcl:"vsi_base_image_name"`
+	VSIBootCapacity                      *int                       `mapstructure:"vsi_boot_vol_capacity" cty:"vsi_boot_vol_capacity" hcl:"vsi_boot_vol_capacity"`
+	VSIBootProfile                       *string                    `mapstructure:"vsi_boot_vol_profile" cty:"vsi_boot_vol_profile" hcl:"vsi_boot_vol_profile"`
+	VSIBootIops                          *int                       `mapstructure:"vsi_boot_vol_iops" cty:"vsi_boot_vol_iops" hcl:"vsi_boot_vol_iops"`
+	VSIBootBandwidth                     *int                       `mapstructure:"vsi_boot_vol_bandwidth" cty:"vsi_boot_vol_bandwidth" hcl:"vsi_boot_vol_bandwidth"`
+	VSIBootVolumeID                      *string                    `mapstructure:"vsi_boot_volume_id" cty:"vsi_boot_volume_id" hcl:"vsi_boot_volume_id"`
+	VSIBootSnapshotID                    *string                    `mapstructure:"vsi_boot_snapshot_id" cty:"vsi_boot_snapshot_id" hcl:"vsi_boot_snapshot_id"`
+	VSIDataCapacity                      *int                       `mapstructure:"vsi_data_vol_capacity" cty:"vsi_data_vol_capacity" hcl:"vsi_data_vol_capacity"`
+	VSIDataProfile                       *string                    `mapstructure:"vsi_data_vol_profile" cty:"vsi_data_vol_profile" hcl:"vsi_data_vol_profile"`
+	VSIDataIops                          *int                       `mapstructure:"vsi_data_vol_iops" cty:"vsi_data_vol_iops" hcl:"vsi_data_vol_iops"`
+	VSIDataBandwidth                     *int                       `mapstructure:"vsi_data_vol_bandwidth" cty:"vsi_data_vol_bandwidth" hcl:"vsi_data_vol_bandwidth"`
+	VSIProfile                           *string                    `mapstructure:"vsi_profile" cty:"vsi_profile" hcl:"vsi_profile"`
+	VSIProfiles                          []string                   `mapstructure:"vsi_profiles" cty:"vsi_profiles" hcl:"vsi_profiles"`
+	VSIInterface                         *string                    `mapstructure:"vsi_interface" cty:"vsi_interface" hcl:"vsi_interface"`
+	VSIUserDataFile                      *string                    `mapstructure:"vsi_user_data_file" cty:"vsi_user_data_file" hcl:"vsi_user_data_file"`
+	VSIUserDataString                    *string                    `mapstructure:"vsi_user_data" cty:"vsi_user_data" hcl:"vsi_user_data"`
+	VSIUserDataParts                     []FlatUserDataPart         `mapstructure:"vsi_user_data_part" cty:"vsi_user_data_part" hcl:"vsi_user_data_part"`
+	WinRMBootstrap                       *bool                      `mapstructure:"winrm_bootstrap" cty:"winrm_bootstrap" hcl:"winrm_bootstrap"`
+	WaitForCloudInit                     *bool                      `mapstructure:"wait_for_cloud_init" cty:"wait_for_cloud_init" hcl:"wait_for_cloud_init"`
+	CloudInitTimeout                     *string                    `mapstructure:"cloud_init_timeout" cty:"cloud_init_timeout" hcl:"cloud_init_timeout"`
+	VSINetworkAttachment                 *string                    `mapstructure:"vsi_network_attachment" cty:"vsi_network_attachment" hcl:"vsi_network_attachment"`
+	VNIProtocolStateFilteringMode        *string                    `mapstructure:"vni_protocol_state_filtering_mode" cty:"vni_protocol_state_filtering_mode" hcl:"vni_protocol_state_filtering_mode"`
+	EnableSecureBoot                     *bool                      `mapstructure:"enable_secure_boot" cty:"enable_secure_boot" hcl:"enable_secure_boot"`
+	ConfidentialComputeMode              *string                    `mapstructure:"confidential_compute_mode" cty:"confidential_compute_mode" hcl:"confidential_compute_mode"`
+	MetadataService                      *FlatMetadataServiceConfig `mapstructure:"metadata_service" cty:"metadata_service" hcl:"metadata_service"`
+	TrustedProfileID                     *string                    `mapstructure:"trusted_profile_id" cty:"trusted_profile_id" hcl:"trusted_profile_id"`
+	TrustedProfileName                   *string                    `mapstructure:"trusted_profile_name" cty:"trusted_profile_name" hcl:"trusted_profile_name"`
+	DedicatedHostID                      *string                    `mapstructure:"dedicated_host_id" cty:"dedicated_host_id" hcl:"dedicated_host_id"`
+	DedicatedHostGroupID                 *string                    `mapstructure:"dedicated_host_group_id" cty:"dedicated_host_group_id" hcl:"dedicated_host_group_id"`
+	PlacementGroupID                     *string                    `mapstructure:"placement_group_id" cty:"placement_group_id" hcl:"placement_group_id"`
+	ReservationID                        *string                    `mapstructure:"reservation_id" cty:"reservation_id" hcl:"reservation_id"`
+	ReservationAffinityPolicy            *string                    `mapstructure:"reservation_affinity_policy" cty:"reservation_affinity_policy" hcl:"reservation_affinity_policy"`
+	ImageName                            *string                    `mapstructure:"image_name" cty:"image_name" hcl:"image_name"`
+	ImageTags                            []string                   `mapstructure:"tags" cty:"tags" hcl:"tags"`
+	KeepImageOnFailure                   *bool                      `mapstructure:"keep_image_on_failure" cty:"keep_image_on_failure" hcl:"keep_image_on_failure"`
+	Generalize                           *string                    `mapstructure:"generalize" cty:"generalize" hcl:"generalize"`
+	GeneralizeCommands                   []string                   `mapstructure:"generalize_commands" cty:"generalize_commands" hcl:"generalize_commands"`
+	GeneralizeUnattendFile               *string                    `mapstructure:"generalize_unattend_file" cty:"generalize_unattend_file" hcl:"generalize_unattend_file"`
+	SkipCreateDefaultSecurityGroupRule   *bool                      `mapstructure:"skip_create_default_security_group_rule" cty:"skip_create_default_security_group_rule" hcl:"skip_create_default_security_group_rule"`
+	SecurityGroupRuleRemoteCIDR          []string                   `mapstructure:"security_group_rule_remote_cidr" cty:"security_group_rule_remote_cidr" hcl:"security_group_rule_remote_cidr"`
+	SecurityGroupRuleRemoteAddress       []string                   `mapstructure:"security_group_rule_remote_address" cty:"security_group_rule_remote_address" hcl:"security_group_rule_remote_address"`
+	SecurityGroupRuleRemoteID            []string                   `mapstructure:"security_group_rule_remote_id" cty:"security_group_rule_remote_id" hcl:"security_group_rule_remote_id"`
+	TemporarySecurityGroupSourceCIDRs    []string                   `mapstructure:"temporary_security_group_source_cidrs" cty:"temporary_security_group_source_cidrs" hcl:"temporary_security_group_source_cidrs"`
+	TemporarySecurityGroupSourcePublicIP *bool                      `mapstructure:"temporary_security_group_source_public_ip" cty:"temporary_security_group_source_public_ip" hcl:"temporary_security_group_source_public_ip"`
+	PublicIPDiscoveryURL                 *string                    `mapstructure:"public_ip_discovery_url" cty:"public_ip_discovery_url" hcl:"public_ip_discovery_url"`
+	SecurityGroupRules                   []FlatSecurityGroupRule    `mapstructure:"security_group_rule" cty:"security_group_rule" hcl:"security_group_rule"`
+	TemporaryResourceTags                []string                   `mapstructure:"temporary_resource_tags" cty:"temporary_resource_tags" hcl:"temporary_resource_tags"`
+	TemporaryResourceNamePrefix          *string                    `mapstructure:"temporary_resource_name_prefix" cty:"temporary_resource_name_prefix" hcl:"temporary_resource_name_prefix"`
+	SshKeyID                             *string                    `mapstructure:"vpc_ssh_key_id" cty:"vpc_ssh_key_id" hcl:"vpc_ssh_key_id"`
+	SshKeyName                           *string                    `mapstructure:"vpc_ssh_key_name" cty:"vpc_ssh_key_name" hcl:"vpc_ssh_key_name"`
+	ExtraSshKeyIDs                       []string                   `mapstructure:"vpc_ssh_key_ids" cty:"vpc_ssh_key_ids" hcl:"vpc_ssh_key_ids"`
+	ResourceLedgerDir                    *string                    `mapstructure:"resource_ledger_dir" cty:"resource_ledger_dir" hcl:"resource_ledger_dir"`
+	SSHKeyOutputDir                      *string                    `mapstructure:"ssh_key_output_dir" cty:"ssh_key_output_dir" hcl:"ssh_key_output_dir"`
+	RawStateTimeout                      *string                    `mapstructure:"timeout" cty:"timeout" hcl:"timeout"`
+	ImageID                              *string                    `mapstructure:"image_id" cty:"image_id" hcl:"image_id"`
+	ImageExportJobName                   *string                    `mapstructure:"image_export_job_name" cty:"image_export_job_name" hcl:"image_export_job_name"`
+	ExportTimeout                        *string                    `mapstructure:"export_timeout" cty:"export_timeout" hcl:"export_timeout"`
+	StorageBucketName                    *string                    `mapstructure:"storage_bucket_name" cty:"storage_bucket_name" hcl:"storage_bucket_name"`
+	StorageBucketCRN                     *string                    `mapstructure:"storage_bucket_crn" cty:"storage_bucket_crn" hcl:"storage_bucket_crn"`
+	Format                               *string                    `mapstructure:"format" cty:"format" hcl:"format"`
+	SkipReboot                           *bool                      `mapstructure:"skip_reboot" cty:"skip_reboot" hcl:"skip_reboot"`
+	VPCLog                               *string                    `mapstructure:"logging" cty:"logging" hcl:"logging"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -127,109 +162,233 @@ func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec }
 // The decoded values from this spec will then be applied to a FlatConfig.
 func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"packer_build_name":                       &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
-		"packer_builder_type":                     &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
-		"packer_core_version":                     &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
-		"packer_debug":                            &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
-		"packer_force":                            &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
-		"packer_on_error":                         &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
-		"packer_user_variables":                   &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
-		"packer_sensitive_variables":              &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
-		"communicator":                            &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
-		"pause_before_connecting":                 &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
-		"ssh_host":                                &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
-		"ssh_port":                                &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
-		"ssh_username":                            &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
-		"ssh_password":                            &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
-		"ssh_keypair_name":                        &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_name":                 &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_type":                 &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
-		"temporary_key_pair_bits":                 &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
-		"ssh_ciphers":                             &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
-		"ssh_clear_authorized_keys":               &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
-		"ssh_key_exchange_algorithms":             &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
-		"ssh_private_key_file":                    &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
-		"ssh_certificate_file":                    &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
-		"ssh_pty":                                 &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
-		"ssh_timeout":                             &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
-		"ssh_wait_timeout":                        &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
-		"ssh_agent_auth":                          &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_disable_agent_forwarding":            &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
-		"ssh_handshake_attempts":                  &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
-		"ssh_bastion_host":                        &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
-		"ssh_bastion_port":                        &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
-		"ssh_bastion_agent_auth":                  &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_bastion_username":                    &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
-		"ssh_bastion_password":                    &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
-		"ssh_bastion_interactive":                 &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
-		"ssh_bastion_private_key_file":            &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
-		"ssh_bastion_certificate_file":            &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
-		"ssh_file_transfer_method":                &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
-		"ssh_proxy_host":                          &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
-		"ssh_proxy_port":                          &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
-		"ssh_proxy_username":                      &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
-		"ssh_proxy_password":                      &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
-		"ssh_keep_alive_interval":                 &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
-		"ssh_read_write_timeout":                  &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
-		"ssh_remote_tunnels":                      &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_local_tunnels":                       &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_public_key":                          &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
-		"ssh_private_key":                         &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
-		"winrm_username":                          &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
-		"winrm_password":                          &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
-		"winrm_host":                              &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
-		"winrm_no_proxy":                          &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
-		"winrm_port":                              &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
-		"winrm_timeout":                           &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
-		"winrm_use_ssl":                           &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
-		"winrm_insecure":                          &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
-		"winrm_use_ntlm":                          &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
-		"api_key":                                 &hcldec.AttrSpec{Name: "api_key", Type: cty.String, Required: false},
-		"region":                                  &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
-		"vpc_endpoint_url":                        &hcldec.AttrSpec{Name: "vpc_endpoint_url", Type: cty.String, Required: false},
-		"rc_endpoint_url":                         &hcldec.AttrSpec{Name: "rc_endpoint_url", Type: cty.String, Required: false},
-		"ghost_endpoint_url":                      &hcldec.AttrSpec{Name: "ghost_endpoint_url", Type: cty.String, Required: false},
-		"encryption_key_crn":                      &hcldec.AttrSpec{Name: "encryption_key_crn", Type: cty.String, Required: false},
-		"iam_url":                                 &hcldec.AttrSpec{Name: "iam_url", Type: cty.String, Required: false},
-		"subnet_id":                               &hcldec.AttrSpec{Name: "subnet_id", Type: cty.String, Required: false},
-		"subnet_ids":                              &hcldec.AttrSpec{Name: "subnet_ids", Type: cty.List(cty.String), Required: false},
-		"ssh_key_type":                            &hcldec.AttrSpec{Name: "ssh_key_type", Type: cty.String, Required: false},
-		"catalog_offering_crn":                    &hcldec.AttrSpec{Name: "catalog_offering_crn", Type: cty.String, Required: false},
-		"catalog_offering_version_crn":            &hcldec.AttrSpec{Name: "catalog_offering_version_crn", Type: cty.String, Required: false},
-		"resource_group_id":                       &hcldec.AttrSpec{Name: "resource_group_id", Type: cty.String, Required: false},
-		"resource_group_name":                     &hcldec.AttrSpec{Name: "resource_group_name", Type: cty.String, Required: false},
-		"security_group_id":                       &hcldec.AttrSpec{Name: "security_group_id", Type: cty.String, Required: false},
-		"vsi_base_image_id":                       &hcldec.AttrSpec{Name: "vsi_base_image_id", Type: cty.String, Required: false},
-		"vsi_base_image_name":                     &hcldec.AttrSpec{Name: "vsi_base_image_name", Type: cty.String, Required: false},
-		"vsi_boot_vol_capacity":                   &hcldec.AttrSpec{Name: "vsi_boot_vol_capacity", Type: cty.Number, Required: false},
-		"vsi_boot_vol_profile":                    &hcldec.AttrSpec{Name: "vsi_boot_vol_profile", Type: cty.String, Required: false},
-		"vsi_boot_vol_iops":                       &hcldec.AttrSpec{Name: "vsi_boot_vol_iops", Type: cty.Number, Required: false},
-		"vsi_boot_vol_bandwidth":                  &hcldec.AttrSpec{Name: "vsi_boot_vol_bandwidth", Type: cty.Number, Required: false},
-		"vsi_boot_volume_id":                      &hcldec.AttrSpec{Name: "vsi_boot_volume_id", Type: cty.String, Required: false},
-		"vsi_boot_snapshot_id":                    &hcldec.AttrSpec{Name: "vsi_boot_snapshot_id", Type: cty.String, Required: false},
-		"vsi_data_vol_capacity":                   &hcldec.AttrSpec{Name: "vsi_data_vol_capacity", Type: cty.Number, Required: false},
-		"vsi_data_vol_profile":                    &hcldec.AttrSpec{Name: "vsi_data_vol_profile", Type: cty.String, Required: false},
-		"vsi_data_vol_iops":                       &hcldec.AttrSpec{Name: "vsi_data_vol_iops", Type: cty.Number, Required: false},
-		"vsi_data_vol_bandwidth":                  &hcldec.AttrSpec{Name: "vsi_data_vol_bandwidth", Type: cty.Number, Required: false},
-		"vsi_profile":                             &hcldec.AttrSpec{Name: "vsi_profile", Type: cty.String, Required: false},
-		"vsi_interface":                           &hcldec.AttrSpec{Name: "vsi_interface", Type: cty.String, Required: false},
-		"vsi_user_data_file":                      &hcldec.AttrSpec{Name: "vsi_user_data_file", Type: cty.String, Required: false},
-		"vsi_user_data":                           &hcldec.AttrSpec{Name: "vsi_user_data", Type: cty.String, Required: false},
-		"image_name":                              &hcldec.AttrSpec{Name: "image_name", Type: cty.String, Required: false},
-		"tags":                                    &hcldec.AttrSpec{Name: "tags", Type: cty.List(cty.String), Required: false},
-		"skip_create_default_security_group_rule": &hcldec.AttrSpec{Name: "skip_create_default_security_group_rule", Type: cty.Bool, Required: false},
-		"security_group_rule_remote_cidr":         &hcldec.AttrSpec{Name: "security_group_rule_remote_cidr", Type: cty.List(cty.String), Required: false},
-		"security_group_rule_remote_address":      &hcldec.AttrSpec{Name: "security_group_rule_remote_address", Type: cty.List(cty.String), Required: false},
-		"security_group_rule_remote_id":           &hcldec.AttrSpec{Name: "security_group_rule_remote_id", Type: cty.List(cty.String), Required: false},
-		"timeout":                                 &hcldec.AttrSpec{Name: "timeout", Type: cty.String, Required: false},
-		"image_id":                                &hcldec.AttrSpec{Name: "image_id", Type: cty.String, Required: false},
-		"image_export_job_name":                   &hcldec.AttrSpec{Name: "image_export_job_name", Type: cty.String, Required: false},
-		"export_timeout":                          &hcldec.AttrSpec{Name: "export_timeout", Type: cty.String, Required: false},
-		"storage_bucket_name":                     &hcldec.AttrSpec{Name: "storage_bucket_name", Type: cty.String, Required: false},
-		"storage_bucket_crn":                      &hcldec.AttrSpec{Name: "storage_bucket_crn", Type: cty.String, Required: false},
-		"format":                                  &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
-		"skip_reboot":                             &hcldec.AttrSpec{Name: "skip_reboot", Type: cty.Bool, Required: false},
-		"logging":                                 &hcldec.AttrSpec{Name: "logging", Type: cty.String, Required: false},
+		"packer_build_name":                         &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":                       &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":                       &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":                              &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":                              &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":                           &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":                     &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables":                &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"communicator":                              &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
+		"pause_before_connecting":                   &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
+		"ssh_host":                                  &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
+		"ssh_port":                                  &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
+		"ssh_username":                              &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
+		"ssh_password":                              &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
+		"ssh_keypair_name":                          &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_name":                   &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_type":                   &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
+		"temporary_key_pair_bits":                   &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
+		"ssh_ciphers":                               &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
+		"ssh_clear_authorized_keys":                 &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
+		"ssh_key_exchange_algorithms":               &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_private_key_file":                      &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
+		"ssh_certificate_file":                      &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
+		"ssh_pty":                                   &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
+		"ssh_timeout":                               &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
+		"ssh_wait_timeout":                          &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
+		"ssh_agent_auth":                            &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_disable_agent_forwarding":              &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
+		"ssh_handshake_attempts":                    &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
+		"ssh_bastion_host":                          &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
+		"ssh_bastion_port":                          &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
+		"ssh_bastion_agent_auth":                    &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_bastion_username":                      &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
+		"ssh_bastion_password":                      &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
+		"ssh_bastion_interactive":                   &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
+		"ssh_bastion_private_key_file":              &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"ssh_bastion_certificate_file":              &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
+		"ssh_file_transfer_method":                  &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
+		"ssh_proxy_host":                            &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
+		"ssh_proxy_port":                            &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
+		"ssh_proxy_username":                        &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
+		"ssh_proxy_password":                        &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
+		"ssh_keep_alive_interval":                   &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
+		"ssh_read_write_timeout":                    &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
+		"ssh_remote_tunnels":                        &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_local_tunnels":                         &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_public_key":                            &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_private_key":                           &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+		"winrm_username":                            &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
+		"winrm_password":                            &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
+		"winrm_host":                                &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
+		"winrm_no_proxy":                            &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
+		"winrm_port":                                &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
+		"winrm_timeout":                             &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
+		"winrm_use_ssl":                             &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
+		"winrm_insecure":                            &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
+		"winrm_use_ntlm":                            &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"shutdown_command":                          &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
+		"shutdown_timeout":                          &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
+		"api_key":                                   &hcldec.AttrSpec{Name: "api_key", Type: cty.String, Required: false},
+		"region":                                    &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
+		"vpc_endpoint_url":                          &hcldec.AttrSpec{Name: "vpc_endpoint_url", Type: cty.String, Required: false},
+		"rc_endpoint_url":                           &hcldec.AttrSpec{Name: "rc_endpoint_url", Type: cty.String, Required: false},
+		"ghost_endpoint_url":                        &hcldec.AttrSpec{Name: "ghost_endpoint_url", Type: cty.String, Required: false},
+		"encryption_key_crn":                        &hcldec.AttrSpec{Name: "encryption_key_crn", Type: cty.String, Required: false},
+		"iam_url":                                   &hcldec.AttrSpec{Name: "iam_url", Type: cty.String, Required: false},
+		"subnet_id":                                 &hcldec.AttrSpec{Name: "subnet_id", Type: cty.String, Required: false},
+		"subnet_ids":                                &hcldec.AttrSpec{Name: "subnet_ids", Type: cty.List(cty.String), Required: false},
+		"ssh_key_type":                              &hcldec.AttrSpec{Name: "ssh_key_type", Type: cty.String, Required: false},
+		"ssh_key_bits":                              &hcldec.AttrSpec{Name: "ssh_key_bits", Type: cty.Number, Required: false},
+		"catalog_offering_crn":                      &hcldec.AttrSpec{Name: "catalog_offering_crn", Type: cty.String, Required: false},
+		"catalog_offering_version_crn":              &hcldec.AttrSpec{Name: "catalog_offering_version_crn", Type: cty.String, Required: false},
+		"resource_group_id":                         &hcldec.AttrSpec{Name: "resource_group_id", Type: cty.String, Required: false},
+		"resource_group_name":                       &hcldec.AttrSpec{Name: "resource_group_name", Type: cty.String, Required: false},
+		"security_group_id":                         &hcldec.AttrSpec{Name: "security_group_id", Type: cty.String, Required: false},
+		"vsi_base_image_id":                         &hcldec.AttrSpec{Name: "vsi_base_image_id", Type: cty.String, Required: false},
+		"vsi_base_image_name":                       &hcldec.AttrSpec{Name: "vsi_base_image_name", Type: cty.String, Required: false},
+		"vsi_boot_vol_capacity":                     &hcldec.AttrSpec{Name: "vsi_boot_vol_capacity", Type: cty.Number, Required: false},
+		"vsi_boot_vol_profile":                      &hcldec.AttrSpec{Name: "vsi_boot_vol_profile", Type: cty.String, Required: false},
+		"vsi_boot_vol_iops":                         &hcldec.AttrSpec{Name: "vsi_boot_vol_iops", Type: cty.Number, Required: false},
+		"vsi_boot_vol_bandwidth":                    &hcldec.AttrSpec{Name: "vsi_boot_vol_bandwidth", Type: cty.Number, Required: false},
+		"vsi_boot_volume_id":                        &hcldec.AttrSpec{Name: "vsi_boot_volume_id", Type: cty.String, Required: false},
+		"vsi_boot_snapshot_id":                      &hcldec.AttrSpec{Name: "vsi_boot_snapshot_id", Type: cty.String, Required: false},
+		"vsi_data_vol_capacity":                     &hcldec.AttrSpec{Name: "vsi_data_vol_capacity", Type: cty.Number, Required: false},
+		"vsi_data_vol_profile":                      &hcldec.AttrSpec{Name: "vsi_data_vol_profile", Type: cty.String, Required: false},
+		"vsi_data_vol_iops":                         &hcldec.AttrSpec{Name: "vsi_data_vol_iops", Type: cty.Number, Required: false},
+		"vsi_data_vol_bandwidth":                    &hcldec.AttrSpec{Name: "vsi_data_vol_bandwidth", Type: cty.Number, Required: false},
+		"vsi_profile":                               &hcldec.AttrSpec{Name: "vsi_profile", Type: cty.String, Required: false},
+		"vsi_profiles":                              &hcldec.AttrSpec{Name: "vsi_profiles", Type: cty.List(cty.String), Required: false},
+		"vsi_interface":                             &hcldec.AttrSpec{Name: "vsi_interface", Type: cty.String, Required: false},
+		"vsi_user_data_file":                        &hcldec.AttrSpec{Name: "vsi_user_data_file", Type: cty.String, Required: false},
+		"vsi_user_data":                             &hcldec.AttrSpec{Name: "vsi_user_data", Type: cty.String, Required: false},
+		"vsi_user_data_part":                        &hcldec.BlockListSpec{TypeName: "vsi_user_data_part", Nested: hcldec.ObjectSpec((*FlatUserDataPart)(nil).HCL2Spec())},
+		"winrm_bootstrap":                           &hcldec.AttrSpec{Name: "winrm_bootstrap", Type: cty.Bool, Required: false},
+		"wait_for_cloud_init":                       &hcldec.AttrSpec{Name: "wait_for_cloud_init", Type: cty.Bool, Required: false},
+		"cloud_init_timeout":                        &hcldec.AttrSpec{Name: "cloud_init_timeout", Type: cty.String, Required: false},
+		"vsi_network_attachment":                    &hcldec.AttrSpec{Name: "vsi_network_attachment", Type: cty.String, Required: false},
+		"vni_protocol_state_filtering_mode":         &hcldec.AttrSpec{Name: "vni_protocol_state_filtering_mode", Type: cty.String, Required: false},
+		"enable_secure_boot":                        &hcldec.AttrSpec{Name: "enable_secure_boot", Type: cty.Bool, Required: false},
+		"confidential_compute_mode":                 &hcldec.AttrSpec{Name: "confidential_compute_mode", Type: cty.String, Required: false},
+		"metadata_service":                          &hcldec.BlockSpec{TypeName: "metadata_service", Nested: hcldec.ObjectSpec((*FlatMetadataServiceConfig)(nil).HCL2Spec())},
+		"trusted_profile_id":                        &hcldec.AttrSpec{Name: "trusted_profile_id", Type: cty.String, Required: false},
+		"trusted_profile_name":                      &hcldec.AttrSpec{Name: "trusted_profile_name", Type: cty.String, Required: false},
+		"dedicated_host_id":                         &hcldec.AttrSpec{Name: "dedicated_host_id", Type: cty.String, Required: false},
+		"dedicated_host_group_id":                   &hcldec.AttrSpec{Name: "dedicated_host_group_id", Type: cty.String, Required: false},
+		"placement_group_id":                        &hcldec.AttrSpec{Name: "placement_group_id", Type: cty.String, Required: false},
+		"reservation_id":                            &hcldec.AttrSpec{Name: "reservation_id", Type: cty.String, Required: false},
+		"reservation_affinity_policy":               &hcldec.AttrSpec{Name: "reservation_affinity_policy", Type: cty.String, Required: false},
+		"image_name":                                &hcldec.AttrSpec{Name: "image_name", Type: cty.String, Required: false},
+		"tags":                                      &hcldec.AttrSpec{Name: "tags", Type: cty.List(cty.String), Required: false},
+		"keep_image_on_failure":                     &hcldec.AttrSpec{Name: "keep_image_on_failure", Type: cty.Bool, Required: false},
+		"generalize":                                &hcldec.AttrSpec{Name: "generalize", Type: cty.String, Required: false},
+		"generalize_commands":                       &hcldec.AttrSpec{Name: "generalize_commands", Type: cty.List(cty.String), Required: false},
+		"generalize_unattend_file":                  &hcldec.AttrSpec{Name: "generalize_unattend_file", Type: cty.String, Required: false},
+		"skip_create_default_security_group_rule":   &hcldec.AttrSpec{Name: "skip_create_default_security_group_rule", Type: cty.Bool, Required: false},
+		"security_group_rule_remote_cidr":           &hcldec.AttrSpec{Name: "security_group_rule_remote_cidr", Type: cty.List(cty.String), Required: false},
+		"security_group_rule_remote_address":        &hcldec.AttrSpec{Name: "security_group_rule_remote_address", Type: cty.List(cty.String), Required: false},
+		"security_group_rule_remote_id":             &hcldec.AttrSpec{Name: "security_group_rule_remote_id", Type: cty.List(cty.String), Required: false},
+		"temporary_security_group_source_cidrs":     &hcldec.AttrSpec{Name: "temporary_security_group_source_cidrs", Type: cty.List(cty.String), Required: false},
+		"temporary_security_group_source_public_ip": &hcldec.AttrSpec{Name: "temporary_security_group_source_public_ip", Type: cty.Bool, Required: false},
+		"public_ip_discovery_url":                   &hcldec.AttrSpec{Name: "public_ip_discovery_url", Type: cty.String, Required: false},
+		"security_group_rule":                       &hcldec.BlockListSpec{TypeName: "security_group_rule", Nested: hcldec.ObjectSpec((*FlatSecurityGroupRule)(nil).HCL2Spec())},
+		"temporary_resource_tags":                   &hcldec.AttrSpec{Name: "temporary_resource_tags", Type: cty.List(cty.String), Required: false},
+		"temporary_resource_name_prefix":            &hcldec.AttrSpec{Name: "temporary_resource_name_prefix", Type: cty.String, Required: false},
+		"vpc_ssh_key_id":                            &hcldec.AttrSpec{Name: "vpc_ssh_key_id", Type: cty.String, Required: false},
+		"vpc_ssh_key_name":                          &hcldec.AttrSpec{Name: "vpc_ssh_key_name", Type: cty.String, Required: false},
+		"vpc_ssh_key_ids":                           &hcldec.AttrSpec{Name: "vpc_ssh_key_ids", Type: cty.List(cty.String), Required: false},
+		"resource_ledger_dir":                       &hcldec.AttrSpec{Name: "resource_ledger_dir", Type: cty.String, Required: false},
+		"ssh_key_output_dir":                        &hcldec.AttrSpec{Name: "ssh_key_output_dir", Type: cty.String, Required: false},
+		"timeout":                                   &hcldec.AttrSpec{Name: "timeout", Type: cty.String, Required: false},
+		"image_id":                                  &hcldec.AttrSpec{Name: "image_id", Type: cty.String, Required: false},
+		"image_export_job_name":                     &hcldec.AttrSpec{Name: "image_export_job_name", Type: cty.String, Required: false},
+		"export_timeout":                            &hcldec.AttrSpec{Name: "export_timeout", Type: cty.String, Required: false},
+		"storage_bucket_name":                       &hcldec.AttrSpec{Name: "storage_bucket_name", Type: cty.String, Required: false},
+		"storage_bucket_crn":                        &hcldec.AttrSpec{Name: "storage_bucket_crn", Type: cty.String, Required: false},
+		"format":                                    &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
+		"skip_reboot":                               &hcldec.AttrSpec{Name: "skip_reboot", Type: cty.Bool, Required: false},
+		"logging":                                   &hcldec.AttrSpec{Name: "logging", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatMetadataServiceConfig is an auto-generated flat version of MetadataServiceConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatMetadataServiceConfig struct {
+	Enabled          *bool   `mapstructure:"enabled" cty:"enabled" hcl:"enabled"`
+	Protocol         *string `mapstructure:"protocol" cty:"protocol" hcl:"protocol"`
+	ResponseHopLimit *int    `mapstructure:"response_hop_limit" cty:"response_hop_limit" hcl:"response_hop_limit"`
+}
+
+// FlatMapstructure returns a new FlatMetadataServiceConfig.
+// FlatMetadataServiceConfig is an auto-generated flat version of MetadataServiceConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*MetadataServiceConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatMetadataServiceConfig)
+}
+
+// HCL2Spec returns the hcl spec of a MetadataServiceConfig.
+// This spec is used by HCL to read the fields of MetadataServiceConfig.
+// The decoded values from this spec will then be applied to a FlatMetadataServiceConfig.
+func (*FlatMetadataServiceConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"enabled":            &hcldec.AttrSpec{Name: "enabled", Type: cty.Bool, Required: false},
+		"protocol":           &hcldec.AttrSpec{Name: "protocol", Type: cty.String, Required: false},
+		"response_hop_limit": &hcldec.AttrSpec{Name: "response_hop_limit", Type: cty.Number, Required: false},
+	}
+	return s
+}
+
+// FlatSecurityGroupRule is an auto-generated flat version of SecurityGroupRule.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSecurityGroupRule struct {
+	Direction *string `mapstructure:"direction" cty:"direction" hcl:"direction"`
+	Protocol  *string `mapstructure:"protocol" cty:"protocol" hcl:"protocol"`
+	PortMin   *int    `mapstructure:"port_min" cty:"port_min" hcl:"port_min"`
+	PortMax   *int    `mapstructure:"port_max" cty:"port_max" hcl:"port_max"`
+	ICMPType  *int    `mapstructure:"icmp_type" cty:"icmp_type" hcl:"icmp_type"`
+	ICMPCode  *int    `mapstructure:"icmp_code" cty:"icmp_code" hcl:"icmp_code"`
+	Remote    *string `mapstructure:"remote" cty:"remote" hcl:"remote"`
+}
+
+// FlatMapstructure returns a new FlatSecurityGroupRule.
+// FlatSecurityGroupRule is an auto-generated flat version of SecurityGroupRule.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SecurityGroupRule) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSecurityGroupRule)
+}
+
+// HCL2Spec returns the hcl spec of a SecurityGroupRule.
+// This spec is used by HCL to read the fields of SecurityGroupRule.
+// The decoded values from this spec will then be applied to a FlatSecurityGroupRule.
+func (*FlatSecurityGroupRule) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"direction": &hcldec.AttrSpec{Name: "direction", Type: cty.String, Required: false},
+		"protocol":  &hcldec.AttrSpec{Name: "protocol", Type: cty.String, Required: false},
+		"port_min":  &hcldec.AttrSpec{Name: "port_min", Type: cty.Number, Required: false},
+		"port_max":  &hcldec.AttrSpec{Name: "port_max", Type: cty.Number, Required: false},
+		"icmp_type": &hcldec.AttrSpec{Name: "icmp_type", Type: cty.Number, Required: false},
+		"icmp_code": &hcldec.AttrSpec{Name: "icmp_code", Type: cty.Number, Required: false},
+		"remote":    &hcldec.AttrSpec{Name: "remote", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatUserDataPart is an auto-generated flat version of UserDataPart.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatUserDataPart struct {
+	ContentType *string `mapstructure:"content_type" cty:"content_type" hcl:"content_type"`
+	Content     *string `mapstructure:"content" cty:"content" hcl:"content"`
+	File        *string `mapstructure:"file" cty:"file" hcl:"file"`
+}
+
+// FlatMapstructure returns a new FlatUserDataPart.
+// FlatUserDataPart is an auto-generated flat version of UserDataPart.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*UserDataPart) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatUserDataPart)
+}
+
+// HCL2Spec returns the hcl spec of a UserDataPart.
+// This spec is used by HCL to read the fields of UserDataPart.
+// The decoded values from this spec will then be applied to a FlatUserDataPart.
+func (*FlatUserDataPart) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"content_type": &hcldec.AttrSpec{Name: "content_type", Type: cty.String, Required: false},
+		"content":      &hcldec.AttrSpec{Name: "content", Type: cty.String, Required: false},
+		"file":         &hcldec.AttrSpec{Name: "file", Type: cty.String, Required: false},
 	}
 	return s
 }
@@ -1,8 +1,13 @@
 package vpc
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"packer-plugin-ibmcloud/builder/ibmcloud/sshkey"
 
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 )
@@ -303,6 +308,18 @@ func TestBootVolumePrototype(t *testing.T) {
 			t.Errorf("Bandwidth = %v, want 4000", vol.Bandwidth)
 		}
 	})
+
+	// The volume is tagged as it is created, so no Global Tagging call is
+	// needed for it.
+	t.Run("image size and temporary resource tags", func(t *testing.T) {
+		vol := bootVolumePrototype(&Config{BuildID: "b-1", TemporaryResourceTags: []string{"team:img"}})
+		if vol.Capacity != nil {
+			t.Errorf("Capacity = %d, want nil (the image's size)", *vol.Capacity)
+		}
+		if got := strings.Join(vol.UserTags, ","); got != "team:img,packer-build-id:b-1" {
+			t.Errorf("UserTags = %q", got)
+		}
+	})
 }
 
 func TestSnapshotBootVolumePrototype(t *testing.T) {
@@ -670,3 +687,673 @@ func TestPrepareSubnetSelection(t *testing.T) {
 		})
 	}
 }
+
+func TestPrepareProfileSelection(t *testing.T) {
+	cases := []struct {
+		name         string
+		profile      string
+		profiles     []string
+		wantErr      string // substring expected in the error, "" means accept
+		wantProfiles []string
+	}{
+		{name: "only vsi_profile normalizes to list", profile: "bx2-2x8", wantProfiles: []string{"bx2-2x8"}},
+		{name: "only vsi_profiles is kept", profiles: []string{"bx2-8x32", "cx2-8x16"}, wantProfiles: []string{"bx2-8x32", "cx2-8x16"}},
+		{name: "both set is rejected", profile: "bx2-2x8", profiles: []string{"cx2-2x4"}, wantErr: "only one of vsi_profile or vsi_profiles"},
+		{name: "neither set is rejected", wantErr: "a vsi_profile or vsi_profiles must be specified"},
+		{name: "empty entry in vsi_profiles is rejected", profiles: []string{"bx2-2x8", ""}, wantErr: "vsi_profiles must not contain empty entries"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			c.VSIProfile = tc.profile
+			c.VSIProfiles = tc.profiles
+
+			_, err := c.Prepare()
+
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Prepare() error = %v, want substring %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Prepare() unexpected error: %v", err)
+			}
+			if strings.Join(c.VSIProfiles, ",") != strings.Join(tc.wantProfiles, ",") {
+				t.Errorf("VSIProfiles = %v, want %v", c.VSIProfiles, tc.wantProfiles)
+			}
+		})
+	}
+}
+
+func TestPrepareTemporaryResourceNames(t *testing.T) {
+	cases := []struct {
+		name       string
+		prefix     string
+		wantPrefix string
+		wantReject bool
+	}{
+		{"defaults to packer-vpc", "", "packer-vpc-", false},
+		{"custom prefix", "cc1234-bake", "cc1234-bake-", false},
+		{"uppercase", "CC1234", "", true},
+		{"starts with a digit", "1234-bake", "", true},
+		{"too long for the security group name", strings.Repeat("a", 29), "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.TemporaryResourceNamePrefix = tc.prefix
+			_, err := c.Prepare()
+			rejected := err != nil && strings.Contains(err.Error(), "temporary_resource_name_prefix")
+			if rejected != tc.wantReject {
+				t.Fatalf("prefix=%q rejected=%v, want %v (err=%v)", tc.prefix, rejected, tc.wantReject, err)
+			}
+			if tc.wantReject {
+				return
+			}
+			for _, name := range []string{c.VSIName, c.VpcSshKeyName, c.SecurityGroupName, c.FloatingIPName} {
+				if !strings.HasPrefix(name, tc.wantPrefix) {
+					t.Errorf("name %q does not start with %q", name, tc.wantPrefix)
+				}
+				if len(name) > 63 {
+					t.Errorf("name %q is longer than 63 characters", name)
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareTemporaryResourceTags(t *testing.T) {
+	c := validVPCConfig()
+	c.Comm.SSHUsername = "root"
+	c.TemporaryResourceTags = []string{"cost-center:cc1234", "team:platform"}
+	if _, err := c.Prepare(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.BuildID == "" {
+		t.Fatal("expected Prepare to generate a build ID")
+	}
+	want := []string{"cost-center:cc1234", "team:platform", "packer-build-id:" + c.BuildID}
+	if got := c.temporaryResourceTags(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("temporaryResourceTags() = %v, want %v", got, want)
+	}
+
+	for _, tag := range []string{"", "owner=platform", strings.Repeat("a", 129)} {
+		c := validVPCConfig()
+		c.TemporaryResourceTags = []string{tag}
+		_, err := c.Prepare()
+		if err == nil || !strings.Contains(err.Error(), "temporary_resource_tags") {
+			t.Errorf("tag %q: expected a temporary_resource_tags error, got %v", tag, err)
+		}
+	}
+}
+
+func TestPrepareNetworkAttachment(t *testing.T) {
+	cases := []struct {
+		name       string
+		attachment string
+		filtering  string
+		wantErr    string // substring expected in the error, "" means accept
+		wantValue  string
+	}{
+		{name: "defaults to network_interface", wantValue: "network_interface"},
+		{name: "virtual network interface", attachment: "virtual_network_interface", wantValue: "virtual_network_interface"},
+		{name: "filtering mode with a VNI", attachment: "virtual_network_interface", filtering: "enabled", wantValue: "virtual_network_interface"},
+		{name: "unknown attachment", attachment: "vni", wantErr: "vsi_network_attachment must be one of"},
+		{name: "filtering mode without a VNI", filtering: "auto", wantErr: "requires vsi_network_attachment to be 'virtual_network_interface'"},
+		{name: "unknown filtering mode", attachment: "virtual_network_interface", filtering: "on", wantErr: "vni_protocol_state_filtering_mode must be one of"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			c.VSINetworkAttachment = tc.attachment
+			c.VNIProtocolStateFilteringMode = tc.filtering
+
+			_, err := c.Prepare()
+
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Prepare() error = %v, want substring %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Prepare() unexpected error: %v", err)
+			}
+			if c.VSINetworkAttachment != tc.wantValue {
+				t.Errorf("VSINetworkAttachment = %q, want %q", c.VSINetworkAttachment, tc.wantValue)
+			}
+			if !strings.HasPrefix(c.VNIName, "packer-vpc-vni-") {
+				t.Errorf("VNIName = %q, want a packer-vpc-vni- prefix", c.VNIName)
+			}
+		})
+	}
+}
+
+func TestPrimaryNetworkPrototype(t *testing.T) {
+	subnet := &vpcv1.SubnetIdentityByID{ID: &[]string{"0717-subnet"}[0]}
+
+	t.Run("legacy network interface", func(t *testing.T) {
+		nic, attachment := primaryNetworkPrototype(&Config{VSINetworkAttachment: "network_interface"}, subnet)
+		if nic == nil || attachment != nil {
+			t.Fatalf("expected only a network interface, got %v and %v", nic, attachment)
+		}
+		if nic.Subnet != subnet {
+			t.Errorf("Subnet = %v, want %v", nic.Subnet, subnet)
+		}
+	})
+
+	t.Run("virtual network interface", func(t *testing.T) {
+		nic, attachment := primaryNetworkPrototype(&Config{
+			VSINetworkAttachment:          "virtual_network_interface",
+			VNIName:                       "packer-vpc-vni-1",
+			VNIProtocolStateFilteringMode: "enabled",
+		}, subnet)
+		if nic != nil || attachment == nil {
+			t.Fatalf("expected only a network attachment, got %v and %v", nic, attachment)
+		}
+		vni := attachment.VirtualNetworkInterface.(*vpcv1.InstanceNetworkAttachmentPrototypeVirtualNetworkInterfaceVirtualNetworkInterfacePrototypeInstanceNetworkAttachmentContext)
+		if *vni.Name != "packer-vpc-vni-1" || vni.Subnet != subnet {
+			t.Errorf("unexpected VNI name %q or subnet %v", *vni.Name, vni.Subnet)
+		}
+		// The VNI must not outlive the builder instance.
+		if vni.AutoDelete == nil || !*vni.AutoDelete {
+			t.Errorf("AutoDelete = %v, want true", vni.AutoDelete)
+		}
+		if vni.ProtocolStateFilteringMode == nil || *vni.ProtocolStateFilteringMode != "enabled" {
+			t.Errorf("ProtocolStateFilteringMode = %v, want enabled", vni.ProtocolStateFilteringMode)
+		}
+	})
+}
+
+func TestPrepareMetadataServiceAndTrustedProfile(t *testing.T) {
+	cases := []struct {
+		name      string
+		metadata  MetadataServiceConfig
+		profileID string
+		profile   string
+		wantErr   string // substring expected in the error, "" means accept
+	}{
+		{name: "neither"},
+		{name: "metadata service only", metadata: MetadataServiceConfig{Enabled: true, Protocol: "https", ResponseHopLimit: 2}},
+		{name: "trusted profile by id", metadata: MetadataServiceConfig{Enabled: true}, profileID: "Profile-1"},
+		{name: "trusted profile by name", metadata: MetadataServiceConfig{Enabled: true}, profile: "packer-builder"},
+		{name: "unknown protocol", metadata: MetadataServiceConfig{Enabled: true, Protocol: "ftp"}, wantErr: "protocol must be one of"},
+		{name: "hop limit out of range", metadata: MetadataServiceConfig{Enabled: true, ResponseHopLimit: 65}, wantErr: "response_hop_limit must be between 1 and 64"},
+		{name: "settings without enabled", metadata: MetadataServiceConfig{Protocol: "https"}, wantErr: "require enabled to be true"},
+		{name: "both id and name", metadata: MetadataServiceConfig{Enabled: true}, profileID: "Profile-1", profile: "packer-builder", wantErr: "only one of trusted_profile_id or trusted_profile_name"},
+		{name: "trusted profile without metadata service", profileID: "Profile-1", wantErr: "require metadata_service to be enabled"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			c.MetadataService = tc.metadata
+			c.TrustedProfileID = tc.profileID
+			c.TrustedProfileName = tc.profile
+
+			_, err := c.Prepare()
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Prepare() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Prepare() error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrepareConfidentialComputeMode(t *testing.T) {
+	for _, mode := range []string{"", "disabled", "sgx", "tdx", "sev"} {
+		c := validVPCConfig()
+		c.Comm.SSHUsername = "root"
+		c.ConfidentialComputeMode = mode
+		_, err := c.Prepare()
+		rejected := err != nil && strings.Contains(err.Error(), "confidential_compute_mode must be one of")
+		if rejected != (mode == "sev") {
+			t.Errorf("confidential_compute_mode=%q: rejected=%v (err=%v)", mode, rejected, err)
+		}
+	}
+}
+
+func TestPreparePlacement(t *testing.T) {
+	cases := []struct {
+		name       string
+		set        func(c *Config)
+		wantErr    string
+		wantPolicy string
+	}{
+		{name: "dedicated host", set: func(c *Config) { c.DedicatedHostID = "host-1" }},
+		{name: "placement group and reservation", set: func(c *Config) {
+			c.PlacementGroupID = "pg-1"
+			c.ReservationID = "res-1"
+		}, wantPolicy: "manual"},
+		{name: "two placement targets", set: func(c *Config) {
+			c.DedicatedHostID = "host-1"
+			c.PlacementGroupID = "pg-1"
+		}, wantErr: "only one of dedicated_host_id, dedicated_host_group_id or placement_group_id"},
+		{name: "automatic policy", set: func(c *Config) { c.ReservationAffinityPolicy = "automatic" }, wantPolicy: "automatic"},
+		{name: "unknown policy", set: func(c *Config) { c.ReservationAffinityPolicy = "restricted" }, wantErr: "reservation_affinity_policy must be one of"},
+		{name: "manual policy without reservation", set: func(c *Config) { c.ReservationAffinityPolicy = "manual" }, wantErr: "requires a reservation_id"},
+		{name: "reservation with disabled policy", set: func(c *Config) {
+			c.ReservationID = "res-1"
+			c.ReservationAffinityPolicy = "disabled"
+		}, wantErr: "reservation_id requires reservation_affinity_policy to be 'manual'"},
+		{name: "reservation on a dedicated host group", set: func(c *Config) {
+			c.ReservationID = "res-1"
+			c.DedicatedHostGroupID = "group-1"
+		}, wantErr: "reservation_id cannot be combined"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			tc.set(c)
+			_, err := c.Prepare()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if c.ReservationAffinityPolicy != tc.wantPolicy {
+					t.Errorf("reservation_affinity_policy = %q, want %q", c.ReservationAffinityPolicy, tc.wantPolicy)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareWinRMKeyType(t *testing.T) {
+	for _, tc := range []struct {
+		communicator, keyType string
+		wantErr               bool
+	}{
+		{"winrm", "", false},
+		{"winrm", "rsa", false},
+		{"winrm", "ed25519", true},
+		{"ssh", "ed25519", false},
+	} {
+		c := validVPCConfig()
+		c.Comm.Type = tc.communicator
+		c.Comm.SSHUsername = "root"
+		c.Comm.WinRMUser = "Administrator"
+		c.SshKeyType = tc.keyType
+		_, err := c.Prepare()
+		rejected := err != nil && strings.Contains(err.Error(), "ssh_key_type 'ed25519' cannot be used with the winrm communicator")
+		if rejected != tc.wantErr {
+			t.Errorf("communicator=%s ssh_key_type=%q: rejected=%v (err=%v)", tc.communicator, tc.keyType, rejected, err)
+		}
+	}
+}
+
+func TestPrepareExistingSshKey(t *testing.T) {
+	privateKeyFile, _ := writeTestPrivateKey(t)
+	notAKey := filepath.Join(t.TempDir(), "not-a-key")
+	if err := os.WriteFile(notAKey, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		name    string
+		set     func(c *Config)
+		wantErr string
+	}{
+		{name: "private key uploaded as the temporary key", set: func(c *Config) { c.Comm.SSHPrivateKeyFile = privateKeyFile }},
+		{name: "existing key by ID", set: func(c *Config) {
+			c.Comm.SSHPrivateKeyFile = privateKeyFile
+			c.SshKeyID = "key-1"
+			c.ExtraSshKeyIDs = []string{"key-2", "key-3"}
+		}},
+		{name: "existing key by name", set: func(c *Config) {
+			c.Comm.SSHPrivateKeyFile = privateKeyFile
+			c.SshKeyName = "vault-key"
+		}},
+		{name: "existing key without its private key", set: func(c *Config) { c.SshKeyName = "vault-key" }, wantErr: "ssh_private_key_file must be set with vpc_ssh_key_id or vpc_ssh_key_name"},
+		{name: "existing key by ID and name", set: func(c *Config) {
+			c.Comm.SSHPrivateKeyFile = privateKeyFile
+			c.SshKeyID = "key-1"
+			c.SshKeyName = "vault-key"
+		}, wantErr: "only one of vpc_ssh_key_id or vpc_ssh_key_name"},
+		{name: "key type with a private key", set: func(c *Config) {
+			c.Comm.SSHPrivateKeyFile = privateKeyFile
+			c.SshKeyType = "rsa"
+		}, wantErr: "ssh_key_type and ssh_key_bits cannot be set with ssh_private_key_file"},
+		{name: "unusable private key", set: func(c *Config) { c.Comm.SSHPrivateKeyFile = notAKey }, wantErr: "is not a usable private key"},
+		{name: "empty extra key", set: func(c *Config) { c.ExtraSshKeyIDs = []string{"key-2", ""} }, wantErr: "vpc_ssh_key_ids cannot contain empty entries"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			tc.set(c)
+			_, err := c.Prepare()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareSshKeyTypeAndBits(t *testing.T) {
+	ecdsaKeyFile := filepath.Join(t.TempDir(), "id_ecdsa")
+	pair, err := sshkey.Generate(sshkey.ECDSA, 256)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.WriteFile(ecdsaKeyFile, pair.PrivateKey, 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		name     string
+		set      func(c *Config)
+		wantType string
+		wantBits int
+		wantErr  string
+	}{
+		{name: "defaults to 2048 bits rsa", set: func(c *Config) {}, wantType: "rsa", wantBits: 2048},
+		{name: "4096 bits rsa", set: func(c *Config) { c.SshKeyBits = 4096 }, wantType: "rsa", wantBits: 4096},
+		{name: "ed25519", set: func(c *Config) { c.SshKeyType = "ed25519" }, wantType: "ed25519", wantBits: 256},
+		{name: "rsa size the VPC key API rejects", set: func(c *Config) { c.SshKeyBits = 3072 }, wantErr: "ssh_key_bits must be 2048 or 4096 for rsa keys"},
+		{name: "ecdsa", set: func(c *Config) { c.SshKeyType = "ecdsa" }, wantErr: `ssh_key_type must be one of rsa, ed25519, not "ecdsa"`},
+		{name: "ecdsa private key uploaded", set: func(c *Config) { c.Comm.SSHPrivateKeyFile = ecdsaKeyFile }, wantErr: "cannot be uploaded as a VPC key"},
+		{name: "ecdsa private key of an existing key", set: func(c *Config) {
+			c.Comm.SSHPrivateKeyFile = ecdsaKeyFile
+			c.SshKeyID = "key-1"
+		}},
+		{name: "ecdsa private key for winrm", set: func(c *Config) {
+			c.Comm.Type = "winrm"
+			c.Comm.WinRMUser = "Administrator"
+			c.Comm.SSHPrivateKeyFile = ecdsaKeyFile
+			c.SshKeyID = "key-1"
+		}, wantErr: "cannot be used with the winrm communicator"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			tc.set(c)
+			_, err := c.Prepare()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if c.SshKeyType != tc.wantType || c.SshKeyBits != tc.wantBits {
+					t.Errorf("got %s %d, want %s %d", c.SshKeyType, c.SshKeyBits, tc.wantType, tc.wantBits)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareGeneralize(t *testing.T) {
+	unattendFile := filepath.Join(t.TempDir(), "unattend.xml")
+	if err := os.WriteFile(unattendFile, []byte("<unattend/>"), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	privateKeyFile, _ := writeTestPrivateKey(t)
+
+	cases := []struct {
+		name    string
+		set     func(c *Config)
+		wantErr string
+	}{
+		{name: "linux profile", set: func(c *Config) { c.Generalize = "linux" }},
+		{name: "commands only", set: func(c *Config) { c.GeneralizeCommands = []string{"apt-get clean"} }},
+		{name: "windows profile with an unattend file", set: func(c *Config) {
+			c.Comm.Type = "winrm"
+			c.Generalize = "windows"
+			c.GeneralizeUnattendFile = unattendFile
+		}},
+		{name: "unknown profile", set: func(c *Config) { c.Generalize = "macos" }, wantErr: "generalize must be 'linux' or 'windows', not 'macos'"},
+		{name: "windows profile over ssh", set: func(c *Config) {
+			c.Comm.SSHPrivateKeyFile = privateKeyFile
+			c.Generalize = "windows"
+		}, wantErr: "generalize 'windows' requires the winrm communicator"},
+		{name: "linux profile over winrm", set: func(c *Config) {
+			c.Comm.Type = "winrm"
+			c.Generalize = "linux"
+		}, wantErr: "generalize 'linux' requires the ssh communicator"},
+		{name: "commands without a communicator", set: func(c *Config) {
+			c.Comm.Type = "none"
+			c.GeneralizeCommands = []string{"apt-get clean"}
+		}, wantErr: "generalize_commands require the ssh or winrm communicator"},
+		{name: "unattend file with the linux profile", set: func(c *Config) {
+			c.Generalize = "linux"
+			c.GeneralizeUnattendFile = unattendFile
+		}, wantErr: "generalize_unattend_file can only be set with generalize 'windows'"},
+		{name: "missing unattend file", set: func(c *Config) {
+			c.Comm.Type = "winrm"
+			c.Generalize = "windows"
+			c.GeneralizeUnattendFile = filepath.Join(t.TempDir(), "missing.xml")
+		}, wantErr: "generalize_unattend_file:"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			c.Comm.WinRMUser = "Administrator"
+			tc.set(c)
+			_, err := c.Prepare()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareWaitForCloudInit(t *testing.T) {
+	c := validVPCConfig()
+	c.Comm.SSHUsername = "root"
+	c.WaitForCloudInit = true
+	if _, err := c.Prepare(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.CloudInitTimeout != 30*time.Minute {
+		t.Errorf("cloud_init_timeout defaults to %s, want 30m", c.CloudInitTimeout)
+	}
+
+	c = validVPCConfig()
+	c.Comm.Type = "none"
+	c.WaitForCloudInit = true
+	if _, err := c.Prepare(); err == nil || !strings.Contains(err.Error(), "wait_for_cloud_init requires the ssh or winrm communicator") {
+		t.Errorf("expected the communicator to be required, got: %v", err)
+	}
+}
+
+func TestPrepareUserDataParts(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		set     func(c *Config)
+		wantErr string
+	}{
+		{name: "build values are interpolated when the instance is created", raw: map[string]interface{}{
+			"vsi_user_data_part": []map[string]interface{}{{"content_type": "cloud-config", "content": "#cloud-config\nssh_authorized_keys: [\"{{ .SSHPublicKey }}\"]\n"}},
+		}},
+		{name: "with vsi_user_data", raw: map[string]interface{}{
+			"vsi_user_data":      "#!/bin/sh",
+			"vsi_user_data_part": []map[string]interface{}{{"content_type": "shell-script", "content": "#!/bin/sh"}},
+		}, wantErr: "vsi_user_data_part cannot be combined with vsi_user_data or vsi_user_data_file"},
+		{name: "unknown content type", raw: map[string]interface{}{
+			"vsi_user_data_part": []map[string]interface{}{{"content_type": "text/plain", "content": "hello"}},
+		}, wantErr: "vsi_user_data_part 1: content_type must be one of cloud-config, shell-script, powershell, not 'text/plain'"},
+		{name: "content and file", raw: map[string]interface{}{
+			"vsi_user_data_part": []map[string]interface{}{{"content_type": "shell-script", "content": "#!/bin/sh", "file": "setup.sh"}},
+		}, wantErr: "vsi_user_data_part 1: exactly one of content or file must be set"},
+		{name: "unknown build value", raw: map[string]interface{}{
+			"vsi_user_data_part": []map[string]interface{}{{"content_type": "shell-script", "content": "echo {{ .VpcID }}"}},
+		}, wantErr: "vsi_user_data_part 1:"},
+		{name: "too large", set: func(c *Config) { c.VSIUserDataString = strings.Repeat("x", maxUserDataSize+1) },
+			wantErr: "the user data is 65537 bytes, more than the 65536 VPC accepts"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			if tc.set != nil {
+				tc.set(c)
+			}
+			var raws []interface{}
+			if tc.raw != nil {
+				raws = append(raws, tc.raw)
+			}
+			_, err := c.Prepare(raws...)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if !strings.Contains(c.VSIUserDataParts[0].Content, "{{ .SSHPublicKey }}") {
+					t.Errorf("the part was interpolated by Prepare: %q", c.VSIUserDataParts[0].Content)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareWinRMBootstrap(t *testing.T) {
+	c := validVPCConfig()
+	c.Comm.Type = "winrm"
+	c.Comm.WinRMUser = "Administrator"
+	c.WinRMBootstrap = true
+	if _, err := c.Prepare(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !c.Comm.WinRMUseSSL || !c.Comm.WinRMInsecure || c.Comm.WinRMPort != 5986 {
+		t.Errorf("winrm_use_ssl %t, winrm_insecure %t, winrm_port %d: want the HTTPS listener on 5986", c.Comm.WinRMUseSSL, c.Comm.WinRMInsecure, c.Comm.WinRMPort)
+	}
+
+	cases := []struct {
+		name    string
+		set     func(c *Config)
+		wantErr string
+	}{
+		{name: "ssh", set: func(c *Config) { c.Comm.Type = "ssh" }, wantErr: "winrm_bootstrap requires the winrm communicator"},
+		{name: "plaintext port", set: func(c *Config) { c.Comm.WinRMPort = 5985 }, wantErr: "winrm_port must be 5986 with winrm_bootstrap"},
+		{name: "with vsi_user_data", set: func(c *Config) { c.VSIUserDataString = "#ps1_sysnative" }, wantErr: "winrm_bootstrap cannot be combined with vsi_user_data"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.Type = "winrm"
+			c.Comm.SSHUsername = "root"
+			c.Comm.WinRMUser = "Administrator"
+			c.WinRMBootstrap = true
+			tc.set(c)
+			_, err := c.Prepare()
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareTemporarySecurityGroupSource(t *testing.T) {
+	cases := []struct {
+		name    string
+		set     func(c *Config)
+		wantErr string
+	}{
+		{name: "public IP", set: func(c *Config) { c.TemporarySecurityGroupSourcePublicIP = true }},
+		{name: "source CIDRs", set: func(c *Config) { c.TemporarySecurityGroupSourceCIDRs = []string{"198.51.100.0/24"} }},
+		{name: "invalid CIDR", set: func(c *Config) { c.TemporarySecurityGroupSourceCIDRs = []string{"198.51.100.7"} },
+			wantErr: "temporary_security_group_source_cidrs entry '198.51.100.7' is not a CIDR block"},
+		{name: "public IP and remotes", set: func(c *Config) {
+			c.TemporarySecurityGroupSourcePublicIP = true
+			c.SecurityGroupRuleRemoteAddress = []string{"198.51.100.7"}
+		}, wantErr: "temporary_security_group_source_public_ip cannot be combined with security_group_rule_remote_*"},
+		{name: "public IP through a bastion", set: func(c *Config) {
+			c.TemporarySecurityGroupSourcePublicIP = true
+			c.Comm.SSHBastionHost = "bastion.example.com"
+			c.Comm.SSHBastionUsername = "root"
+			c.Comm.SSHBastionPassword = "secret"
+		}, wantErr: "temporary_security_group_source_public_ip cannot be used with ssh_bastion_host"},
+		{name: "public IP without the rule", set: func(c *Config) {
+			c.TemporarySecurityGroupSourcePublicIP = true
+			c.SkipCreateDefaultSecurityGroupRule = true
+		}, wantErr: "cannot be set with skip_create_default_security_group_rule"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validVPCConfig()
+			c.Comm.SSHUsername = "root"
+			tc.set(c)
+			_, err := c.Prepare()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if c.PublicIPDiscoveryURL != defaultPublicIPDiscoveryURL {
+					t.Errorf("public_ip_discovery_url defaults to %q", c.PublicIPDiscoveryURL)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareSecurityGroupRules(t *testing.T) {
+	c := validVPCConfig()
+	c.Comm.SSHUsername = "root"
+	c.SecurityGroupRules = []SecurityGroupRule{{Protocol: "tcp", PortMin: 8443}, {Protocol: "icmp", PortMin: 1}}
+	_, err := c.Prepare()
+	if err == nil || !strings.Contains(err.Error(), "security_group_rule 2: port_min and port_max cannot be set for icmp rules") {
+		t.Errorf("expected the second rule to be rejected, got: %v", err)
+	}
+	if c.SecurityGroupRules[0].Direction != "inbound" || c.SecurityGroupRules[0].PortMax != 8443 {
+		t.Errorf("the first rule's defaults are not set: %+v", c.SecurityGroupRules[0])
+	}
+}
+
+// An inbound rule without a remote is not opened to every address; it keeps
+// no remote, for the communicator's sources. An outbound one goes anywhere.
+func TestPrepareSecurityGroupRulesWithoutRemote(t *testing.T) {
+	c := validVPCConfig()
+	c.Comm.SSHUsername = "root"
+	c.SecurityGroupRules = []SecurityGroupRule{
+		{Protocol: "tcp", PortMin: 8443},
+		{Direction: "outbound", Protocol: "all"},
+	}
+	if _, err := c.Prepare(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if remote := c.SecurityGroupRules[0].Remote; remote != "" {
+		t.Errorf("expected the inbound rule to keep no remote, got %q", remote)
+	}
+	if remote := c.SecurityGroupRules[1].Remote; remote != "0.0.0.0/0" {
+		t.Errorf("expected the outbound rule to default to 0.0.0.0/0, got %q", remote)
+	}
+}
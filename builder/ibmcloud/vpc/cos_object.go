@@ -0,0 +1,80 @@
+package vpc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+// exportedObjectDeleter deletes the Cloud Object Storage object an image export
+// job wrote. It is a seam so StepImageCopy's Cleanup can be faked in tests.
+type exportedObjectDeleter interface {
+	deleteObject(href string) error
+}
+
+// cosObjectDeleter deletes objects through the S3-compatible COS API.
+type cosObjectDeleter struct {
+	authenticator core.Authenticator
+	client        *http.Client
+	// endpoint returns the COS endpoint of a bucket location.
+	endpoint func(location string) string
+}
+
+var _ exportedObjectDeleter = cosObjectDeleter{}
+
+// newCOSObjectDeleter builds a cosObjectDeleter that authenticates with the IBM
+// Cloud API key and talks to the public COS endpoints.
+func newCOSObjectDeleter(apiKey, iamURL string) cosObjectDeleter {
+	return cosObjectDeleter{
+		authenticator: &core.IamAuthenticator{ApiKey: apiKey, URL: iamURL},
+		client:        &http.Client{Timeout: 30 * time.Second},
+		endpoint: func(location string) string {
+			return "https://s3." + location + ".cloud-object-storage.appdomain.cloud"
+		},
+	}
+}
+
+// parseCOSHref splits the storage_href of an image export job,
+// cos://<location>/<bucket>/<object>.
+func parseCOSHref(href string) (location, bucket, object string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(href, "cos://"), "/", 3)
+	if !strings.HasPrefix(href, "cos://") || len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("%q is not a cos://<location>/<bucket>/<object> location", href)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+func (d cosObjectDeleter) deleteObject(href string) error {
+	location, bucket, object, err := parseCOSHref(href)
+	if err != nil {
+		return err
+	}
+	objectURL := fmt.Sprintf("%s/%s/%s", d.endpoint(location), url.PathEscape(bucket), (&url.URL{Path: object}).EscapedPath())
+	req, err := http.NewRequest(http.MethodDelete, objectURL, nil)
+	if err != nil {
+		return fmt.Errorf("building COS delete request: %w", err)
+	}
+	if err := d.authenticator.Authenticate(req); err != nil {
+		return fmt.Errorf("authenticating COS request: %w", err)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("contacting COS to delete %s: %w", href, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK, http.StatusNotFound:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("COS denied the deletion of %s (HTTP %d) — the API key needs the Object Writer role (or higher) on the bucket: %s",
+			href, resp.StatusCode, oneLineSnippet(respBody))
+	default:
+		return fmt.Errorf("COS deletion of %s returned HTTP %d: %s", href, resp.StatusCode, oneLineSnippet(respBody))
+	}
+}
@@ -0,0 +1,64 @@
+package vpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+func TestParseCOSHref(t *testing.T) {
+	location, bucket, object, err := parseCOSHref("cos://us-south/my-bucket/exports/golden.qcow2")
+	if err != nil {
+		t.Fatalf("parseCOSHref returned error: %s", err)
+	}
+	if location != "us-south" || bucket != "my-bucket" || object != "exports/golden.qcow2" {
+		t.Errorf("got %q %q %q", location, bucket, object)
+	}
+	for _, href := range []string{"", "https://us-south/bucket/object", "cos://us-south/bucket", "cos://us-south//object"} {
+		if _, _, _, err := parseCOSHref(href); err == nil {
+			t.Errorf("expected an error for %q", href)
+		}
+	}
+}
+
+func TestCOSObjectDeleter(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{name: "deleted", status: http.StatusNoContent},
+		{name: "already gone", status: http.StatusNotFound},
+		{name: "forbidden", status: http.StatusForbidden, wantErr: "Object Writer"},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: "HTTP 500"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Method + " " + r.URL.EscapedPath()
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			d := cosObjectDeleter{
+				authenticator: &core.NoAuthAuthenticator{},
+				client:        srv.Client(),
+				endpoint:      func(location string) string { return srv.URL + "/" + location },
+			}
+			err := d.deleteObject("cos://us-south/bucket/exports/golden image.qcow2")
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+			if want := "DELETE /us-south/bucket/exports/golden%20image.qcow2"; got != want {
+				t.Errorf("got request %q, want %q", got, want)
+			}
+		})
+	}
+}
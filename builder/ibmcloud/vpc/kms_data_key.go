@@ -0,0 +1,113 @@
+package vpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+// dataKeyWrapper wraps and unwraps image data encryption keys with a KMS root
+// key. It is a seam so the rewrap used by StepImageCopy can be faked in tests.
+// Keys travel base64-encoded, as the KMS API expects.
+type dataKeyWrapper interface {
+	unwrapKey(endpoint, instanceID, keyID, ciphertext string) (plaintext string, err error)
+	wrapKey(endpoint, instanceID, keyID, plaintext string) (ciphertext string, err error)
+}
+
+// kmsDataKeyWrapper wraps and unwraps keys through the Key Protect-compatible KMS API.
+type kmsDataKeyWrapper struct {
+	authenticator core.Authenticator
+	client        *http.Client
+}
+
+var _ dataKeyWrapper = kmsDataKeyWrapper{}
+
+// newKMSDataKeyWrapper builds a kmsDataKeyWrapper that authenticates with the IBM Cloud API key.
+func newKMSDataKeyWrapper(apiKey, iamURL string) kmsDataKeyWrapper {
+	return kmsDataKeyWrapper{
+		authenticator: &core.IamAuthenticator{ApiKey: apiKey, URL: iamURL},
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (w kmsDataKeyWrapper) unwrapKey(endpoint, instanceID, keyID, ciphertext string) (string, error) {
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := w.keyAction(endpoint, instanceID, keyID, "unwrap", map[string]string{"ciphertext": ciphertext}, &out); err != nil {
+		return "", err
+	}
+	return out.Plaintext, nil
+}
+
+func (w kmsDataKeyWrapper) wrapKey(endpoint, instanceID, keyID, plaintext string) (string, error) {
+	var out struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := w.keyAction(endpoint, instanceID, keyID, "wrap", map[string]string{"plaintext": plaintext}, &out); err != nil {
+		return "", err
+	}
+	return out.Ciphertext, nil
+}
+
+// keyAction POSTs a key action (POST /api/v2/keys/{id}/actions/{action}) and decodes the response into out.
+func (w kmsDataKeyWrapper) keyAction(endpoint, instanceID, keyID, action string, in map[string]string, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encoding KMS %s request: %w", action, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v2/keys/%s/actions/%s", endpoint, keyID, action), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building KMS %s request: %w", action, err)
+	}
+	req.Header.Set("Bluemix-Instance", instanceID)
+	req.Header.Set("Content-Type", "application/vnd.ibm.kms.key_action+json")
+	if err := w.authenticator.Authenticate(req); err != nil {
+		return fmt.Errorf("authenticating KMS request: %w", err)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("contacting KMS at %s to %s key %s: %w", endpoint, action, keyID, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// parsed below
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("KMS denied the %s of key %s at %s (HTTP %d) — the API key needs the Key Protect ReaderPlus role (or higher) on this instance: %s",
+			action, keyID, endpoint, resp.StatusCode, oneLineSnippet(respBody))
+	default:
+		return fmt.Errorf("KMS %s of key %s at %s returned HTTP %d: %s", action, keyID, endpoint, resp.StatusCode, oneLineSnippet(respBody))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("parsing KMS %s response from %s: %w", action, endpoint, err)
+	}
+	return nil
+}
+
+// rewrapDataKey turns the encrypted data key of an exported image, wrapped by
+// the source image's root key, into one wrapped by the target region's root key,
+// so the exported file can be imported there as an encrypted image. The
+// plaintext data key only exists in memory between the two calls.
+func rewrapDataKey(sourceKeyCRN, targetKeyCRN string, encryptedDataKey []byte, w dataKeyWrapper) (string, error) {
+	sourceEndpoint, sourceInstance, sourceKey, err := parseEncryptionKeyCRN(sourceKeyCRN)
+	if err != nil {
+		return "", err
+	}
+	targetEndpoint, targetInstance, targetKey, err := parseEncryptionKeyCRN(targetKeyCRN)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := w.unwrapKey(sourceEndpoint, sourceInstance, sourceKey, base64.StdEncoding.EncodeToString(encryptedDataKey))
+	if err != nil {
+		return "", err
+	}
+	return w.wrapKey(targetEndpoint, targetInstance, targetKey, plaintext)
+}
@@ -0,0 +1,101 @@
+package vpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKMSDataKeyWrapperKeyActions(t *testing.T) {
+	var gotPath, gotInstance, gotContentType string
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotInstance = r.Header.Get("Bluemix-Instance")
+		gotContentType = r.Header.Get("Content-Type")
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"plaintext":"cGxhaW4=","ciphertext":"d3JhcHBlZA=="}`))
+	}))
+	defer srv.Close()
+	w := kmsDataKeyWrapper{authenticator: stubAuthenticator{}, client: srv.Client()}
+
+	plaintext, err := w.unwrapKey(srv.URL, "inst-1", "key-1", "c2VjcmV0")
+	if err != nil {
+		t.Fatalf("unwrapKey returned error: %s", err)
+	}
+	if plaintext != "cGxhaW4=" || gotPath != "/api/v2/keys/key-1/actions/unwrap" || gotBody["ciphertext"] != "c2VjcmV0" {
+		t.Errorf("unwrap: got plaintext %q, path %q, body %v", plaintext, gotPath, gotBody)
+	}
+	if gotInstance != "inst-1" || gotContentType != "application/vnd.ibm.kms.key_action+json" {
+		t.Errorf("unwrap: got Bluemix-Instance %q, Content-Type %q", gotInstance, gotContentType)
+	}
+
+	ciphertext, err := w.wrapKey(srv.URL, "inst-2", "key-2", "cGxhaW4=")
+	if err != nil {
+		t.Fatalf("wrapKey returned error: %s", err)
+	}
+	if ciphertext != "d3JhcHBlZA==" || gotPath != "/api/v2/keys/key-2/actions/wrap" || gotBody["plaintext"] != "cGxhaW4=" {
+		t.Errorf("wrap: got ciphertext %q, path %q, body %v", ciphertext, gotPath, gotBody)
+	}
+}
+
+func TestKMSDataKeyWrapperDenied(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"resources":[{"errorMsg":"Unauthorized"}]}`))
+	}))
+	defer srv.Close()
+	w := kmsDataKeyWrapper{authenticator: stubAuthenticator{}, client: srv.Client()}
+
+	if _, err := w.unwrapKey(srv.URL, "inst-1", "key-1", "c2VjcmV0"); err == nil {
+		t.Fatal("expected an error when KMS denies the unwrap")
+	}
+}
+
+// fakeDataKeyWrapper records the KMS calls and "wraps" by prefixing the key id.
+type fakeDataKeyWrapper struct {
+	calls []string
+}
+
+func (f *fakeDataKeyWrapper) unwrapKey(endpoint, instanceID, keyID, ciphertext string) (string, error) {
+	f.calls = append(f.calls, "unwrap "+endpoint+" "+instanceID+" "+keyID+" "+ciphertext)
+	return "plain", nil
+}
+
+func (f *fakeDataKeyWrapper) wrapKey(endpoint, instanceID, keyID, plaintext string) (string, error) {
+	f.calls = append(f.calls, "wrap "+endpoint+" "+instanceID+" "+keyID+" "+plaintext)
+	return keyID + ":" + plaintext, nil
+}
+
+func TestRewrapDataKey(t *testing.T) {
+	f := &fakeDataKeyWrapper{}
+	got, err := rewrapDataKey(
+		"crn:v1:bluemix:public:kms:us-south:a/acc:inst-src:key:key-src",
+		"crn:v1:bluemix:public:kms:eu-de:a/acc:inst-dst:key:key-dst",
+		[]byte("secret"), f)
+	if err != nil {
+		t.Fatalf("rewrapDataKey returned error: %s", err)
+	}
+	if got != "key-dst:plain" {
+		t.Errorf("expected the key wrapped by the target key, got %q", got)
+	}
+	want := []string{
+		"unwrap https://us-south.kms.cloud.ibm.com inst-src key-src c2VjcmV0",
+		"wrap https://eu-de.kms.cloud.ibm.com inst-dst key-dst plain",
+	}
+	if len(f.calls) != len(want) || f.calls[0] != want[0] || f.calls[1] != want[1] {
+		t.Errorf("unexpected KMS calls:\n got %q\nwant %q", f.calls, want)
+	}
+}
+
+func TestRewrapDataKeyRejectsBadCRN(t *testing.T) {
+	f := &fakeDataKeyWrapper{}
+	if _, err := rewrapDataKey("crn:v1:bluemix:public:kms:us-south:a/acc:inst-src:key:key-src", "not-a-crn", []byte("secret"), f); err == nil {
+		t.Fatal("expected an error for an invalid target key CRN")
+	}
+	if len(f.calls) != 0 {
+		t.Errorf("no KMS call should be made for an invalid CRN, got %q", f.calls)
+	}
+}
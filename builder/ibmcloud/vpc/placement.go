@@ -0,0 +1,153 @@
+package vpc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+)
+
+// placementTargetPrototype returns the placement_target of the builder
+// instance, or nil to let IBM Cloud place it.
+func placementTargetPrototype(config *Config) vpcv1.InstancePlacementTargetPrototypeIntf {
+	switch {
+	case config.DedicatedHostID != "":
+		return &vpcv1.InstancePlacementTargetPrototypeDedicatedHostIdentity{ID: &config.DedicatedHostID}
+	case config.DedicatedHostGroupID != "":
+		return &vpcv1.InstancePlacementTargetPrototypeDedicatedHostGroupIdentity{ID: &config.DedicatedHostGroupID}
+	case config.PlacementGroupID != "":
+		return &vpcv1.InstancePlacementTargetPrototypePlacementGroupIdentity{ID: &config.PlacementGroupID}
+	}
+	return nil
+}
+
+// reservationAffinityPrototype returns the reservation_affinity of the builder
+// instance, or nil to use the account's default policy.
+func reservationAffinityPrototype(config *Config) *vpcv1.InstanceReservationAffinityPrototype {
+	if config.ReservationAffinityPolicy == "" {
+		return nil
+	}
+	affinity := &vpcv1.InstanceReservationAffinityPrototype{Policy: &config.ReservationAffinityPolicy}
+	if config.ReservationID != "" {
+		affinity.Pool = []vpcv1.ReservationIdentityIntf{&vpcv1.ReservationIdentity{ID: &config.ReservationID}}
+	}
+	return affinity
+}
+
+// placementZone checks that the configured dedicated host, dedicated host
+// group, placement group and reservation can take an instance of every
+// vsi_profiles entry, and returns the zone the dedicated host, group or
+// reservation pins the build to ("" when none does) and a description of what
+// pins it.
+func placementZone(svc *vpcv1.VpcV1, config *Config) (zone, pinnedBy string, err error) {
+	switch {
+	case config.DedicatedHostID != "":
+		host, _, err := svc.GetDedicatedHost(&vpcv1.GetDedicatedHostOptions{ID: &config.DedicatedHostID})
+		if err != nil {
+			return "", "", fmt.Errorf("fetching dedicated host %s: %s", config.DedicatedHostID, err)
+		}
+		if host.InstancePlacementEnabled != nil && !*host.InstancePlacementEnabled {
+			return "", "", fmt.Errorf("dedicated host %s does not allow instances to be placed on it", config.DedicatedHostID)
+		}
+		if state := stringValue(host.State); state != vpcv1.DedicatedHostStateAvailableConst {
+			return "", "", fmt.Errorf("dedicated host %s is %s, not available", config.DedicatedHostID, state)
+		}
+		if err := checkSupportedProfiles(host.SupportedInstanceProfiles, config.VSIProfiles, "dedicated host "+config.DedicatedHostID); err != nil {
+			return "", "", err
+		}
+		zone, pinnedBy = zoneName(host.Zone), "dedicated host "+config.DedicatedHostID
+	case config.DedicatedHostGroupID != "":
+		group, _, err := svc.GetDedicatedHostGroup(&vpcv1.GetDedicatedHostGroupOptions{ID: &config.DedicatedHostGroupID})
+		if err != nil {
+			return "", "", fmt.Errorf("fetching dedicated host group %s: %s", config.DedicatedHostGroupID, err)
+		}
+		if err := checkSupportedProfiles(group.SupportedInstanceProfiles, config.VSIProfiles, "dedicated host group "+config.DedicatedHostGroupID); err != nil {
+			return "", "", err
+		}
+		zone, pinnedBy = zoneName(group.Zone), "dedicated host group "+config.DedicatedHostGroupID
+	case config.PlacementGroupID != "":
+		// Placement groups are regional: any subnet will do.
+		group, _, err := svc.GetPlacementGroup(&vpcv1.GetPlacementGroupOptions{ID: &config.PlacementGroupID})
+		if err != nil {
+			return "", "", fmt.Errorf("fetching placement group %s: %s", config.PlacementGroupID, err)
+		}
+		if state := stringValue(group.LifecycleState); state != vpcv1.PlacementGroupLifecycleStateStableConst {
+			return "", "", fmt.Errorf("placement group %s is %s, not stable", config.PlacementGroupID, state)
+		}
+	}
+
+	if config.ReservationID != "" {
+		reservation, _, err := svc.GetReservation(&vpcv1.GetReservationOptions{ID: &config.ReservationID})
+		if err != nil {
+			return "", "", fmt.Errorf("fetching reservation %s: %s", config.ReservationID, err)
+		}
+		if status := stringValue(reservation.Status); status != vpcv1.ReservationStatusActiveConst {
+			return "", "", fmt.Errorf("reservation %s is %s, not active", config.ReservationID, status)
+		}
+		if profile, ok := reservation.Profile.(*vpcv1.ReservationProfile); ok && profile.Name != nil {
+			for _, p := range config.VSIProfiles {
+				if p != *profile.Name {
+					return "", "", fmt.Errorf("reservation %s is for %s instances, not %s", config.ReservationID, *profile.Name, p)
+				}
+			}
+		}
+		zone, pinnedBy = zoneName(reservation.Zone), "reservation "+config.ReservationID
+	}
+	return zone, pinnedBy, nil
+}
+
+// checkSupportedProfiles fails unless every profile is one of the supported
+// profiles of the dedicated host or group described by what.
+func checkSupportedProfiles(supported []vpcv1.InstanceProfileReference, profiles []string, what string) error {
+	names := []string{}
+	for _, p := range supported {
+		names = append(names, stringValue(p.Name))
+	}
+	for _, profile := range profiles {
+		if !slices.Contains(names, profile) {
+			return fmt.Errorf("%s cannot run %s instances; supported profiles: %s", what, profile, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// subnetsOutsideZone fetches the subnets and returns the ones that are not in
+// zone, as "<id> (<zone>)".
+func subnetsOutsideZone(svc *vpcv1.VpcV1, subnetIDs []string, zone string) ([]string, error) {
+	outside := []string{}
+	for _, id := range subnetIDs {
+		subnet, _, err := svc.GetSubnet(&vpcv1.GetSubnetOptions{ID: &id})
+		if err != nil {
+			return nil, fmt.Errorf("fetching subnet %s: %s", id, err)
+		}
+		if subnetZone := zoneName(subnet.Zone); subnetZone != zone {
+			outside = append(outside, fmt.Sprintf("%s (%s)", id, subnetZone))
+		}
+	}
+	return outside, nil
+}
+
+// pinnedCapacityError explains a capacity failure for profile that no other
+// subnet can work around because the instance is pinned to a dedicated host,
+// dedicated host group or reservation, which has no more capacity in any
+// subnet; only another of vsi_profiles can. It returns nil when the next
+// subnet is worth trying.
+func pinnedCapacityError(config *Config, profile string, capErr *capacityError) error {
+	switch {
+	case capErr.code == vpcv1.InstanceStatusReasonCodeCannotStartReservationCapacityConst && config.ReservationID != "":
+		return fmt.Errorf("%s. Reservation %s has no capacity left for another %s instance; free some of it, or set reservation_affinity_policy to \"automatic\" or \"disabled\" to build without it", capErr, config.ReservationID, profile)
+	case config.DedicatedHostID != "":
+		return fmt.Errorf("%s. Dedicated host %s has no room left for a %s instance", capErr, config.DedicatedHostID, profile)
+	case config.DedicatedHostGroupID != "":
+		return fmt.Errorf("%s. No host of dedicated host group %s has room left for a %s instance", capErr, config.DedicatedHostGroupID, profile)
+	}
+	return nil
+}
+
+func zoneName(zone *vpcv1.ZoneReference) string {
+	if zone == nil {
+		return ""
+	}
+	return stringValue(zone.Name)
+}
@@ -0,0 +1,192 @@
+package vpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+)
+
+// placementHandler serves the dedicated hosts, placement groups, reservations
+// and subnets placementZone and subnetsOutsideZone read.
+func placementHandler(t *testing.T) http.HandlerFunc {
+	resources := map[string]string{
+		"/dedicated_hosts/host-1":        `{"id":"host-1","instance_placement_enabled":true,"state":"available","supported_instance_profiles":[{"name":"bx2-2x8"},{"name":"bx2-4x16"}],"zone":{"name":"us-south-2"}}`,
+		"/dedicated_hosts/host-full":     `{"id":"host-full","instance_placement_enabled":false,"state":"available","supported_instance_profiles":[{"name":"bx2-2x8"}],"zone":{"name":"us-south-2"}}`,
+		"/dedicated_host/groups/group-1": `{"id":"group-1","supported_instance_profiles":[{"name":"mx2-2x16"}],"zone":{"name":"us-south-1"}}`,
+		"/placement_groups/pg-1":         `{"id":"pg-1","lifecycle_state":"stable","strategy":"host_spread"}`,
+		"/reservations/res-1":            `{"id":"res-1","status":"active","profile":{"name":"bx2-2x8","resource_type":"instance_profile"},"zone":{"name":"us-south-3"}}`,
+		"/reservations/res-expired":      `{"id":"res-expired","status":"expired","profile":{"name":"bx2-2x8","resource_type":"instance_profile"},"zone":{"name":"us-south-3"}}`,
+		"/subnets/subnet-1":              `{"id":"subnet-1","zone":{"name":"us-south-1"}}`,
+		"/subnets/subnet-2":              `{"id":"subnet-2","zone":{"name":"us-south-2"}}`,
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, ok := resources[r.URL.Path]
+		if !ok || r.Method != http.MethodGet {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestPlacementZone(t *testing.T) {
+	srv := httptest.NewServer(placementHandler(t))
+	defer srv.Close()
+	svc := newTestVpcService(t, srv.URL)
+
+	cases := []struct {
+		name         string
+		config       Config
+		wantZone     string
+		wantPinnedBy string
+		wantErr      string
+	}{
+		{
+			name:         "dedicated host",
+			config:       Config{VSIProfiles: []string{"bx2-4x16"}, DedicatedHostID: "host-1"},
+			wantZone:     "us-south-2",
+			wantPinnedBy: "dedicated host host-1",
+		},
+		{
+			name:    "dedicated host without the profile",
+			config:  Config{VSIProfiles: []string{"cx2-2x4"}, DedicatedHostID: "host-1"},
+			wantErr: "dedicated host host-1 cannot run cx2-2x4 instances; supported profiles: bx2-2x8, bx2-4x16",
+		},
+		{
+			name:    "dedicated host without the fallback profile",
+			config:  Config{VSIProfiles: []string{"bx2-4x16", "cx2-4x8"}, DedicatedHostID: "host-1"},
+			wantErr: "dedicated host host-1 cannot run cx2-4x8 instances",
+		},
+		{
+			name:    "dedicated host closed to placement",
+			config:  Config{VSIProfiles: []string{"bx2-2x8"}, DedicatedHostID: "host-full"},
+			wantErr: "does not allow instances to be placed on it",
+		},
+		{
+			name:         "dedicated host group",
+			config:       Config{VSIProfiles: []string{"mx2-2x16"}, DedicatedHostGroupID: "group-1"},
+			wantZone:     "us-south-1",
+			wantPinnedBy: "dedicated host group group-1",
+		},
+		{
+			name:   "placement group is regional",
+			config: Config{VSIProfiles: []string{"bx2-2x8"}, PlacementGroupID: "pg-1"},
+		},
+		{
+			name:         "placement group and reservation",
+			config:       Config{VSIProfiles: []string{"bx2-2x8"}, PlacementGroupID: "pg-1", ReservationID: "res-1"},
+			wantZone:     "us-south-3",
+			wantPinnedBy: "reservation res-1",
+		},
+		{
+			name:    "reservation for another profile",
+			config:  Config{VSIProfiles: []string{"bx2-4x16"}, ReservationID: "res-1"},
+			wantErr: "reservation res-1 is for bx2-2x8 instances, not bx2-4x16",
+		},
+		{
+			name:    "expired reservation",
+			config:  Config{VSIProfiles: []string{"bx2-2x8"}, ReservationID: "res-expired"},
+			wantErr: "reservation res-expired is expired, not active",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone, pinnedBy, err := placementZone(svc, &tc.config)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if zone != tc.wantZone || pinnedBy != tc.wantPinnedBy {
+				t.Errorf("got (%q, %q), want (%q, %q)", zone, pinnedBy, tc.wantZone, tc.wantPinnedBy)
+			}
+		})
+	}
+}
+
+func TestSubnetsOutsideZone(t *testing.T) {
+	srv := httptest.NewServer(placementHandler(t))
+	defer srv.Close()
+
+	outside, err := subnetsOutsideZone(newTestVpcService(t, srv.URL), []string{"subnet-1", "subnet-2"}, "us-south-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Join(outside, ",") != "subnet-1 (us-south-1)" {
+		t.Errorf("got %v, want [subnet-1 (us-south-1)]", outside)
+	}
+}
+
+func TestPlacementPrototypes(t *testing.T) {
+	config := &Config{DedicatedHostGroupID: "group-1", ReservationAffinityPolicy: "manual", ReservationID: "res-1"}
+	target, ok := placementTargetPrototype(config).(*vpcv1.InstancePlacementTargetPrototypeDedicatedHostGroupIdentity)
+	if !ok || *target.ID != "group-1" {
+		t.Errorf("placement target = %#v, want dedicated host group group-1", placementTargetPrototype(config))
+	}
+	affinity := reservationAffinityPrototype(config)
+	if *affinity.Policy != "manual" || len(affinity.Pool) != 1 || *affinity.Pool[0].(*vpcv1.ReservationIdentity).ID != "res-1" {
+		t.Errorf("reservation affinity = %#v, want manual with pool res-1", affinity)
+	}
+
+	if target := placementTargetPrototype(&Config{}); target != nil {
+		t.Errorf("placement target = %#v, want nil", target)
+	}
+	if affinity := reservationAffinityPrototype(&Config{}); affinity != nil {
+		t.Errorf("reservation affinity = %#v, want nil", affinity)
+	}
+}
+
+func TestPinnedCapacityError(t *testing.T) {
+	capacity := &capacityError{msg: "[ERROR] capacity", code: vpcv1.InstanceStatusReasonCodeCannotStartCapacityConst}
+	reservation := &capacityError{msg: "[ERROR] reservation", code: vpcv1.InstanceStatusReasonCodeCannotStartReservationCapacityConst}
+
+	cases := []struct {
+		name    string
+		config  Config
+		capErr  *capacityError
+		wantErr string
+	}{
+		{name: "no placement tries the next subnet", capErr: capacity},
+		{name: "automatic reservation tries the next subnet", config: Config{ReservationAffinityPolicy: "automatic"}, capErr: reservation},
+		{name: "zone capacity with a reservation tries the next subnet", config: Config{ReservationID: "res-1"}, capErr: capacity},
+		{name: "placement group tries the next subnet", config: Config{PlacementGroupID: "pg-1"}, capErr: capacity},
+		{name: "full reservation", config: Config{ReservationID: "res-1"}, capErr: reservation, wantErr: "Reservation res-1 has no capacity left for another bx2-2x8 instance"},
+		{name: "full dedicated host", config: Config{DedicatedHostID: "host-1"}, capErr: capacity, wantErr: "Dedicated host host-1 has no room left"},
+		{name: "full dedicated host group", config: Config{DedicatedHostGroupID: "group-1"}, capErr: capacity, wantErr: "No host of dedicated host group group-1 has room left"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := pinnedCapacityError(&tc.config, "bx2-2x8", tc.capErr)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) || !strings.Contains(err.Error(), tc.capErr.msg) {
+				t.Errorf("expected error containing %q and %q, got: %v", tc.capErr.msg, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPlacementAttempts(t *testing.T) {
+	subnets := []subnetZone{{ID: "subnet-1", Zone: "us-south-1"}, {ID: "subnet-2", Zone: "us-south-2"}}
+	got := []string{}
+	for _, a := range placementAttempts([]string{"bx2-8x32", "cx2-8x16"}, subnets) {
+		got = append(got, a.Profile+"/"+a.Subnet.ID)
+	}
+	want := "bx2-8x32/subnet-1,bx2-8x32/subnet-2,cx2-8x16/subnet-1,cx2-8x16/subnet-2"
+	if strings.Join(got, ",") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package vpc
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+)
+
+// checkSecureBootAndConfidentialCompute verifies that the profile supports
+// the requested enable_secure_boot and confidential_compute_mode.
+func checkSecureBootAndConfidentialCompute(config Config, profile *vpcv1.InstanceProfile) error {
+	if config.EnableSecureBoot != nil {
+		secureBoot := *config.EnableSecureBoot
+		if profile.SecureBootModes == nil || !slices.Contains(profile.SecureBootModes.Values, secureBoot) {
+			return fmt.Errorf("vsi_profile %s does not support enable_secure_boot = %t", stringValue(profile.Name), secureBoot)
+		}
+	}
+	if config.ConfidentialComputeMode != "" {
+		if profile.ConfidentialComputeModes == nil || !slices.Contains(profile.ConfidentialComputeModes.Values, config.ConfidentialComputeMode) {
+			var supported []string
+			if profile.ConfidentialComputeModes != nil {
+				supported = profile.ConfidentialComputeModes.Values
+			}
+			return fmt.Errorf("vsi_profile %s does not support confidential_compute_mode %s (supported: %s)", stringValue(profile.Name), config.ConfidentialComputeMode, strings.Join(supported, ", "))
+		}
+	}
+	return nil
+}
+
+// allowedUseComparison is one enable_secure_boot or confidential_compute_mode
+// comparison with a literal, e.g. enable_secure_boot == true.
+var allowedUseComparison = regexp.MustCompile(`^(enable_secure_boot|confidential_compute_mode)\s*(==|!=)\s*(true|false|'[^']*'|"[^"]*")$`)
+
+// allowedUseNegation matches a ! other than that of !=.
+var allowedUseNegation = regexp.MustCompile(`!\s*[^=\s]`)
+
+// checkAllowedUse verifies that the allowed_use.instance expression of the
+// instance's source, described by source, admits the requested
+// enable_secure_boot and confidential_compute_mode. Only the comparisons of
+// those variables with a literal that the expression requires, i.e. joined to
+// it by &&, are checked; IBM Cloud evaluates the whole expression when the
+// instance is created, so an expression with ||, ! or ?: is left to it.
+func checkAllowedUse(config Config, source, expression string) error {
+	if strings.Contains(expression, "||") || strings.Contains(expression, "?") || allowedUseNegation.MatchString(expression) {
+		return nil
+	}
+	requested := map[string]string{}
+	if config.EnableSecureBoot != nil {
+		requested["enable_secure_boot"] = strconv.FormatBool(*config.EnableSecureBoot)
+	}
+	if config.ConfidentialComputeMode != "" {
+		requested["confidential_compute_mode"] = config.ConfidentialComputeMode
+	}
+	for _, term := range strings.Split(expression, "&&") {
+		term = strings.Trim(term, "() \t\n")
+		if term == "enable_secure_boot" {
+			term = "enable_secure_boot == true"
+		}
+		match := allowedUseComparison.FindStringSubmatch(term)
+		if match == nil {
+			continue
+		}
+		value, ok := requested[match[1]]
+		if !ok {
+			continue
+		}
+		if (value == strings.Trim(match[3], `'"`)) != (match[2] == "==") {
+			return fmt.Errorf("the allowed_use of %s does not allow %s = %s: %s", source, match[1], value, expression)
+		}
+	}
+	return nil
+}
+
+// confidentialComputeModePrototype returns the confidential_compute_mode of the
+// builder instance, nil to leave it to the profile's default.
+func confidentialComputeModePrototype(config *Config) *string {
+	if config.ConfidentialComputeMode == "" {
+		return nil
+	}
+	return &config.ConfidentialComputeMode
+}
+
+// imageCapabilities describes the captured image for the artifact's state:
+// "secure_boot" and "confidential_compute_mode" are those of the builder
+// instance the image was captured from, i.e. what it has been seen to boot
+// with, and "allowed_use" the image's allowed_use.instance expression, which
+// instances provisioned from it must satisfy.
+func imageCapabilities(instance *vpcv1.Instance, allowedUse *vpcv1.ImageAllowedUse) map[string]interface{} {
+	capabilities := map[string]interface{}{}
+	if instance.EnableSecureBoot != nil {
+		capabilities["secure_boot"] = *instance.EnableSecureBoot
+	}
+	if instance.ConfidentialComputeMode != nil {
+		capabilities["confidential_compute_mode"] = *instance.ConfidentialComputeMode
+	}
+	if allowedUse != nil && stringValue(allowedUse.Instance) != "" {
+		capabilities["allowed_use"] = *allowedUse.Instance
+	}
+	return capabilities
+}
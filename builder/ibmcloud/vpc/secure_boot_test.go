@@ -0,0 +1,145 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestCheckSecureBootAndConfidentialCompute(t *testing.T) {
+	yes, no := true, false
+	profile := &vpcv1.InstanceProfile{
+		Name:                     &[]string{"bx3dc-2x10"}[0],
+		SecureBootModes:          &vpcv1.InstanceProfileSupportedSecureBootModes{Default: &no, Values: []bool{false, true}},
+		ConfidentialComputeModes: &vpcv1.InstanceProfileSupportedConfidentialComputeModes{Values: []string{"disabled", "tdx"}},
+	}
+	cases := []struct {
+		name       string
+		secureBoot *bool
+		mode       string
+		wantErr    string // substring expected in the error, "" means accept
+	}{
+		{name: "supported settings", secureBoot: &yes, mode: "tdx"},
+		{name: "secure boot off", secureBoot: &no},
+		{name: "unsupported mode", mode: "sgx", wantErr: "vsi_profile bx3dc-2x10 does not support confidential_compute_mode sgx (supported: disabled, tdx)"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := Config{EnableSecureBoot: tc.secureBoot, ConfidentialComputeMode: tc.mode}
+			err := checkSecureBootAndConfidentialCompute(config, profile)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	noSecureBoot := &vpcv1.InstanceProfile{Name: &[]string{"bx2-2x8"}[0], SecureBootModes: &vpcv1.InstanceProfileSupportedSecureBootModes{Default: &no, Values: []bool{false}}}
+	err := checkSecureBootAndConfidentialCompute(Config{EnableSecureBoot: &yes}, noSecureBoot)
+	if err == nil || !strings.Contains(err.Error(), "vsi_profile bx2-2x8 does not support enable_secure_boot = true") {
+		t.Errorf("expected the profile to reject secure boot, got %v", err)
+	}
+}
+
+func TestCheckAllowedUse(t *testing.T) {
+	yes, no := true, false
+	cases := []struct {
+		name       string
+		expression string
+		secureBoot *bool
+		mode       string
+		wantErr    string // substring expected in the error, "" means accept
+	}{
+		{name: "no expression", secureBoot: &yes},
+		{name: "secure boot required", expression: "enable_secure_boot == true", secureBoot: &yes},
+		{name: "secure boot required but off", expression: "gpu.count == 0 && enable_secure_boot == true", secureBoot: &no,
+			wantErr: "the allowed_use of base image golden does not allow enable_secure_boot = false"},
+		{name: "bare secure boot variable", expression: "(enable_secure_boot) && vcpu.count >= 2", secureBoot: &no,
+			wantErr: "does not allow enable_secure_boot = false"},
+		{name: "secure boot unset", expression: "enable_secure_boot == true"},
+		{name: "mode ruled out", expression: "confidential_compute_mode != 'tdx'", mode: "tdx",
+			wantErr: "does not allow confidential_compute_mode = tdx"},
+		{name: "mode required", expression: `confidential_compute_mode == "sgx"`, mode: "sgx"},
+		{name: "alternative left to the API", expression: "enable_secure_boot == true || gpu.count > 0", secureBoot: &no},
+		{name: "negation left to the API", expression: "!(enable_secure_boot == false && gpu.count > 0)", secureBoot: &no},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := Config{EnableSecureBoot: tc.secureBoot, ConfidentialComputeMode: tc.mode}
+			err := checkAllowedUse(config, "base image golden", tc.expression)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// stepVerifyInput halts before creating anything when the base image's
+// allowed_use rules out the requested secure boot setting.
+func TestStepVerifyInputAllowedUse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/regions/us-south":
+			fmt.Fprint(w, `{"name":"us-south","status":"available"}`)
+		case "/images/r006-golden":
+			fmt.Fprint(w, `{"id":"r006-golden","name":"golden","status":"available",
+				"operating_system":{"name":"ubuntu-24-04-amd64","architecture":"amd64"},
+				"allowed_use":{"api_version":"2025-07-01","instance":"enable_secure_boot == true","bare_metal_server":"true"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	no := false
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packer.TestUi(t))
+	state.Put("client", &IBMCloudClient{})
+	state.Put("vpcService", newTestVpcService(t, srv.URL))
+	state.Put("config", Config{Region: "us-south", VSIBaseImageID: "r006-golden", VSIProfiles: []string{"bx2-2x8"}, EnableSecureBoot: &no})
+
+	if action := new(stepVerifyInput).Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatal("expected stepVerifyInput to halt")
+	}
+	err, _ := state.Get("error").(error)
+	if err == nil || !strings.Contains(err.Error(), "the allowed_use of base image golden does not allow enable_secure_boot = false") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestImageCapabilities(t *testing.T) {
+	yes := true
+	mode := "tdx"
+	expr := "enable_secure_boot == true"
+	capabilities := imageCapabilities(
+		&vpcv1.Instance{EnableSecureBoot: &yes, ConfidentialComputeMode: &mode},
+		&vpcv1.ImageAllowedUse{Instance: &expr},
+	)
+	if capabilities["secure_boot"] != true || capabilities["confidential_compute_mode"] != "tdx" || capabilities["allowed_use"] != expr {
+		t.Errorf("unexpected capabilities %v", capabilities)
+	}
+
+	capabilities = imageCapabilities(&vpcv1.Instance{}, nil)
+	if len(capabilities) != 0 {
+		t.Errorf("expected no capabilities, got %v", capabilities)
+	}
+}
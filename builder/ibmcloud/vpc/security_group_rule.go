@@ -0,0 +1,155 @@
+package vpc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+)
+
+// The protocols of a security_group_rule. "all" is the VPC API's "any".
+const (
+	ruleProtocolTCP  = "tcp"
+	ruleProtocolUDP  = "udp"
+	ruleProtocolICMP = "icmp"
+	ruleProtocolAll  = "all"
+)
+
+// prepare sets the defaults of the rule and returns what is wrong with it. An
+// inbound rule without a remote is left without one; stepCreateSecurityGroupRules
+// opens it to the communicator's sources instead of to every address.
+func (r *SecurityGroupRule) prepare() []error {
+	var errs []error
+	if r.Direction == "" {
+		r.Direction = "inbound"
+	}
+	if r.Direction != "inbound" && r.Direction != "outbound" {
+		errs = append(errs, fmt.Errorf("direction must be inbound or outbound, not '%s'", r.Direction))
+	}
+	if r.Remote == "" && r.Direction == "outbound" {
+		r.Remote = "0.0.0.0/0"
+	} else if strings.Contains(r.Remote, "/") {
+		if _, _, err := net.ParseCIDR(r.Remote); err != nil {
+			errs = append(errs, fmt.Errorf("remote '%s' is not a CIDR block", r.Remote))
+		}
+	}
+
+	switch r.Protocol {
+	case ruleProtocolTCP, ruleProtocolUDP:
+		if r.PortMax == 0 {
+			r.PortMax = r.PortMin
+		}
+		if r.PortMin < 0 || r.PortMax > 65535 || r.PortMin > r.PortMax || (r.PortMin == 0) != (r.PortMax == 0) {
+			errs = append(errs, fmt.Errorf("port_min %d and port_max %d are not a port range within 1-65535", r.PortMin, r.PortMax))
+		}
+		if r.ICMPType != nil || r.ICMPCode != nil {
+			errs = append(errs, fmt.Errorf("icmp_type and icmp_code cannot be set for %s rules", r.Protocol))
+		}
+	case ruleProtocolICMP:
+		if r.PortMin != 0 || r.PortMax != 0 {
+			errs = append(errs, fmt.Errorf("port_min and port_max cannot be set for icmp rules"))
+		}
+		if r.ICMPType != nil && (*r.ICMPType < 0 || *r.ICMPType > 254) {
+			errs = append(errs, fmt.Errorf("icmp_type must be within 0-254, not %d", *r.ICMPType))
+		}
+		if r.ICMPCode != nil && r.ICMPType == nil {
+			errs = append(errs, fmt.Errorf("icmp_code requires icmp_type"))
+		} else if r.ICMPCode != nil && (*r.ICMPCode < 0 || *r.ICMPCode > 255) {
+			errs = append(errs, fmt.Errorf("icmp_code must be within 0-255, not %d", *r.ICMPCode))
+		}
+	case ruleProtocolAll:
+		if r.PortMin != 0 || r.PortMax != 0 || r.ICMPType != nil || r.ICMPCode != nil {
+			errs = append(errs, fmt.Errorf("ports and icmp_type or icmp_code cannot be set for all rules"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("protocol must be one of tcp, udp, icmp, all, not '%s'", r.Protocol))
+	}
+	return errs
+}
+
+// prototype returns the rule as created in a security group.
+func (r *SecurityGroupRule) prototype() vpcv1.SecurityGroupRulePrototypeIntf {
+	remote := &vpcv1.SecurityGroupRuleRemotePrototype{}
+	if strings.Contains(r.Remote, "/") {
+		remote.CIDRBlock = &r.Remote
+	} else if net.ParseIP(r.Remote) != nil {
+		remote.Address = &r.Remote
+	} else {
+		remote.ID = &r.Remote
+	}
+
+	switch r.Protocol {
+	case ruleProtocolTCP, ruleProtocolUDP:
+		rule := &vpcv1.SecurityGroupRulePrototypeSecurityGroupRuleProtocolTcpudp{
+			Direction: &r.Direction,
+			Protocol:  &r.Protocol,
+			Remote:    remote,
+		}
+		if r.PortMin != 0 {
+			rule.PortMin = &[]int64{int64(r.PortMin)}[0]
+			rule.PortMax = &[]int64{int64(r.PortMax)}[0]
+		}
+		return rule
+	case ruleProtocolICMP:
+		rule := &vpcv1.SecurityGroupRulePrototypeSecurityGroupRuleProtocolIcmp{
+			Direction: &r.Direction,
+			Protocol:  &r.Protocol,
+			Remote:    remote,
+		}
+		if r.ICMPType != nil {
+			rule.Type = &[]int64{int64(*r.ICMPType)}[0]
+		}
+		if r.ICMPCode != nil {
+			rule.Code = &[]int64{int64(*r.ICMPCode)}[0]
+		}
+		return rule
+	default:
+		return &vpcv1.SecurityGroupRulePrototypeSecurityGroupRuleProtocolAnyPrototype{
+			Direction: &r.Direction,
+			Protocol:  &[]string{vpcv1.SecurityGroupRulePrototypeSecurityGroupRuleProtocolAnyPrototypeProtocolAnyConst}[0],
+			Remote:    remote,
+		}
+	}
+}
+
+// String describes the rule in messages.
+func (r *SecurityGroupRule) String() string {
+	traffic := r.Protocol
+	switch {
+	case r.PortMin != 0:
+		traffic = fmt.Sprintf("%s %d-%d", r.Protocol, r.PortMin, r.PortMax)
+	case r.ICMPType != nil:
+		traffic = fmt.Sprintf("icmp type %d", *r.ICMPType)
+	}
+	if r.Direction == "outbound" {
+		return fmt.Sprintf("outbound %s to %s", traffic, r.Remote)
+	}
+	if r.Remote == "" {
+		return fmt.Sprintf("inbound %s from the communicator's sources", traffic)
+	}
+	return fmt.Sprintf("inbound %s from %s", traffic, r.Remote)
+}
+
+// securityGroupRuleID returns the ID of a created rule, of any protocol.
+func securityGroupRuleID(rule vpcv1.SecurityGroupRuleIntf) string {
+	var id *string
+	switch r := rule.(type) {
+	case *vpcv1.SecurityGroupRule:
+		id = r.ID
+	case *vpcv1.SecurityGroupRuleSecurityGroupRuleProtocolTcpudp:
+		id = r.ID
+	case *vpcv1.SecurityGroupRuleSecurityGroupRuleProtocolIcmp:
+		id = r.ID
+	case *vpcv1.SecurityGroupRuleProtocolAny:
+		id = r.ID
+	case *vpcv1.SecurityGroupRuleProtocolIcmptcpudp:
+		id = r.ID
+	case *vpcv1.SecurityGroupRuleProtocolIndividual:
+		id = r.ID
+	}
+	if id == nil {
+		return ""
+	}
+	return *id
+}
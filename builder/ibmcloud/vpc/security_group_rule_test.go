@@ -0,0 +1,206 @@
+package vpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestSecurityGroupRulePrepare(t *testing.T) {
+	icmpType, icmpCode, badType := 8, 0, 255
+	cases := []struct {
+		name    string
+		rule    SecurityGroupRule
+		want    string
+		wantErr string
+	}{
+		{name: "tcp port", rule: SecurityGroupRule{Protocol: "tcp", PortMin: 8443}, want: "inbound tcp 8443-8443 from the communicator's sources"},
+		{name: "outbound without remote", rule: SecurityGroupRule{Direction: "outbound", Protocol: "all"}, want: "outbound all to 0.0.0.0/0"},
+		{name: "udp range outbound", rule: SecurityGroupRule{Direction: "outbound", Protocol: "udp", PortMin: 500, PortMax: 4500, Remote: "10.0.0.0/8"},
+			want: "outbound udp 500-4500 to 10.0.0.0/8"},
+		{name: "icmp echo", rule: SecurityGroupRule{Protocol: "icmp", ICMPType: &icmpType, ICMPCode: &icmpCode, Remote: "198.51.100.7"},
+			want: "inbound icmp type 8 from 198.51.100.7"},
+		{name: "all outbound to a proxy", rule: SecurityGroupRule{Direction: "outbound", Protocol: "all", Remote: "r006-proxy-sg"},
+			want: "outbound all to r006-proxy-sg"},
+		{name: "unknown direction", rule: SecurityGroupRule{Direction: "both", Protocol: "all"}, wantErr: "direction must be inbound or outbound, not 'both'"},
+		{name: "unknown protocol", rule: SecurityGroupRule{Protocol: "gre"}, wantErr: "protocol must be one of tcp, udp, icmp, all, not 'gre'"},
+		{name: "reversed ports", rule: SecurityGroupRule{Protocol: "tcp", PortMin: 9000, PortMax: 8000}, wantErr: "port_min 9000 and port_max 8000 are not a port range"},
+		{name: "port above range", rule: SecurityGroupRule{Protocol: "tcp", PortMin: 70000}, wantErr: "are not a port range within 1-65535"},
+		{name: "icmp with ports", rule: SecurityGroupRule{Protocol: "icmp", PortMin: 22}, wantErr: "port_min and port_max cannot be set for icmp rules"},
+		{name: "icmp code without type", rule: SecurityGroupRule{Protocol: "icmp", ICMPCode: &icmpCode}, wantErr: "icmp_code requires icmp_type"},
+		{name: "icmp type out of range", rule: SecurityGroupRule{Protocol: "icmp", ICMPType: &badType}, wantErr: "icmp_type must be within 0-254, not 255"},
+		{name: "tcp with icmp type", rule: SecurityGroupRule{Protocol: "tcp", PortMin: 22, ICMPType: &icmpType}, wantErr: "icmp_type and icmp_code cannot be set for tcp rules"},
+		{name: "all with ports", rule: SecurityGroupRule{Protocol: "all", PortMin: 22}, wantErr: "cannot be set for all rules"},
+		{name: "invalid CIDR", rule: SecurityGroupRule{Protocol: "all", Remote: "10.0.0.0/33"}, wantErr: "remote '10.0.0.0/33' is not a CIDR block"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := tc.rule
+			errs := rule.prepare()
+			if tc.wantErr != "" {
+				var messages []string
+				for _, err := range errs {
+					messages = append(messages, err.Error())
+				}
+				if !strings.Contains(strings.Join(messages, "; "), tc.wantErr) {
+					t.Errorf("expected error containing %q, got: %v", tc.wantErr, messages)
+				}
+				return
+			}
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if rule.String() != tc.want {
+				t.Errorf("rule = %q, want %q", rule.String(), tc.want)
+			}
+		})
+	}
+}
+
+// fakeSecurityGroup serves a security group sg-1 in which rules are created
+// and deleted.
+type fakeSecurityGroup struct {
+	mu      sync.Mutex
+	created []map[string]interface{}
+	deleted []string
+}
+
+func (f *fakeSecurityGroup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/security_groups/sg-1":
+		fmt.Fprint(w, `{"id":"sg-1","name":"build-sg"}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/security_groups/sg-1/rules":
+		var rule map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&rule)
+		f.created = append(f.created, rule)
+		rule["id"] = fmt.Sprintf("rule-%d", len(f.created))
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(rule)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/security_groups/sg-1/targets/"):
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"vsi-1-nic","resource_type":"network_interface"}`)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/security_groups/sg-1/rules/"):
+		f.deleted = append(f.deleted, strings.TrimPrefix(r.URL.Path, "/security_groups/sg-1/rules/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestStepCreateSecurityGroupRulesCustomRules(t *testing.T) {
+	sg := &fakeSecurityGroup{}
+	srv := httptest.NewServer(sg)
+	defer srv.Close()
+
+	icmpType := 8
+	config := Config{
+		SecurityGroupID:                    "sg-1",
+		SkipCreateDefaultSecurityGroupRule: true,
+		SecurityGroupRules: []SecurityGroupRule{
+			{Direction: "inbound", Protocol: "icmp", ICMPType: &icmpType, Remote: "10.0.0.0/8"},
+			{Direction: "outbound", Protocol: "tcp", PortMin: 3128, PortMax: 3128, Remote: "10.1.2.3"},
+			{Direction: "outbound", Protocol: "all", Remote: "r006-proxy-sg"},
+		},
+	}
+	config.Comm.Type = "ssh"
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packer.TestUi(t))
+	state.Put("vpcService", newTestVpcService(t, srv.URL))
+	state.Put("client", &IBMCloudClient{})
+	state.Put("config", config)
+	state.Put("instance_data", &vpcv1.Instance{
+		ID:                      &[]string{"vsi-1"}[0],
+		PrimaryNetworkInterface: &vpcv1.NetworkInterfaceInstanceContextReference{ID: &[]string{"vsi-1-nic"}[0]},
+	})
+
+	step := new(stepCreateSecurityGroupRules)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected ActionContinue, got %v: %v", action, state.Get("error"))
+	}
+	step.Cleanup(state)
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	var created []string
+	for _, rule := range sg.created {
+		remote, _ := json.Marshal(rule["remote"])
+		created = append(created, fmt.Sprintf("%v %v %s", rule["direction"], rule["protocol"], remote))
+	}
+	want := []string{
+		`inbound icmp {"cidr_block":"10.0.0.0/8"}`,
+		`outbound tcp {"address":"10.1.2.3"}`,
+		`outbound any {"id":"r006-proxy-sg"}`,
+	}
+	if strings.Join(created, "\n") != strings.Join(want, "\n") {
+		t.Errorf("created rules:\n%s\nwant:\n%s", strings.Join(created, "\n"), strings.Join(want, "\n"))
+	}
+	if sg.created[0]["type"] != float64(8) || sg.created[1]["port_min"] != float64(3128) {
+		t.Errorf("the icmp type or tcp ports were not sent: %v", sg.created)
+	}
+	if got := strings.Join(sg.deleted, ","); got != "rule-1,rule-2,rule-3" {
+		t.Errorf("deleted rules = %q, want all three", got)
+	}
+}
+
+// An inbound rule without a remote gets one rule for each of the sources of
+// the communicator's rule, here temporary_security_group_source_cidrs.
+func TestStepCreateSecurityGroupRulesInboundWithoutRemote(t *testing.T) {
+	sg := &fakeSecurityGroup{}
+	srv := httptest.NewServer(sg)
+	defer srv.Close()
+
+	config := Config{
+		SecurityGroupID:                    "sg-1",
+		SkipCreateDefaultSecurityGroupRule: true,
+		TemporarySecurityGroupSourceCIDRs:  []string{"203.0.113.0/24", "198.51.100.0/24"},
+		SecurityGroupRules: []SecurityGroupRule{
+			{Direction: "inbound", Protocol: "tcp", PortMin: 8443, PortMax: 8443},
+		},
+	}
+	config.Comm.Type = "ssh"
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packer.TestUi(t))
+	state.Put("vpcService", newTestVpcService(t, srv.URL))
+	state.Put("client", &IBMCloudClient{})
+	state.Put("config", config)
+	state.Put("instance_data", &vpcv1.Instance{
+		ID:                      &[]string{"vsi-1"}[0],
+		PrimaryNetworkInterface: &vpcv1.NetworkInterfaceInstanceContextReference{ID: &[]string{"vsi-1-nic"}[0]},
+	})
+
+	step := new(stepCreateSecurityGroupRules)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected ActionContinue, got %v: %v", action, state.Get("error"))
+	}
+	step.Cleanup(state)
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	var created []string
+	for _, rule := range sg.created {
+		remote, _ := json.Marshal(rule["remote"])
+		created = append(created, fmt.Sprintf("%v %v %s", rule["direction"], rule["protocol"], remote))
+	}
+	want := []string{
+		`inbound tcp {"cidr_block":"203.0.113.0/24"}`,
+		`inbound tcp {"cidr_block":"198.51.100.0/24"}`,
+	}
+	if strings.Join(created, "\n") != strings.Join(want, "\n") {
+		t.Errorf("created rules:\n%s\nwant:\n%s", strings.Join(created, "\n"), strings.Join(want, "\n"))
+	}
+	if got := strings.Join(sg.deleted, ","); got != "rule-1,rule-2" {
+		t.Errorf("deleted rules = %q, want both", got)
+	}
+}
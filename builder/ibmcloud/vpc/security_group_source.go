@@ -0,0 +1,95 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultPublicIPDiscoveryURL answers with the public IP address a request
+// comes from, as plain text.
+const defaultPublicIPDiscoveryURL = "https://api.ipify.org"
+
+// securityGroupRemote is a remote allowed by the temporary security group
+// rule: a "cidr", an "address" or a security group "id".
+type securityGroupRemote struct {
+	remoteType string
+	value      string
+}
+
+// securityGroupRemotes returns the remotes the communicator is opened to:
+// the security_group_rule_remote_* and temporary_security_group_source_cidrs,
+// else the build host's public IP with
+// temporary_security_group_source_public_ip, else the ssh_bastion_host the
+// build connects through, and else any address.
+func securityGroupRemotes(ctx context.Context, config *Config) ([]securityGroupRemote, error) {
+	var remotes []securityGroupRemote
+	for _, cidr := range config.SecurityGroupRuleRemoteCIDR {
+		remotes = append(remotes, securityGroupRemote{remoteType: "cidr", value: cidr})
+	}
+	for _, cidr := range config.TemporarySecurityGroupSourceCIDRs {
+		remotes = append(remotes, securityGroupRemote{remoteType: "cidr", value: cidr})
+	}
+	for _, addr := range config.SecurityGroupRuleRemoteAddress {
+		remotes = append(remotes, securityGroupRemote{remoteType: "address", value: addr})
+	}
+	for _, id := range config.SecurityGroupRuleRemoteID {
+		remotes = append(remotes, securityGroupRemote{remoteType: "id", value: id})
+	}
+	if len(remotes) > 0 {
+		return remotes, nil
+	}
+
+	if config.TemporarySecurityGroupSourcePublicIP {
+		ip, err := discoverPublicIP(ctx, config.PublicIPDiscoveryURL)
+		if err != nil {
+			return nil, fmt.Errorf("discovering the build host's public IP from %s: %s", config.PublicIPDiscoveryURL, err)
+		}
+		return []securityGroupRemote{{remoteType: "cidr", value: ip + "/32"}}, nil
+	}
+
+	if config.Comm.Type == "ssh" && config.Comm.SSHBastionHost != "" {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", config.Comm.SSHBastionHost)
+		if err != nil {
+			return nil, fmt.Errorf("resolving ssh_bastion_host %s: %s", config.Comm.SSHBastionHost, err)
+		}
+		for _, ip := range ips {
+			remotes = append(remotes, securityGroupRemote{remoteType: "cidr", value: ip.String() + "/32"})
+		}
+		return remotes, nil
+	}
+
+	return []securityGroupRemote{{remoteType: "cidr", value: "0.0.0.0/0"}}, nil
+}
+
+// discoverPublicIP asks url for the IPv4 address the build host's requests
+// come from.
+func discoverPublicIP(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("the answer %q is not an IPv4 address", strings.TrimSpace(string(body)))
+	}
+	return ip.To4().String(), nil
+}
@@ -0,0 +1,108 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestSecurityGroupRemotes(t *testing.T) {
+	answer := "203.0.113.7\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, answer)
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name    string
+		set     func(c *Config)
+		answer  string
+		want    string
+		wantErr string
+	}{
+		{name: "any address", set: func(c *Config) {}, want: "cidr 0.0.0.0/0"},
+		{name: "configured remotes", set: func(c *Config) {
+			c.SecurityGroupRuleRemoteCIDR = []string{"10.0.0.0/8"}
+			c.TemporarySecurityGroupSourceCIDRs = []string{"198.51.100.0/24"}
+			c.SecurityGroupRuleRemoteID = []string{"r006-sg"}
+		}, want: "cidr 10.0.0.0/8,cidr 198.51.100.0/24,id r006-sg"},
+		{name: "public IP", set: func(c *Config) { c.TemporarySecurityGroupSourcePublicIP = true }, answer: "203.0.113.7\n", want: "cidr 203.0.113.7/32"},
+		{name: "public IP not understood", set: func(c *Config) { c.TemporarySecurityGroupSourcePublicIP = true }, answer: "<html>", wantErr: `the answer "<html>" is not an IPv4 address`},
+		{name: "bastion host", set: func(c *Config) { c.Comm.SSHBastionHost = "192.0.2.10" }, want: "cidr 192.0.2.10/32"},
+		{name: "bastion host with configured remotes", set: func(c *Config) {
+			c.Comm.SSHBastionHost = "192.0.2.10"
+			c.TemporarySecurityGroupSourceCIDRs = []string{"192.0.2.0/24"}
+		}, want: "cidr 192.0.2.0/24"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			answer = tc.answer
+			config := &Config{PublicIPDiscoveryURL: srv.URL}
+			config.Comm.Type = "ssh"
+			tc.set(config)
+
+			remotes, err := securityGroupRemotes(context.Background(), config)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var got []string
+			for _, remote := range remotes {
+				got = append(got, remote.remoteType+" "+remote.value)
+			}
+			if strings.Join(got, ",") != tc.want {
+				t.Errorf("remotes = %q, want %q", strings.Join(got, ","), tc.want)
+			}
+		})
+	}
+}
+
+// Every communicator rule is recorded for deletion, so none is left in a
+// security group given by security_group_id.
+func TestStepCreateSecurityGroupRulesDeletesEveryRemote(t *testing.T) {
+	sg := &fakeSecurityGroup{}
+	srv := httptest.NewServer(sg)
+	defer srv.Close()
+
+	config := Config{
+		SecurityGroupID:                   "sg-1",
+		TemporarySecurityGroupSourceCIDRs: []string{"198.51.100.0/24", "203.0.113.0/24"},
+	}
+	config.Comm.Type = "ssh"
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packer.TestUi(t))
+	state.Put("vpcService", newTestVpcService(t, srv.URL))
+	state.Put("client", &IBMCloudClient{})
+	state.Put("config", config)
+	state.Put("instance_data", &vpcv1.Instance{
+		ID:                      &[]string{"vsi-1"}[0],
+		PrimaryNetworkInterface: &vpcv1.NetworkInterfaceInstanceContextReference{ID: &[]string{"vsi-1-nic"}[0]},
+	})
+
+	if action := new(stepCreateSecurityGroupRules).Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected ActionContinue, got %v: %v", action, state.Get("error"))
+	}
+	ruleIDs, _ := state.Get("security_group_rule_ids").([]string)
+	if got := strings.Join(ruleIDs, ","); got != "rule-1,rule-2" {
+		t.Fatalf("security_group_rule_ids = %q, want a rule per CIDR", got)
+	}
+	deleteSecurityGroupRules(state, ruleIDs)
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	if got := strings.Join(sg.deleted, ","); got != "rule-1,rule-2" {
+		t.Errorf("deleted rules = %q, want both", got)
+	}
+}
@@ -6,14 +6,17 @@ import (
 	"log"
 	"regexp"
 
-	"github.com/IBM/go-sdk-core/v5/core"
-	globaltaggingv1 "github.com/IBM/platform-services-go-sdk/globaltaggingv1"
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
-type stepCaptureImage struct{}
+type stepCaptureImage struct {
+	// imageID is set once CreateImage succeeds, so Cleanup knows there is an
+	// image to remove if the build does not complete.
+	imageID string
+}
 
 func (s *stepCaptureImage) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
 	client := state.Get("client").(*IBMCloudClient)
@@ -28,28 +31,6 @@ func (s *stepCaptureImage) Run(_ context.Context, state multistep.StateBag) mult
 	instanceData := state.Get("instance_data").(*vpcv1.Instance)
 	instanceID := *instanceData.ID
 
-	ui.Say(fmt.Sprintf("Stopping instance ID: %s ...", instanceID))
-	status, err := client.manageInstance(instanceID, "stop", state)
-	if err != nil {
-		err := fmt.Errorf("[ERROR] Error stopping the instance: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		// log.Fatalf(err.Error())
-		return multistep.ActionHalt
-	}
-
-	if status != "stopped" {
-		err := client.waitForResourceDown(instanceID, "instances", config.StateTimeout, state)
-		if err != nil {
-			err := fmt.Errorf("[ERROR] Error stopping the instance: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			// log.Fatalf(err.Error())
-			return multistep.ActionHalt
-		}
-	}
-	ui.Say("Instance successfully stopped!")
-
 	ui.Say(fmt.Sprintf("Creating an Image from instance ID: %s ...", instanceID))
 	bootVolumeAttachment := instanceData.BootVolumeAttachment
 	bootVolume := bootVolumeAttachment.Volume
@@ -105,48 +86,22 @@ func (s *stepCaptureImage) Run(_ context.Context, state multistep.StateBag) mult
 	imageId := *imageData.ID
 
 	state.Put("image_id", imageId)
+	s.imageID = imageId
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("ImageID", imageId)
+	generatedData.Put("ImageCRN", stringValue(imageData.CRN))
 
 	ui.Say("Image Successfully created!")
 	ui.Say(fmt.Sprintf("Image's Name: %s", config.ImageName))
 	ui.Say(fmt.Sprintf("Image's ID: %s", imageId))
 
-	if config.ImageTags != nil && len(config.ImageTags) > 0 {
-
-		optGlbTag := globaltaggingv1.GlobalTaggingV1Options{
-			Authenticator: &core.IamAuthenticator{
-				ApiKey: client.IBMApiKey,
-				URL:    config.IAMEndpoint,
-			},
-		}
-		if config.GhostEndpoint != "" {
-			optGlbTag.URL = config.GhostEndpoint
-		}
-		serviceClientOptions, errOpt := globaltaggingv1.NewGlobalTaggingV1(&optGlbTag)
-		if errOpt != nil {
-			err := fmt.Errorf("[ERROR] Error creating global tagging client: %s", errOpt)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-
-		var tagType = new(string)
-		*tagType = "user"
-		resources := []globaltaggingv1.Resource{}
-		r := globaltaggingv1.Resource{ResourceID: imageData.CRN, ResourceType: nil}
-		resources = append(resources, r)
-		AttachTagOptions := &globaltaggingv1.AttachTagOptions{}
-		AttachTagOptions.Resources = resources
-		AttachTagOptions.TagNames = config.ImageTags
-		AttachTagOptions.TagType = tagType
-
-		_, resp, err := serviceClientOptions.AttachTag(AttachTagOptions)
-		if err != nil {
+	if len(config.ImageTags) > 0 {
+		if err := client.attachUserTags(state, config.ImageTags, *imageData.CRN); err != nil {
 			// Tags were explicitly requested, so a tagging failure is a build
-			// failure: halt (like the tagging-client failure above) rather than
-			// returning the image as a successful artifact. (The image already
-			// exists at this point and is not deleted on halt; see the orphaned-image
-			// note in Cleanup.)
-			err := fmt.Errorf("[ERROR] Error attaching tags %v : %s\n%s", config.ImageTags, err, resp)
+			// failure: halt rather than returning the image as a successful
+			// artifact. The image already exists at this point; Cleanup deletes
+			// it unless keep_image_on_failure is set.
+			err := fmt.Errorf("[ERROR] Error tagging the image: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
@@ -163,17 +118,55 @@ func (s *stepCaptureImage) Run(_ context.Context, state multistep.StateBag) mult
 		return multistep.ActionHalt
 	}
 	ui.Say("Image is now AVAILABLE!")
+
+	// The image inherits its allowed_use from the source volume; read it back
+	// to report it with the artifact.
+	var allowedUse *vpcv1.ImageAllowedUse
+	image, _, err := vpcService.GetImage(&vpcv1.GetImageOptions{ID: &imageId})
+	if err != nil {
+		ui.Error(fmt.Sprintf("Warning: could not read the allowed_use of image %s: %s", imageId, err))
+	} else {
+		allowedUse = image.AllowedUse
+	}
+	capabilities := imageCapabilities(instanceData, allowedUse)
+	state.Put("image_capabilities", capabilities)
+	if secureBoot, ok := capabilities["secure_boot"].(bool); ok {
+		ui.Say(fmt.Sprintf("Image captured from an instance with secure boot %s and confidential compute mode %v.", map[bool]string{true: "enabled", false: "disabled"}[secureBoot], capabilities["confidential_compute_mode"]))
+	}
 	return multistep.ActionContinue
 }
 
 func (s *stepCaptureImage) Cleanup(state multistep.StateBag) {
-	// Note: this does not delete the captured image. If the build halts after
-	// CreateImage succeeds (e.g. tag attachment or the AVAILABLE wait fails), the
-	// image is left in the account and must be removed manually.
 	ui := state.Get("ui").(packer.Ui)
 	ui.Say("")
 	ui.Say("****************************************************************************")
 	ui.Say("* Cleaning Up all temporary infrastructure created during packer execution *")
 	ui.Say("****************************************************************************")
 	ui.Say("")
+
+	// If the build halts after CreateImage succeeds (e.g. tag attachment or the
+	// AVAILABLE wait fails), the image is not returned as an artifact, so delete
+	// it here rather than leaving it orphaned in the account.
+	if s.imageID == "" {
+		return
+	}
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+
+	config := state.Get("config").(Config)
+	if config.KeepImageOnFailure {
+		ui.Say(fmt.Sprintf("Build did not complete; keeping image %s (keep_image_on_failure is set). Delete it manually when no longer needed.", s.imageID))
+		return
+	}
+
+	client := state.Get("client").(*IBMCloudClient)
+	ui.Say(fmt.Sprintf("Build did not complete; deleting image %s ...", s.imageID))
+	if err := client.destroyImage(s.imageID, config.StateTimeout, state); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting image %s, it was left in the account and must be deleted manually: %s", s.imageID, err))
+		return
+	}
+	ui.Say(fmt.Sprintf("Image %s deleted.", s.imageID))
 }
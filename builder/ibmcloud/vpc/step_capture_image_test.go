@@ -0,0 +1,51 @@
+package vpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func newCaptureCleanupState(t *testing.T, url string, keep, halted bool) *multistep.BasicStateBag {
+	t.Helper()
+	state := newDestroyImageState(t, url)
+	state.Put("config", Config{StateTimeout: 30 * time.Second, KeepImageOnFailure: keep})
+	state.Put("client", &IBMCloudClient{})
+	if halted {
+		state.Put(multistep.StateHalted, true)
+	}
+	return state
+}
+
+func TestStepCaptureImageCleanup(t *testing.T) {
+	cases := []struct {
+		name        string
+		imageID     string
+		keep        bool
+		halted      bool
+		wantDeletes int32
+	}{
+		{name: "halted build deletes the image", imageID: "img-1", halted: true, wantDeletes: 1},
+		{name: "keep_image_on_failure keeps the image", imageID: "img-1", keep: true, halted: true},
+		{name: "successful build keeps the image", imageID: "img-1"},
+		{name: "no image was created", halted: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var deletes int32
+			srv := httptest.NewServer(imageDeleteHandler(`[]`, http.StatusAccepted, &deletes))
+			defer srv.Close()
+
+			step := &stepCaptureImage{imageID: tc.imageID}
+			step.Cleanup(newCaptureCleanupState(t, srv.URL, tc.keep, tc.halted))
+
+			if got := atomic.LoadInt32(&deletes); got != tc.wantDeletes {
+				t.Errorf("expected %d DELETE calls, got %d", tc.wantDeletes, got)
+			}
+		})
+	}
+}
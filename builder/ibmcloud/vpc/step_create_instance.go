@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"slices"
 	"time"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
@@ -22,18 +24,24 @@ func (step *stepCreateInstance) Run(_ context.Context, state multistep.StateBag)
 	// bake_subnets is the shuffled subnet/zone list from stepGetSubnetInfo. The
 	// builder VSI's profile can intermittently have no host capacity in a given
 	// zone (a capacity status reason; see capacityStatusReasonCodes), so try each
-	// subnet in turn: create the VSI, wait for it to start, and on a capacity
-	// failure delete it and move to the next zone.
-	subnets := state.Get("bake_subnets").([]subnetZone)
-
-	for i, sn := range subnets {
-		if len(subnets) > 1 {
-			ui.Say(fmt.Sprintf("Creating Instance in subnet %s (zone %s) [attempt %d/%d]...", sn.ID, sn.Zone, i+1, len(subnets)))
+	// (profile, subnet) pair in turn: create the VSI, wait for it to start, and
+	// on a capacity failure delete it and move to the next subnet, then to the
+	// next of vsi_profiles once every subnet has failed with the current one.
+	attempts := placementAttempts(config.VSIProfiles, state.Get("bake_subnets").([]subnetZone))
+
+	skipProfile := ""
+	for i, attempt := range attempts {
+		sn := attempt.Subnet
+		if attempt.Profile == skipProfile {
+			continue
+		}
+		if len(attempts) > 1 {
+			ui.Say(fmt.Sprintf("Creating Instance with profile %s in subnet %s (zone %s) [attempt %d/%d]...", attempt.Profile, sn.ID, sn.Zone, i+1, len(attempts)))
 		} else {
 			ui.Say("Creating Instance...")
 		}
 
-		instanceData, err := step.createInstance(state, sn.ID, sn.Zone)
+		instanceData, err := step.createInstance(state, attempt.Profile, sn.ID, sn.Zone)
 		if err != nil {
 			// A create-time error is not the capacity case, so it is fatal rather
 			// than retried in another zone.
@@ -44,6 +52,13 @@ func (step *stepCreateInstance) Run(_ context.Context, state multistep.StateBag)
 		// Record the instance immediately so Cleanup can delete it if the wait
 		// below fails on the final attempt.
 		state.Put("instance_data", instanceData)
+		ledger.RecordCreated(state, TemporaryInstance, *instanceData.ID, *instanceData.Name)
+		if vni := primaryVNI(instanceData); vni != nil {
+			ledger.RecordCreated(state, TemporaryVirtualNetworkInterface, *vni.ID, stringValue(vni.Name))
+		}
+		if err := client.tagTemporaryResources(state, temporaryInstanceCRNs(instanceData)...); err != nil {
+			ui.Error(fmt.Sprintf("Warning: could not tag the Instance, so the cleanup subcommand cannot find it by build ID: %s", err))
+		}
 		ui.Say(fmt.Sprintf("Instance created: %s (%s). Waiting for it to start...", *instanceData.Name, *instanceData.ID))
 
 		waitErr := client.waitForResourceReady(*instanceData.ID, "instances", config.StateTimeout, state)
@@ -52,16 +67,32 @@ func (step *stepCreateInstance) Run(_ context.Context, state multistep.StateBag)
 			return multistep.ActionContinue
 		}
 
-		// Only a capacity/host-placement failure is worth trying another zone; any
-		// other failure (and the last subnet) is fatal.
+		// Only a capacity/host-placement failure is worth another attempt; any
+		// other failure is fatal.
 		var capErr *capacityError
-		if !errors.As(waitErr, &capErr) || i == len(subnets)-1 {
+		if !errors.As(waitErr, &capErr) {
 			state.Put("error", waitErr)
 			ui.Error(waitErr.Error())
 			return multistep.ActionHalt
 		}
+		// A dedicated host, host group or reservation that is full stays full
+		// whatever subnet the next attempt uses, so only another profile can
+		// help.
+		failure := waitErr
+		if err := pinnedCapacityError(&config, attempt.Profile, capErr); err != nil {
+			failure, skipProfile = err, attempt.Profile
+		}
+		if !slices.ContainsFunc(attempts[i+1:], func(a placementAttempt) bool { return a.Profile != skipProfile }) {
+			state.Put("error", failure)
+			ui.Error(failure.Error())
+			return multistep.ActionHalt
+		}
+		if failure != waitErr {
+			ui.Say(fmt.Sprintf("%s. Skipping the remaining subnets for this profile...", failure))
+		} else {
+			ui.Say(fmt.Sprintf("Zone %s could not start a %s instance (%s). Trying the next attempt...", sn.Zone, attempt.Profile, waitErr))
+		}
 
-		ui.Say(fmt.Sprintf("Zone %s could not start the instance (%s). Trying the next subnet...", sn.Zone, waitErr))
 		// Delete the failed VSI before the next attempt, then clear instance_data
 		// so Cleanup does not try to delete an instance that is already gone.
 		if delErr := deleteInstanceAndWait(vpcService(state), ui, *instanceData.ID, config.StateTimeout); delErr != nil {
@@ -69,25 +100,76 @@ func (step *stepCreateInstance) Run(_ context.Context, state multistep.StateBag)
 			ui.Error(delErr.Error())
 			return multistep.ActionHalt
 		}
+		recordInstanceDeleted(state, instanceData)
 		state.Put("instance_data", nil)
 	}
 
-	// Unreachable: Config.Prepare guarantees at least one subnet.
+	// Unreachable: Config.Prepare guarantees at least one profile and subnet.
 	err := fmt.Errorf("[ERROR] no subnets were available to create the builder instance")
 	state.Put("error", err)
 	ui.Error(err.Error())
 	return multistep.ActionHalt
 }
 
+// placementAttempt is one (profile, subnet) pair of the capacity fallback.
+type placementAttempt struct {
+	Profile string
+	Subnet  subnetZone
+}
+
+// placementAttempts orders the (profile, subnet) pairs the capacity fallback
+// walks: every subnet with the first profile, then with the next, so a
+// preferred profile is tried in every zone before the build settles for
+// another one.
+func placementAttempts(profiles []string, subnets []subnetZone) []placementAttempt {
+	attempts := make([]placementAttempt, 0, len(profiles)*len(subnets))
+	for _, profile := range profiles {
+		for _, sn := range subnets {
+			attempts = append(attempts, placementAttempt{Profile: profile, Subnet: sn})
+		}
+	}
+	return attempts
+}
+
+// recordInstanceDeleted marks the instance deleted in the build's ledger,
+// together with its virtual network interface, which is deleted with it.
+func recordInstanceDeleted(state multistep.StateBag, instance *vpcv1.Instance) {
+	ledger.RecordDeleted(state, TemporaryInstance, *instance.ID)
+	if vni := primaryVNI(instance); vni != nil {
+		ledger.RecordDeleted(state, TemporaryVirtualNetworkInterface, *vni.ID)
+	}
+}
+
+// temporaryInstanceCRNs returns the CRNs of the instance and of the virtual
+// network interface created with it. The volumes created with it are tagged by
+// their prototypes (see bootVolumePrototype); a boot volume attached by
+// vsi_boot_volume_id belongs to the user and is left untagged.
+func temporaryInstanceCRNs(instance *vpcv1.Instance) []string {
+	crns := []string{*instance.CRN}
+	if vni := primaryVNI(instance); vni != nil && vni.CRN != nil {
+		crns = append(crns, *vni.CRN)
+	}
+	return crns
+}
+
 // createInstance builds the instance prototype for the configured source
 // (catalog offering, base image, boot volume, or boot snapshot) in the given
 // subnet/zone and creates it. It returns the created instance or an error; it
 // does not wait for the instance to start or mutate build state beyond recording
 // the instance definition.
-func (step *stepCreateInstance) createInstance(state multistep.StateBag, subnetID, zone string) (*vpcv1.Instance, error) {
+func (step *stepCreateInstance) createInstance(state multistep.StateBag, profile, subnetID, zone string) (*vpcv1.Instance, error) {
 	config := state.Get("config").(Config)
 	svc := vpcService(state)
 
+	data, err := userDataValues(state, &config, zone)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := buildUserData(&config, data)
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] Error building the user data: %s", err)
+	}
+
 	vsiBaseImageName := config.VSIBaseImageName
 	vsiBaseImageID := state.Get("baseImageID").(string)
 	vsiCatalogOfferingCrn := config.CatalogOfferingCRN
@@ -95,13 +177,15 @@ func (step *stepCreateInstance) createInstance(state multistep.StateBag, subnetI
 	vsiBootVolumeID := config.VSIBootVolumeID
 	vsiBootSnapshotId := config.VSIBootSnapshotID
 
-	vsiCapacity := config.VSIBootCapacity
-
 	keyIdentityModel := &vpcv1.KeyIdentityByID{
 		ID: &[]string{state.Get("vpc_ssh_key_id").(string)}[0],
 	}
+	keys := []vpcv1.KeyIdentityIntf{keyIdentityModel}
+	for _, id := range config.ExtraSshKeyIDs {
+		keys = append(keys, &vpcv1.KeyIdentityByID{ID: &id})
+	}
 	instanceProfileIdentityModel := &vpcv1.InstanceProfileIdentityByName{
-		Name: &[]string{config.VSIProfile}[0],
+		Name: &profile,
 	}
 	vpcIdentityModel := &vpcv1.VPCIdentityByID{
 		ID: &[]string{state.Get("vpc_id").(string)}[0],
@@ -109,10 +193,7 @@ func (step *stepCreateInstance) createInstance(state multistep.StateBag, subnetI
 	subnetIdentityModel := &vpcv1.SubnetIdentityByID{
 		ID: &[]string{subnetID}[0],
 	}
-	networkInterfacePrototypeModel := &vpcv1.NetworkInterfacePrototype{
-		Name:   &[]string{"my-instance-modified"}[0],
-		Subnet: subnetIdentityModel,
-	}
+	networkInterfacePrototypeModel, networkAttachmentPrototypeModel := primaryNetworkPrototype(&config, subnetIdentityModel)
 	zoneIdentityModel := &vpcv1.ZoneIdentityByName{
 		Name: &[]string{zone}[0],
 	}
@@ -134,24 +215,27 @@ func (step *stepCreateInstance) createInstance(state multistep.StateBag, subnetI
 			catalogOfferingPrototype.Version = versionOffering
 		}
 		instancePrototypeModel := &vpcv1.InstancePrototypeInstanceByCatalogOffering{
-			Keys:                    []vpcv1.KeyIdentityIntf{keyIdentityModel},
-			Name:                    &[]string{config.VSIName}[0],
-			Profile:                 instanceProfileIdentityModel,
-			VPC:                     vpcIdentityModel,
-			PrimaryNetworkInterface: networkInterfacePrototypeModel,
-			Zone:                    zoneIdentityModel,
+			Keys:                     keys,
+			Name:                     &[]string{config.VSIName}[0],
+			Profile:                  instanceProfileIdentityModel,
+			VPC:                      vpcIdentityModel,
+			PrimaryNetworkInterface:  networkInterfacePrototypeModel,
+			PrimaryNetworkAttachment: networkAttachmentPrototypeModel,
+			Zone:                     zoneIdentityModel,
+			MetadataService:          metadataServicePrototype(&config),
+			DefaultTrustedProfile:    defaultTrustedProfilePrototype(&config, state),
+			EnableSecureBoot:         config.EnableSecureBoot,
+			ConfidentialComputeMode:  confidentialComputeModePrototype(&config),
+			PlacementTarget:          placementTargetPrototype(&config),
+			ReservationAffinity:      reservationAffinityPrototype(&config),
 		}
-		if int64(vsiCapacity) != 0 {
-			instancePrototypeModel.BootVolumeAttachment = &vpcv1.VolumeAttachmentPrototypeInstanceByImageContext{
-				Volume: bootVolumePrototype(&config),
-			}
+		instancePrototypeModel.BootVolumeAttachment = &vpcv1.VolumeAttachmentPrototypeInstanceByImageContext{
+			Volume: bootVolumePrototype(&config),
 		}
 		instancePrototypeModel.VolumeAttachments = dataVolumeAttachments(&config)
 		instancePrototypeModel.CatalogOffering = catalogOfferingPrototype
 
-		if err := applyUserData(&config, &instancePrototypeModel.UserData); err != nil {
-			return nil, err
-		}
+		instancePrototypeModel.UserData = userData
 		instancePrototypeModel.ResourceGroup = resourceGroupIdentity(&config, state)
 
 		state.Put("instance_definition", *instancePrototypeModel)
@@ -163,24 +247,27 @@ func (step *stepCreateInstance) createInstance(state multistep.StateBag, subnetI
 			ID: &[]string{vsiBaseImageID}[0],
 		}
 		instancePrototypeModel := &vpcv1.InstancePrototypeInstanceByImage{
-			Keys:                    []vpcv1.KeyIdentityIntf{keyIdentityModel},
-			Name:                    &[]string{config.VSIName}[0],
-			Profile:                 instanceProfileIdentityModel,
-			VPC:                     vpcIdentityModel,
-			Image:                   imageIdentityModel,
-			PrimaryNetworkInterface: networkInterfacePrototypeModel,
-			Zone:                    zoneIdentityModel,
+			Keys:                     keys,
+			Name:                     &[]string{config.VSIName}[0],
+			Profile:                  instanceProfileIdentityModel,
+			VPC:                      vpcIdentityModel,
+			Image:                    imageIdentityModel,
+			PrimaryNetworkInterface:  networkInterfacePrototypeModel,
+			PrimaryNetworkAttachment: networkAttachmentPrototypeModel,
+			Zone:                     zoneIdentityModel,
+			MetadataService:          metadataServicePrototype(&config),
+			DefaultTrustedProfile:    defaultTrustedProfilePrototype(&config, state),
+			EnableSecureBoot:         config.EnableSecureBoot,
+			ConfidentialComputeMode:  confidentialComputeModePrototype(&config),
+			PlacementTarget:          placementTargetPrototype(&config),
+			ReservationAffinity:      reservationAffinityPrototype(&config),
 		}
-		if int64(vsiCapacity) != 0 {
-			instancePrototypeModel.BootVolumeAttachment = &vpcv1.VolumeAttachmentPrototypeInstanceByImageContext{
-				Volume: bootVolumePrototype(&config),
-			}
+		instancePrototypeModel.BootVolumeAttachment = &vpcv1.VolumeAttachmentPrototypeInstanceByImageContext{
+			Volume: bootVolumePrototype(&config),
 		}
 		instancePrototypeModel.VolumeAttachments = dataVolumeAttachments(&config)
 
-		if err := applyUserData(&config, &instancePrototypeModel.UserData); err != nil {
-			return nil, err
-		}
+		instancePrototypeModel.UserData = userData
 		instancePrototypeModel.ResourceGroup = resourceGroupIdentity(&config, state)
 
 		state.Put("instance_definition", *instancePrototypeModel)
@@ -195,19 +282,24 @@ func (step *stepCreateInstance) createInstance(state multistep.StateBag, subnetI
 			Volume: volumeIdentity,
 		}
 		instancePrototypeModel := &vpcv1.InstancePrototypeInstanceByVolume{
-			Keys:                    []vpcv1.KeyIdentityIntf{keyIdentityModel},
-			Name:                    &[]string{config.VSIName}[0],
-			Profile:                 instanceProfileIdentityModel,
-			VPC:                     vpcIdentityModel,
-			BootVolumeAttachment:    bootVolumeAttachment,
-			PrimaryNetworkInterface: networkInterfacePrototypeModel,
-			Zone:                    zoneIdentityModel,
+			Keys:                     keys,
+			Name:                     &[]string{config.VSIName}[0],
+			Profile:                  instanceProfileIdentityModel,
+			VPC:                      vpcIdentityModel,
+			BootVolumeAttachment:     bootVolumeAttachment,
+			PrimaryNetworkInterface:  networkInterfacePrototypeModel,
+			PrimaryNetworkAttachment: networkAttachmentPrototypeModel,
+			Zone:                     zoneIdentityModel,
+			MetadataService:          metadataServicePrototype(&config),
+			DefaultTrustedProfile:    defaultTrustedProfilePrototype(&config, state),
+			EnableSecureBoot:         config.EnableSecureBoot,
+			ConfidentialComputeMode:  confidentialComputeModePrototype(&config),
+			PlacementTarget:          placementTargetPrototype(&config),
+			ReservationAffinity:      reservationAffinityPrototype(&config),
 		}
 		instancePrototypeModel.VolumeAttachments = dataVolumeAttachments(&config)
 
-		if err := applyUserData(&config, &instancePrototypeModel.UserData); err != nil {
-			return nil, err
-		}
+		instancePrototypeModel.UserData = userData
 		instancePrototypeModel.ResourceGroup = resourceGroupIdentity(&config, state)
 
 		state.Put("instance_definition", *instancePrototypeModel)
@@ -222,19 +314,24 @@ func (step *stepCreateInstance) createInstance(state multistep.StateBag, subnetI
 			Volume: snapshotBootVolumePrototype(&config, sourceSnapshot),
 		}
 		instancePrototypeModel := &vpcv1.InstancePrototypeInstanceBySourceSnapshot{
-			Keys:                    []vpcv1.KeyIdentityIntf{keyIdentityModel},
-			Name:                    &[]string{config.VSIName}[0],
-			Profile:                 instanceProfileIdentityModel,
-			VPC:                     vpcIdentityModel,
-			BootVolumeAttachment:    bootVolumeAttachment,
-			PrimaryNetworkInterface: networkInterfacePrototypeModel,
-			Zone:                    zoneIdentityModel,
+			Keys:                     keys,
+			Name:                     &[]string{config.VSIName}[0],
+			Profile:                  instanceProfileIdentityModel,
+			VPC:                      vpcIdentityModel,
+			BootVolumeAttachment:     bootVolumeAttachment,
+			PrimaryNetworkInterface:  networkInterfacePrototypeModel,
+			PrimaryNetworkAttachment: networkAttachmentPrototypeModel,
+			Zone:                     zoneIdentityModel,
+			MetadataService:          metadataServicePrototype(&config),
+			DefaultTrustedProfile:    defaultTrustedProfilePrototype(&config, state),
+			EnableSecureBoot:         config.EnableSecureBoot,
+			ConfidentialComputeMode:  confidentialComputeModePrototype(&config),
+			PlacementTarget:          placementTargetPrototype(&config),
+			ReservationAffinity:      reservationAffinityPrototype(&config),
 		}
 		instancePrototypeModel.VolumeAttachments = dataVolumeAttachments(&config)
 
-		if err := applyUserData(&config, &instancePrototypeModel.UserData); err != nil {
-			return nil, err
-		}
+		instancePrototypeModel.UserData = userData
 		// The snapshot path only supports resource_group_id (no
 		// resource_group_name derivation, unlike the other source paths).
 		if config.ResourceGroupID != "" {
@@ -288,10 +385,12 @@ func (step *stepCreateInstance) Cleanup(state multistep.StateBag) {
 					}
 					if result.StatusCode == 204 {
 						ui.Say("The Floating IP was successfully released!")
+						ledger.RecordDeleted(state, TemporaryFloatingIP, floatingIPID)
 					}
 				}
 			} else if response.StatusCode == 404 {
 				ui.Say("The Floating IP was already deleted or does not exist.")
+				ledger.RecordDeleted(state, TemporaryFloatingIP, floatingIPID)
 			}
 		}
 	}
@@ -308,32 +407,12 @@ func (step *stepCreateInstance) Cleanup(state multistep.StateBag) {
 			ui.Error(err.Error())
 			return
 		}
+		recordInstanceDeleted(state, instanceData)
 	}
 
-	// Deleting Security Group's rule
-	if state.Get("security_group_rule_id") != nil && state.Get("security_group_id") != nil {
-		ruleID := state.Get("security_group_rule_id").(string)
-		securityGroupID := state.Get("security_group_id").(string)
-		ui.Say(fmt.Sprintf("Deleting Security Group's rule %s ...", ruleID))
-		sgRuleOptions := &vpcv1.DeleteSecurityGroupRuleOptions{}
-		sgRuleOptions.SetSecurityGroupID(securityGroupID)
-		sgRuleOptions.SetID(ruleID)
-		sgRuleResponse, sgRuleErr := svc.DeleteSecurityGroupRule(sgRuleOptions)
-
-		if sgRuleErr != nil {
-			// Check if it's a 404 (resource already deleted)
-			if sgRuleResponse != nil && sgRuleResponse.StatusCode == 404 {
-				ui.Say("The Security Group's rule was already deleted or does not exist.")
-			} else {
-				sgRuleErr := fmt.Errorf("[ERROR] Error deleting Security Group's rule %s. Please delete it manually: %s", ruleID, sgRuleErr)
-				state.Put("error", sgRuleErr)
-				ui.Error(sgRuleErr.Error())
-				// log.Fatalf(err.Error())
-				return
-			}
-		} else if sgRuleResponse.StatusCode == 204 {
-			ui.Say("The Security Group's rule was successfully deleted!")
-		}
+	// Deleting the Security Group's rules
+	if ruleIDs, ok := state.Get("security_group_rule_ids").([]string); ok && state.Get("security_group_id") != nil {
+		deleteSecurityGroupRules(state, ruleIDs)
 	}
 
 	// Wait a couple of seconds before attempting to delete the security group.
@@ -352,6 +431,7 @@ func (step *stepCreateInstance) Cleanup(state multistep.StateBag) {
 				// Check if it's a 404 (resource already deleted)
 				if sgResponse != nil && sgResponse.StatusCode == 404 {
 					ui.Say("The Security Group was already deleted or does not exist.")
+					ledger.RecordDeleted(state, TemporarySecurityGroup, securityGroupID)
 				} else {
 					err := fmt.Errorf("[ERROR] Error deleting Security Group %s. Please delete it manually: %s", securityGroupName, err)
 					state.Put("error", err)
@@ -361,12 +441,80 @@ func (step *stepCreateInstance) Cleanup(state multistep.StateBag) {
 				}
 			} else if sgResponse.StatusCode == 204 {
 				ui.Say("The Security Group was successfully deleted!")
+				ledger.RecordDeleted(state, TemporarySecurityGroup, securityGroupID)
 			}
 		}
 	}
 
 }
 
+// primaryNetworkPrototype connects the builder instance to its subnet, either
+// through a legacy network interface or, with vsi_network_attachment set to
+// virtual_network_interface, through a network attachment whose virtual
+// network interface is created with the instance and deleted with it. Exactly
+// one of the two is returned non-nil.
+func primaryNetworkPrototype(config *Config, subnet vpcv1.SubnetIdentityIntf) (*vpcv1.NetworkInterfacePrototype, *vpcv1.InstanceNetworkAttachmentPrototype) {
+	if config.VSINetworkAttachment != networkAttachmentVNI {
+		return &vpcv1.NetworkInterfacePrototype{
+			Name:   &[]string{"my-instance-modified"}[0],
+			Subnet: subnet,
+		}, nil
+	}
+	vni := &vpcv1.InstanceNetworkAttachmentPrototypeVirtualNetworkInterfaceVirtualNetworkInterfacePrototypeInstanceNetworkAttachmentContext{
+		Name:       &[]string{config.VNIName}[0],
+		AutoDelete: &[]bool{true}[0],
+		Subnet:     subnet,
+	}
+	if config.VNIProtocolStateFilteringMode != "" {
+		vni.ProtocolStateFilteringMode = &[]string{config.VNIProtocolStateFilteringMode}[0]
+	}
+	return nil, &vpcv1.InstanceNetworkAttachmentPrototype{
+		VirtualNetworkInterface: vni,
+	}
+}
+
+// primaryVNI returns the virtual network interface of the instance's primary
+// network attachment, or nil for an instance with a legacy network interface.
+func primaryVNI(instance *vpcv1.Instance) *vpcv1.VirtualNetworkInterfaceReferenceAttachmentContext {
+	if instance == nil || instance.PrimaryNetworkAttachment == nil {
+		return nil
+	}
+	return instance.PrimaryNetworkAttachment.VirtualNetworkInterface
+}
+
+// primaryNetworkTargetID returns the ID floating IPs and security groups are
+// bound to: the instance's virtual network interface, or its legacy primary
+// network interface.
+func primaryNetworkTargetID(instance *vpcv1.Instance) string {
+	if vni := primaryVNI(instance); vni != nil {
+		return stringValue(vni.ID)
+	}
+	if instance.PrimaryNetworkInterface != nil {
+		return stringValue(instance.PrimaryNetworkInterface.ID)
+	}
+	return ""
+}
+
+// primarySubnetAndIP returns the subnet and private IP address of the
+// instance's primary network attachment or interface. Either is empty until
+// the API reports it.
+func primarySubnetAndIP(instance *vpcv1.Instance) (subnetID, address string) {
+	var subnet *vpcv1.SubnetReference
+	var ip *vpcv1.ReservedIPReference
+	if attachment := instance.PrimaryNetworkAttachment; attachment != nil {
+		subnet, ip = attachment.Subnet, attachment.PrimaryIP
+	} else if nic := instance.PrimaryNetworkInterface; nic != nil {
+		subnet, ip = nic.Subnet, nic.PrimaryIP
+	}
+	if subnet != nil {
+		subnetID = stringValue(subnet.ID)
+	}
+	if ip != nil {
+		address = stringValue(ip.Address)
+	}
+	return subnetID, address
+}
+
 // vpcService returns the shared vpcv1 client from build state, or nil if it has
 // not been created yet.
 func vpcService(state multistep.StateBag) *vpcv1.VpcV1 {
@@ -386,23 +534,6 @@ func doCreate(svc *vpcv1.VpcV1, prototype vpcv1.InstancePrototypeIntf) (*vpcv1.I
 	return instanceData, nil
 }
 
-// applyUserData sets *dst from vsi_user_data_file or vsi_user_data (mutually
-// exclusive per Config.Prepare). It is a no-op when neither is configured.
-func applyUserData(config *Config, dst **string) error {
-	if config.VSIUserDataFile != "" {
-		content, err := os.ReadFile(config.VSIUserDataFile)
-		if err != nil {
-			return fmt.Errorf("[ERROR] Error reading user data file. Error: %s", err)
-		}
-		*dst = &[]string{string(content)}[0]
-		return nil
-	}
-	if config.VSIUserDataString != "" {
-		*dst = &[]string{config.VSIUserDataString}[0]
-	}
-	return nil
-}
-
 // resourceGroupIdentity resolves the resource group for the instance from
 // resource_group_id, or from the id derived from resource_group_name in
 // stepVerifyInput. Returns nil when neither is configured (the account default
@@ -455,14 +586,18 @@ func deleteInstanceAndWait(svc *vpcv1.VpcV1, ui packer.Ui, instanceID string, ti
 }
 
 func bootVolumePrototype(config *Config) *vpcv1.VolumePrototypeInstanceByImageContext {
-	capacity := int64(config.VSIBootCapacity)
 	profile := "general-purpose"
 	if config.VSIBootProfile != "" {
 		profile = config.VSIBootProfile
 	}
 	vol := &vpcv1.VolumePrototypeInstanceByImageContext{
-		Capacity: &capacity,
 		Profile:  &vpcv1.VolumeProfileIdentity{Name: &profile},
+		UserTags: config.temporaryResourceTags(),
+	}
+	// Without vsi_boot_vol_capacity the volume has the image's minimum size.
+	if config.VSIBootCapacity != 0 {
+		capacity := int64(config.VSIBootCapacity)
+		vol.Capacity = &capacity
 	}
 	// iops/bandwidth are passed through whenever set; Config.Prepare is the gate
 	// that restricts iops to the custom/sdp profiles and bandwidth to sdp, the
@@ -499,6 +634,7 @@ func dataVolumeAttachments(config *Config) []vpcv1.VolumeAttachmentPrototype {
 	vol := &vpcv1.VolumeAttachmentPrototypeVolumeVolumePrototypeInstanceContext{
 		Capacity: &capacity,
 		Profile:  &vpcv1.VolumeProfileIdentity{Name: &profile},
+		UserTags: config.temporaryResourceTags(),
 	}
 	// iops/bandwidth are passed through whenever set; Config.Prepare is the gate
 	// that restricts iops to the custom/sdp profiles and bandwidth to sdp, the
@@ -532,6 +668,7 @@ func snapshotBootVolumePrototype(config *Config, sourceSnapshot vpcv1.SnapshotId
 	vol := &vpcv1.VolumePrototypeInstanceBySourceSnapshotContext{
 		Profile:        &vpcv1.VolumeProfileIdentity{Name: &profile},
 		SourceSnapshot: sourceSnapshot,
+		UserTags:       config.temporaryResourceTags(),
 	}
 	if config.VSIBootCapacity != 0 {
 		capacity := int64(config.VSIBootCapacity)
@@ -0,0 +1,66 @@
+package vpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// Every instance source sets the metadata service, trusted profile, secure
+// boot, confidential compute mode, placement and reservation affinity.
+func TestCreateInstanceSharedOptions(t *testing.T) {
+	var created map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/instances" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		created = nil
+		_ = json.NewDecoder(r.Body).Decode(&created)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"vsi-1"}`))
+	}))
+	defer srv.Close()
+
+	secureBoot := true
+	sources := map[string]func(c *Config){
+		"catalog offering": func(c *Config) { c.CatalogOfferingCRN = "crn:offering" },
+		"image":            func(c *Config) { c.VSIBaseImageID = "r006-image" },
+		"boot volume":      func(c *Config) { c.VSIBootVolumeID = "r006-volume" },
+		"boot snapshot":    func(c *Config) { c.VSIBootSnapshotID = "r006-snapshot" },
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			config := Config{
+				VSIName:                   "builder",
+				MetadataService:           MetadataServiceConfig{Enabled: true},
+				TrustedProfileID:          "Profile-1",
+				EnableSecureBoot:          &secureBoot,
+				ConfidentialComputeMode:   "sgx",
+				DedicatedHostID:           "dh-1",
+				ReservationAffinityPolicy: "manual",
+				ReservationID:             "res-1",
+			}
+			source(&config)
+			state := new(multistep.BasicStateBag)
+			state.Put("config", config)
+			state.Put("vpcService", newTestVpcService(t, srv.URL))
+			state.Put("baseImageID", config.VSIBaseImageID)
+			state.Put("vpc_ssh_key_id", "key-1")
+			state.Put("vpc_id", "vpc-1")
+
+			if _, err := new(stepCreateInstance).createInstance(state, "bx2-2x8", "subnet-1", "us-south-1"); err != nil {
+				t.Fatalf("createInstance: %s", err)
+			}
+			for _, field := range []string{"metadata_service", "default_trusted_profile", "enable_secure_boot", "confidential_compute_mode", "placement_target", "reservation_affinity"} {
+				if _, ok := created[field]; !ok {
+					t.Errorf("%s is not set: %v", field, created)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
@@ -11,7 +13,7 @@ import (
 
 type stepCreateSecurityGroupRules struct{}
 
-func (s *stepCreateSecurityGroupRules) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *stepCreateSecurityGroupRules) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packer.Ui)
 	client := state.Get("client").(*IBMCloudClient)
 	config := state.Get("config").(Config)
@@ -53,6 +55,10 @@ func (s *stepCreateSecurityGroupRules) Run(_ context.Context, state multistep.St
 		state.Put("security_group_id", securityGroupID)
 		securityGroupName := *SecurityGroupData.Name
 		state.Put("security_group_name", securityGroupName)
+		ledger.RecordCreated(state, TemporarySecurityGroup, securityGroupID, securityGroupName)
+		if err := client.tagTemporaryResources(state, *SecurityGroupData.CRN); err != nil {
+			ui.Error(fmt.Sprintf("Warning: could not tag the Temp Security Group, so the cleanup subcommand cannot find it by build ID: %s", err))
+		}
 		ui.Say("Temp Security Group on VPC successfully created!")
 		ui.Say(fmt.Sprintf("Security Group's Name: %s", securityGroupName))
 		ui.Say(fmt.Sprintf("Security Group's ID: %s", securityGroupID))
@@ -74,49 +80,51 @@ func (s *stepCreateSecurityGroupRules) Run(_ context.Context, state multistep.St
 		ui.Say(fmt.Sprintf("Security group with name %s and ID %s found.", securityGroupName, securityGroupID))
 	}
 
+	// The communicator's rule and the inbound security_group_rule blocks
+	// without a remote are opened to the same sources.
+	needRemotes := !config.SkipCreateDefaultSecurityGroupRule
+	for _, rule := range config.SecurityGroupRules {
+		if rule.Remote == "" {
+			needRemotes = true
+		}
+	}
+	var remotes []securityGroupRemote
+	if needRemotes {
+		var err error
+		remotes, err = securityGroupRemotes(ctx, &config)
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error choosing the sources of the Security Group's rule: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	// Check if we should skip creating the default rule
 	if config.SkipCreateDefaultSecurityGroupRule {
 		ui.Say(fmt.Sprintf("Skipping default security group rule creation (skip_create_default_security_group_rule=true)"))
 		ui.Say(fmt.Sprintf("Ensure your security group has appropriate rules for %s connectivity", config.Comm.Type))
 	} else {
-		// Collect all remote configurations
-		type remoteConfig struct {
-			remoteType string // "cidr", "address", or "id"
-			value      string
-		}
-		var remotes []remoteConfig
-
-		// Add CIDR blocks
-		for _, cidr := range config.SecurityGroupRuleRemoteCIDR {
-			remotes = append(remotes, remoteConfig{remoteType: "cidr", value: cidr})
-		}
-
-		// Add addresses
-		for _, addr := range config.SecurityGroupRuleRemoteAddress {
-			remotes = append(remotes, remoteConfig{remoteType: "address", value: addr})
-		}
-
-		// Add security group IDs
-		for _, id := range config.SecurityGroupRuleRemoteID {
-			remotes = append(remotes, remoteConfig{remoteType: "id", value: id})
-		}
-
-		// Default to allowing all IPs if no remotes specified
-		if len(remotes) == 0 {
-			remotes = []remoteConfig{{remoteType: "cidr", value: "0.0.0.0/0"}}
-			ui.Say(fmt.Sprintf("Creating Security Group's rule to allow %s connection from all IPs (0.0.0.0/0)...", config.Comm.Type))
-		} else {
-			ui.Say(fmt.Sprintf("Creating Security Group's rule to allow %s connection from specified remotes...", config.Comm.Type))
-		}
+		ui.Say(fmt.Sprintf("Creating Security Group's rule to allow %s connection from %d remote(s)...", config.Comm.Type, len(remotes)))
 
 		// Create a rule for each remote configuration
-		for i, remote := range remotes {
+		var ruleIDs []string
+		for _, remote := range remotes {
 			securityGroupRuleRequest := &vpcv1.CreateSecurityGroupRuleOptions{}
 			securityGroupRuleRequest.SetSecurityGroupID(state.Get("security_group_id").(string))
 
 			var rulePrototype *vpcv1.SecurityGroupRulePrototypeSecurityGroupRuleProtocolTcpudp
 
-			if config.Comm.Type == "winrm" {
+			if config.Comm.Type == "winrm" && config.WinRMBootstrap {
+				// Only the HTTPS listener enabled by winrm_bootstrap is
+				// opened.
+				rulePrototype = &vpcv1.SecurityGroupRulePrototypeSecurityGroupRuleProtocolTcpudp{
+					Direction: &[]string{"inbound"}[0],
+					Protocol:  &[]string{"tcp"}[0],
+					PortMin:   &[]int64{winRMBootstrapPort}[0],
+					PortMax:   &[]int64{winRMBootstrapPort}[0],
+				}
+			} else if config.Comm.Type == "winrm" {
 				// Create rule to allow WinRM connection
 				// Connection to Windows-based VSIs via WinRM
 				// Protocol: TCP, Port range: 5985-5986
@@ -154,7 +162,7 @@ func (s *stepCreateSecurityGroupRules) Run(_ context.Context, state multistep.St
 
 			securityGroupRuleRequest.SetSecurityGroupRulePrototype(rulePrototype)
 
-			ruleData, err2 := client.createRule(*securityGroupRuleRequest, state)
+			ruleID, err2 := client.createRule(*securityGroupRuleRequest, state)
 			if err2 != nil {
 				err := fmt.Errorf("[ERROR] Error creating a new Security Group's rule for %s %s: %s", remote.remoteType, remote.value, err2)
 				state.Put("error", err)
@@ -162,20 +170,50 @@ func (s *stepCreateSecurityGroupRules) Run(_ context.Context, state multistep.St
 				return multistep.ActionHalt
 			}
 
-			ruleID := *ruleData.ID
-			// Store the first rule ID for backward compatibility
-			if i == 0 {
-				state.Put("security_group_rule_id", ruleID)
-			}
+			// Every rule is deleted in stepCreateInstance's Cleanup, also from a
+			// security group given by security_group_id.
+			ruleIDs = append(ruleIDs, ruleID)
+			state.Put("security_group_rule_ids", ruleIDs)
 			ui.Say(fmt.Sprintf("Security Group's rule to allow %s connection from %s %s successfully created (ID: %s)", config.Comm.Type, remote.remoteType, remote.value, ruleID))
 		}
 	}
 
-	// Attaching the VSI to the Security Group via primary_network_interface
+	// The security_group_rule blocks, deleted again in Cleanup
+	var customRuleIDs []string
+	for _, configured := range config.SecurityGroupRules {
+		rules := []SecurityGroupRule{configured}
+		if configured.Remote == "" {
+			// One rule for each of the communicator's sources
+			rules = nil
+			for _, remote := range remotes {
+				rule := configured
+				rule.Remote = remote.value
+				rules = append(rules, rule)
+			}
+		}
+		for i := range rules {
+			rule := &rules[i]
+			options := &vpcv1.CreateSecurityGroupRuleOptions{}
+			options.SetSecurityGroupID(state.Get("security_group_id").(string))
+			options.SetSecurityGroupRulePrototype(rule.prototype())
+			ruleID, err := client.createRule(*options, state)
+			if err != nil {
+				err := fmt.Errorf("[ERROR] Error creating the Security Group's rule %s: %s", rule, err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			customRuleIDs = append(customRuleIDs, ruleID)
+			state.Put("security_group_custom_rule_ids", customRuleIDs)
+			ui.Say(fmt.Sprintf("Security Group's rule to allow %s successfully created (ID: %s)", rule, ruleID))
+		}
+	}
+
+	// Attaching the VSI to the Security Group via its primary network interface
+	// or virtual network interface
 	ui.Say("Attaching Instance to the Security Group")
 	instanceData := state.Get("instance_data").(*vpcv1.Instance)
-	primaryNetworkInterfaceID := *instanceData.PrimaryNetworkInterface.ID
-	_, err := client.addNetworkInterfaceToSecurityGroup(state.Get("security_group_id").(string), primaryNetworkInterfaceID, state)
+	_, err := client.addNetworkInterfaceToSecurityGroup(state.Get("security_group_id").(string), primaryNetworkTargetID(instanceData), state)
 	if err != nil {
 		err := fmt.Errorf("[ERROR] Error Adding Network Interface To Security Group: %s", err)
 		state.Put("error", err)
@@ -189,9 +227,38 @@ func (s *stepCreateSecurityGroupRules) Run(_ context.Context, state multistep.St
 }
 
 func (s *stepCreateSecurityGroupRules) Cleanup(state multistep.StateBag) {
-	// client := state.Get("client").(*IBMCloudClient)
-	// ui := state.Get("ui").(packer.Ui)
-	// config := state.Get("config").(Config)
 	// Security Group is deleted on `step_create_instance` Cleanup() due to a conflict when deleting Security Group: Seems the VSI becomes
 	// an `Attached resources` to a Security Group, so first is required to delete the VSI, before attempting to delete the Security Group.
+	// The security_group_rule blocks are deleted here, so none is left in a
+	// security group given by security_group_id.
+	customRuleIDs, ok := state.GetOk("security_group_custom_rule_ids")
+	if !ok {
+		return
+	}
+	deleteSecurityGroupRules(state, customRuleIDs.([]string))
+}
+
+// deleteSecurityGroupRules deletes rules the build created in its security
+// group. A rule that cannot be deleted is reported, and the others are still
+// deleted.
+func deleteSecurityGroupRules(state multistep.StateBag, ruleIDs []string) {
+	ui := state.Get("ui").(packer.Ui)
+	svc := vpcService(state)
+	securityGroupID := state.Get("security_group_id").(string)
+	for _, ruleID := range ruleIDs {
+		ui.Say(fmt.Sprintf("Deleting Security Group's rule %s ...", ruleID))
+		options := svc.NewDeleteSecurityGroupRuleOptions(securityGroupID, ruleID)
+		response, err := svc.DeleteSecurityGroupRule(options)
+		if err != nil {
+			if response != nil && response.StatusCode == 404 {
+				ui.Say("The Security Group's rule was already deleted or does not exist.")
+				continue
+			}
+			err := fmt.Errorf("[ERROR] Error deleting Security Group's rule %s. Please delete it manually: %s", ruleID, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			continue
+		}
+		ui.Say("The Security Group's rule was successfully deleted!")
+	}
 }
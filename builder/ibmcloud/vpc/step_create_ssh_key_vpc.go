@@ -1,20 +1,67 @@
 package vpc
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"time"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
 )
 
-type stepCreateSshKeyVPC struct{}
+type stepCreateSshKeyVPC struct {
+	// existing is set when the build uses a VPC key of the user's, which it
+	// must not delete.
+	existing bool
+}
 
 func (s *stepCreateSshKeyVPC) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packer.Ui)
 	client := state.Get("client").(*IBMCloudClient)
+	config := state.Get("config").(Config)
+	vpcService := state.Get("vpcService").(*vpcv1.VpcV1)
+
+	for _, id := range config.ExtraSshKeyIDs {
+		if _, _, err := vpcService.GetKey(&vpcv1.GetKeyOptions{ID: &id}); err != nil {
+			err := fmt.Errorf("[ERROR] Error fetching the SSH key %s of vpc_ssh_key_ids: %s", id, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if config.SshKeyID != "" || config.SshKeyName != "" {
+		key, err := existingKey(vpcService, config.SshKeyID, config.SshKeyName)
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error fetching the existing SSH key for VPC: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		publicKey, err := os.ReadFile(state.Get("PUBLIC_KEY").(string))
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error reading SSH Public Key. Error: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if !sameAuthorizedKey(stringValue(key.PublicKey), string(publicKey)) {
+			err := fmt.Errorf("[ERROR] SSH key %s (%s) is not the public key of ssh_private_key_file %s", stringValue(key.Name), stringValue(key.ID), config.Comm.SSHPrivateKeyFile)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.existing = true
+		state.Put("vpc_ssh_key_id", *key.ID)
+		ui.Say(fmt.Sprintf("Using the existing SSH key for VPC %s (ID: %s); it will not be deleted.", stringValue(key.Name), *key.ID))
+		return multistep.ActionContinue
+	}
 
 	ui.Say("Creating a new SSH key for VPC...")
 	VPCSSHKeyData, err := client.createSSHKeyVPC(state)
@@ -31,6 +78,11 @@ func (s *stepCreateSshKeyVPC) Run(_ context.Context, state multistep.StateBag) m
 	state.Put("vpc_ssh_key_id", VPCSSHKeyID)
 	VPCSSHKeyName := *VPCSSHKeyData.Name
 	state.Put("vpc_ssh_key_name", VPCSSHKeyName)
+	ledger.RecordCreated(state, TemporaryKey, VPCSSHKeyID, VPCSSHKeyName)
+
+	if err := client.tagTemporaryResources(state, *VPCSSHKeyData.CRN); err != nil {
+		ui.Error(fmt.Sprintf("Warning: could not tag the SSH Key for VPC, so the cleanup subcommand cannot find it by build ID: %s", err))
+	}
 
 	ui.Say("SSH Key for VPC successfully created!")
 	ui.Say(fmt.Sprintf("SSH Key for VPC's Name: %s", VPCSSHKeyName))
@@ -41,6 +93,9 @@ func (s *stepCreateSshKeyVPC) Run(_ context.Context, state multistep.StateBag) m
 func (s *stepCreateSshKeyVPC) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packer.Ui)
 
+	if s.existing {
+		return
+	}
 	// Check if VPC SSH key exists in state before attempting deletion
 	if state.Get("vpc_ssh_key_name") == nil || state.Get("vpc_ssh_key_id") == nil {
 		return
@@ -64,6 +119,7 @@ func (s *stepCreateSshKeyVPC) Cleanup(state multistep.StateBag) {
 		// Check if it's a 404 (resource already deleted)
 		if response != nil && response.StatusCode == 404 {
 			ui.Say("The SSH key was already deleted or does not exist.")
+			ledger.RecordDeleted(state, TemporaryKey, vpcSSHKeyID)
 		} else {
 			err := fmt.Errorf("[ERROR] Error deleting SSH key for VPC %s. Please delete it manually: %s", vpcSSHKeyName, err)
 			state.Put("error", err)
@@ -73,7 +129,47 @@ func (s *stepCreateSshKeyVPC) Cleanup(state multistep.StateBag) {
 		}
 	} else if response.StatusCode == 204 {
 		ui.Say("The Key was successfully deleted!")
+		ledger.RecordDeleted(state, TemporaryKey, vpcSSHKeyID)
 	} else {
 		ui.Say("The key could not be deleted. Please delete it manually!")
 	}
 }
+
+// existingKey fetches the VPC key with the ID, or else the one with the name.
+func existingKey(svc *vpcv1.VpcV1, id, name string) (*vpcv1.Key, error) {
+	if id != "" {
+		key, _, err := svc.GetKey(&vpcv1.GetKeyOptions{ID: &id})
+		if err != nil {
+			return nil, fmt.Errorf("fetching SSH key %s: %s", id, err)
+		}
+		return key, nil
+	}
+	pager, err := svc.NewKeysPager(&vpcv1.ListKeysOptions{})
+	if err != nil {
+		return nil, err
+	}
+	keys, err := pager.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("listing SSH keys: %s", err)
+	}
+	for i := range keys {
+		if stringValue(keys[i].Name) == name {
+			return &keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no SSH key is named %s", name)
+}
+
+// sameAuthorizedKey reports whether two public keys in the authorized_keys
+// format are the same key, whatever their comments.
+func sameAuthorizedKey(a, b string) bool {
+	keyA, _, _, _, err := ssh.ParseAuthorizedKey([]byte(a))
+	if err != nil {
+		return false
+	}
+	keyB, _, _, _, err := ssh.ParseAuthorizedKey([]byte(b))
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(keyA.Marshal(), keyB.Marshal())
+}
@@ -0,0 +1,94 @@
+package vpc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeTestPrivateKey writes a new ed25519 private key in the OpenSSH format
+// and returns its path and its public key in the authorized_keys format.
+func writeTestPrivateKey(t *testing.T) (string, string) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return path, string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+}
+
+func TestExistingKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/keys/key-1":
+			_, _ = w.Write([]byte(`{"id":"key-1","name":"vault-key","public_key":"ssh-ed25519 AAAA"}`))
+		case "/keys":
+			_, _ = w.Write([]byte(`{"keys":[{"id":"key-0","name":"other-key"},{"id":"key-1","name":"vault-key"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"not_found","message":"Key not found"}]}`))
+		}
+	}))
+	defer srv.Close()
+	svc := newTestVpcService(t, srv.URL)
+
+	for _, tc := range []struct {
+		id, name, wantID, wantErr string
+	}{
+		{id: "key-1", wantID: "key-1"},
+		{name: "vault-key", wantID: "key-1"},
+		{id: "key-missing", wantErr: "fetching SSH key key-missing"},
+		{name: "missing-key", wantErr: "no SSH key is named missing-key"},
+	} {
+		key, err := existingKey(svc, tc.id, tc.name)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("id=%q name=%q: expected error containing %q, got: %v", tc.id, tc.name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("id=%q name=%q: unexpected error: %s", tc.id, tc.name, err)
+			continue
+		}
+		if stringValue(key.ID) != tc.wantID {
+			t.Errorf("id=%q name=%q: got key %s, want %s", tc.id, tc.name, stringValue(key.ID), tc.wantID)
+		}
+	}
+}
+
+func TestSameAuthorizedKey(t *testing.T) {
+	_, publicKey := writeTestPrivateKey(t)
+	_, otherKey := writeTestPrivateKey(t)
+
+	if !sameAuthorizedKey(strings.TrimSpace(publicKey)+" vault@example.com", publicKey) {
+		t.Error("the same key with another comment should match")
+	}
+	if sameAuthorizedKey(publicKey, otherKey) {
+		t.Error("different keys should not match")
+	}
+	if sameAuthorizedKey("not a key", publicKey) {
+		t.Error("an unparsable key should not match")
+	}
+}
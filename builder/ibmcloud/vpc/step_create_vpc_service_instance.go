@@ -56,17 +56,7 @@ func (step *StepCreateVPCServiceInstance) Run(_ context.Context, state multistep
 		core.SetLogger(core.NewLogger(logLevel, goLogger, goLogger))
 	}
 
-	authenticator := &core.IamAuthenticator{
-		ApiKey: client.IBMApiKey,
-		URL:    config.IAMEndpoint,
-	}
-
-	options := &vpcv1.VpcV1Options{
-		Authenticator: authenticator,
-		URL:           config.Endpoint,
-	}
-	vpcService, serviceErr := vpcv1.NewVpcV1(options)
-
+	vpcService, serviceErr := NewVPCService(client.IBMApiKey, config.IAMEndpoint, config.Endpoint)
 	if serviceErr != nil {
 		err := fmt.Errorf("[ERROR] Error creating VPC service %s", serviceErr)
 		state.Put("error", err)
@@ -74,8 +64,6 @@ func (step *StepCreateVPCServiceInstance) Run(_ context.Context, state multistep
 		return multistep.ActionHalt
 	}
 
-	vpcService.EnableRetries(vpcRetryMaxAttempts, vpcRetryMaxInterval)
-
 	state.Put("vpcService", vpcService)
 	ui.Say("VPC service creation successful!")
 	return multistep.ActionContinue
@@ -83,3 +71,26 @@ func (step *StepCreateVPCServiceInstance) Run(_ context.Context, state multistep
 
 func (step *StepCreateVPCServiceInstance) Cleanup(state multistep.StateBag) {
 }
+
+// NewVPCService builds a vpcv1 client for endpoint, authenticated with the IBM
+// Cloud API key and with the SDK request retries enabled. It is shared by the
+// build (StepCreateVPCServiceInstance), by Artifact.Destroy, which runs after
+// the build's state bag is gone, and by the vpc-image data source.
+func NewVPCService(apiKey, iamURL, endpoint string) (*vpcv1.VpcV1, error) {
+	authenticator := &core.IamAuthenticator{
+		ApiKey: apiKey,
+		URL:    iamURL,
+	}
+
+	options := &vpcv1.VpcV1Options{
+		Authenticator: authenticator,
+		URL:           endpoint,
+	}
+	vpcService, err := vpcv1.NewVpcV1(options)
+	if err != nil {
+		return nil, err
+	}
+
+	vpcService.EnableRetries(vpcRetryMaxAttempts, vpcRetryMaxInterval)
+	return vpcService, nil
+}
@@ -0,0 +1,150 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The built-in profiles of generalize.
+const (
+	generalizeLinux   = "linux"
+	generalizeWindows = "windows"
+)
+
+// Where the generalize script and the unattend file are uploaded to.
+const (
+	linuxGeneralizeScript   = "/tmp/packer-generalize.sh"
+	windowsGeneralizeScript = `C:\Windows\Temp\packer-generalize.ps1`
+	windowsUnattendFile     = `C:\Windows\Temp\packer-unattend.xml`
+)
+
+// linuxGeneralizeProfile removes what makes an instance unique, so every
+// instance of the image gets its own identity on first boot.
+const linuxGeneralizeProfile = `if command -v cloud-init >/dev/null 2>&1; then
+  cloud-init clean --logs --seed
+fi
+rm -f /etc/ssh/ssh_host_*
+if [ -f /etc/machine-id ]; then
+  truncate -s 0 /etc/machine-id
+fi
+rm -f /root/.bash_history /home/*/.bash_history
+`
+
+// windowsGeneralizeProfile runs sysprep, with the uploaded unattend file or
+// else the one of Cloudbase-Init, which IBM Cloud's Windows images run at
+// first boot. Sysprep quits rather than shuts down: the instance is stopped by
+// stepShutdown.
+const windowsGeneralizeProfile = `$unattend = '%s'
+if (-not (Test-Path $unattend)) {
+  $unattend = "$env:ProgramFiles\Cloudbase Solutions\Cloudbase-Init\conf\Unattend.xml"
+}
+$arguments = @('/generalize', '/oobe', '/quit', '/quiet')
+if (Test-Path $unattend) {
+  $arguments += "/unattend:$unattend"
+}
+$sysprep = Start-Process -FilePath "$env:SystemRoot\System32\Sysprep\sysprep.exe" -ArgumentList $arguments -Wait -PassThru
+if ($sysprep.ExitCode -ne 0) {
+  throw "sysprep exited with code $($sysprep.ExitCode)"
+}
+`
+
+// stepGeneralize cleans the instance before its image is captured, so the
+// image is safe to instantiate many times: generalize_commands run first, then
+// the built-in generalize profile, in one script run through the
+// communicator. A generalized instance is not rebooted by stepRebootInstance:
+// booting would give it a new identity again.
+type stepGeneralize struct{}
+
+func (s *stepGeneralize) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	if config.Generalize == "" && len(config.GeneralizeCommands) == 0 {
+		return multistep.ActionContinue
+	}
+	comm := state.Get("communicator").(packer.Communicator)
+
+	ui.Say("Generalizing the instance before capture...")
+	if config.GeneralizeUnattendFile != "" {
+		unattend, err := os.Open(config.GeneralizeUnattendFile)
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error reading generalize_unattend_file: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		defer unattend.Close()
+		if err := comm.Upload(windowsUnattendFile, unattend, nil); err != nil {
+			err := fmt.Errorf("[ERROR] Error uploading generalize_unattend_file: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	path, script, command := generalizeScript(config)
+	if err := comm.Upload(path, strings.NewReader(script), nil); err != nil {
+		err := fmt.Errorf("[ERROR] Error uploading the generalize script: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		err := fmt.Errorf("[ERROR] Error running the generalize script: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if status := cmd.ExitStatus(); status != 0 {
+		err := fmt.Errorf("[ERROR] The generalize script exited with status %d", status)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if config.Generalize != "" {
+		state.Put("generalized", true)
+	}
+	ui.Say("Instance successfully generalized!")
+	return multistep.ActionContinue
+}
+
+func (s *stepGeneralize) Cleanup(state multistep.StateBag) {}
+
+// generalizeScript returns where to upload the generalize script, the script,
+// and the command that runs it. The script stops at the first command that
+// fails, and deletes itself.
+func generalizeScript(config Config) (path, script, command string) {
+	var b strings.Builder
+	if config.Comm.Type == "winrm" {
+		b.WriteString("$ErrorActionPreference = 'Stop'\n")
+		for _, c := range config.GeneralizeCommands {
+			b.WriteString(c + "\n")
+		}
+		b.WriteString("Remove-Item -Force $PSCommandPath\n")
+		if config.Generalize == generalizeWindows {
+			fmt.Fprintf(&b, windowsGeneralizeProfile, windowsUnattendFile)
+		}
+		return windowsGeneralizeScript, b.String(),
+			"powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File " + windowsGeneralizeScript
+	}
+
+	b.WriteString("#!/bin/sh\nset -e\nrm -f \"$0\"\n")
+	for _, c := range config.GeneralizeCommands {
+		b.WriteString(c + "\n")
+	}
+	if config.Generalize == generalizeLinux {
+		b.WriteString(linuxGeneralizeProfile)
+	}
+	b.WriteString("sync\n")
+	command = "sh " + linuxGeneralizeScript
+	if config.Comm.SSHUsername != "root" {
+		command = "sudo -n " + command
+	}
+	return linuxGeneralizeScript, b.String(), command
+}
@@ -0,0 +1,82 @@
+package vpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepGeneralize(t *testing.T) {
+	cases := []struct {
+		name            string
+		set             func(c *Config)
+		exitStatus      int
+		wantAction      multistep.StepAction
+		wantCommand     string
+		wantScript      []string
+		wantGeneralized bool
+	}{
+		{name: "nothing to run", set: func(c *Config) {}, wantAction: multistep.ActionContinue},
+		{name: "linux profile as a sudoer", set: func(c *Config) {
+			c.Comm.SSHUsername = "ubuntu"
+			c.Generalize = generalizeLinux
+			c.GeneralizeCommands = []string{"apt-get clean"}
+		}, wantAction: multistep.ActionContinue, wantCommand: "sudo -n sh /tmp/packer-generalize.sh",
+			wantScript: []string{"set -e", "apt-get clean", "cloud-init clean --logs --seed", "rm -f /etc/ssh/ssh_host_*", "truncate -s 0 /etc/machine-id"}, wantGeneralized: true},
+		{name: "commands only", set: func(c *Config) { c.GeneralizeCommands = []string{"rm -rf /var/cache/build"} },
+			wantAction: multistep.ActionContinue, wantCommand: "sh /tmp/packer-generalize.sh", wantScript: []string{"rm -rf /var/cache/build"}},
+		{name: "windows profile", set: func(c *Config) {
+			c.Comm.Type = "winrm"
+			c.Generalize = generalizeWindows
+			c.GeneralizeCommands = []string{"Remove-Item -Recurse C:\\build"}
+		}, wantAction: multistep.ActionContinue, wantCommand: `powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File C:\Windows\Temp\packer-generalize.ps1`,
+			wantScript: []string{"Remove-Item -Recurse C:\\build", `$unattend = 'C:\Windows\Temp\packer-unattend.xml'`, "'/generalize', '/oobe', '/quit'", "Sysprep\\sysprep.exe"}, wantGeneralized: true},
+		{name: "failing script", set: func(c *Config) { c.Generalize = generalizeLinux }, exitStatus: 1,
+			wantAction: multistep.ActionHalt, wantCommand: "sh /tmp/packer-generalize.sh"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := Config{}
+			config.Comm.Type = "ssh"
+			config.Comm.SSHUsername = "root"
+			tc.set(&config)
+			comm := &packer.MockCommunicator{StartExitStatus: tc.exitStatus}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packer.TestUi(t))
+			state.Put("config", config)
+			state.Put("communicator", comm)
+
+			if action := new(stepGeneralize).Run(context.Background(), state); action != tc.wantAction {
+				t.Fatalf("expected %v, got %v: %v", tc.wantAction, action, state.Get("error"))
+			}
+			if tc.wantCommand == "" {
+				if comm.UploadCalled || comm.StartCalled {
+					t.Errorf("nothing should run, got upload %q and command %v", comm.UploadPath, comm.StartCmd)
+				}
+				return
+			}
+			if !comm.StartCalled || comm.StartCmd.Command != tc.wantCommand {
+				t.Fatalf("ran %v, want %q", comm.StartCmd, tc.wantCommand)
+			}
+			if !strings.HasSuffix(tc.wantCommand, comm.UploadPath) {
+				t.Errorf("uploaded the script to %s, but ran %q", comm.UploadPath, tc.wantCommand)
+			}
+			last := -1
+			for _, want := range tc.wantScript {
+				i := strings.Index(comm.UploadData, want)
+				if i < 0 {
+					t.Errorf("the script does not contain %q:\n%s", want, comm.UploadData)
+				} else if i < last {
+					t.Errorf("%q is out of order in the script:\n%s", want, comm.UploadData)
+				}
+				last = i
+			}
+			if _, generalized := state.GetOk("generalized"); generalized != tc.wantGeneralized {
+				t.Errorf("generalized = %t, want %t", generalized, tc.wantGeneralized)
+			}
+		})
+	}
+}
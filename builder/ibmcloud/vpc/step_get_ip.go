@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 type stepGetIP struct{}
@@ -22,10 +25,8 @@ func (step *stepGetIP) Run(_ context.Context, state multistep.StateBag) multiste
 	ui.Say(fmt.Sprintf("Getting %s IP...", config.VSIInterface))
 	var ipAddress string
 	if config.VSIInterface == "private" {
-		primaryNetworkInterface := instanceData.PrimaryNetworkInterface
-
 		// Post 3/29/22 Reserved IP P2
-		ipAddress = *primaryNetworkInterface.PrimaryIP.Address
+		_, ipAddress = primarySubnetAndIP(instanceData)
 
 	} else if config.VSIInterface == "public" {
 		ui.Say("Reserve a Floating IP and associate it to the instance's network interface")
@@ -45,6 +46,10 @@ func (step *stepGetIP) Run(_ context.Context, state multistep.StateBag) multiste
 		ui.Say("Waiting for the Floating IP to become ACTIVE...")
 		floatingIPID := *floatingIPData.ID
 		state.Put("floating_ip_id", floatingIPID)
+		ledger.RecordCreated(state, TemporaryFloatingIP, floatingIPID, stringValue(floatingIPData.Name))
+		if err := client.tagTemporaryResources(state, *floatingIPData.CRN); err != nil {
+			ui.Error(fmt.Sprintf("Warning: could not tag the Floating IP, so the cleanup subcommand cannot find it by build ID: %s", err))
+		}
 
 		err := client.waitForResourceReady(floatingIPID, "floating_ips", config.StateTimeout, state)
 		if err != nil {
@@ -56,6 +61,8 @@ func (step *stepGetIP) Run(_ context.Context, state multistep.StateBag) multiste
 		}
 		ui.Say("Floating IP is ACTIVE!")
 		ipAddress = *floatingIPData.Address
+		generatedData := &packerbuilderdata.GeneratedData{State: state}
+		generatedData.Put("FloatingIP", ipAddress)
 	}
 
 	state.Put("floating_ip", ipAddress)
@@ -63,6 +63,11 @@ func (s *stepGetSubnetInfo) Run(_ context.Context, state multistep.StateBag) mul
 		}
 
 		zone := *subnetData.Zone.Name
+		// stepVerifyInput already reported the subnets a dedicated host or
+		// reservation cannot use.
+		if placementZone, ok := state.GetOk("placement_zone"); ok && placementZone.(string) != zone {
+			continue
+		}
 		subnets = append(subnets, subnetZone{ID: subnetID, Zone: zone})
 		ui.Say(fmt.Sprintf("Subnet %s is in zone %s", subnetID, zone))
 	}
@@ -96,3 +96,25 @@ func TestStepGetSubnetInfoRejectsCrossVPC(t *testing.T) {
 		t.Fatalf("error = %v, want it to mention same VPC", err)
 	}
 }
+
+// TestStepGetSubnetInfoPlacementZone checks that the subnets outside the zone
+// a dedicated host or reservation pins the build to are not used.
+func TestStepGetSubnetInfoPlacementZone(t *testing.T) {
+	srv := httptest.NewServer(subnetHandler(map[string]subnetInfo{
+		"0717-a": {vpc: "vpc-1", zone: "us-east-1"},
+		"0727-b": {vpc: "vpc-1", zone: "us-east-2"},
+	}))
+	defer srv.Close()
+
+	state := newSubnetInfoState(t, srv.URL, "0717-a", "0727-b")
+	state.Put("placement_zone", "us-east-2")
+
+	step := &stepGetSubnetInfo{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run action = %v, want ActionContinue (err=%v)", action, state.Get("error"))
+	}
+	bake := state.Get("bake_subnets").([]subnetZone)
+	if len(bake) != 1 || bake[0].ID != "0727-b" {
+		t.Errorf("bake_subnets = %v, want only 0727-b", bake)
+	}
+}
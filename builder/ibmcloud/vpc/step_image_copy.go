@@ -0,0 +1,215 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ImageCopyTarget is a region StepImageCopy imports the exported image into.
+type ImageCopyTarget struct {
+	Region string
+	// Endpoint is the region's VPC API endpoint; it defaults to the public one.
+	Endpoint string
+	// EncryptionKeyCRN is the root key that encrypts the copy. It is required
+	// when the source image is encrypted and not allowed otherwise.
+	EncryptionKeyCRN string
+	// ImageName defaults to the source image's name.
+	ImageName       string
+	ResourceGroupID string
+}
+
+// StepImageCopy replicates an image to other regions by importing the file
+// StepImageExport wrote to Cloud Object Storage. An encrypted image is exported
+// encrypted, so its data key is rewrapped with each target's root key (see
+// rewrapDataKey). The copies, keyed by region, are stored under
+// "copied_image_ids"; if the step or a later one fails, they are deleted. The
+// exported object is deleted in either case once the imports are done with it.
+type StepImageCopy struct {
+	Targets []ImageCopyTarget
+	Timeout time.Duration
+
+	// Seams for tests; nil means the real VPC, KMS and COS clients.
+	newService func(target ImageCopyTarget) (*vpcv1.VpcV1, error)
+	wrapper    dataKeyWrapper
+	deleter    exportedObjectDeleter
+
+	created []copiedImage
+}
+
+// copiedImage records an imported image so Cleanup can find it in its region.
+type copiedImage struct {
+	region  string
+	imageID string
+	service *vpcv1.VpcV1
+}
+
+func (step *StepImageCopy) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*IBMCloudClient)
+	config := state.Get("config").(Config)
+	ui := state.Get("ui").(packer.Ui)
+	svc := vpcService(state)
+
+	source, _, err := svc.GetImage(svc.NewGetImageOptions(config.ImageID))
+	if err != nil {
+		err := fmt.Errorf("[ERROR] Error fetching image %s: %s", config.ImageID, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	href, _ := state.Get("image_export_storage_href").(string)
+	if href == "" {
+		err := fmt.Errorf("[ERROR] The export of image %s did not report a Cloud Object Storage location", config.ImageID)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	var sourceKeyCRN string
+	if source.EncryptionKey != nil && source.EncryptionKey.CRN != nil {
+		sourceKeyCRN = *source.EncryptionKey.CRN
+	}
+	encryptedDataKey, _ := state.Get("image_export_encrypted_data_key").([]byte)
+	if sourceKeyCRN != "" && len(encryptedDataKey) == 0 {
+		err := fmt.Errorf("[ERROR] The export of encrypted image %s did not report its encrypted data key", config.ImageID)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// The exported file is encrypted exactly when the source image is, and an
+	// import can neither decrypt nor encrypt it, so every copy must match before
+	// any is made.
+	for _, target := range step.Targets {
+		switch {
+		case sourceKeyCRN != "" && target.EncryptionKeyCRN == "":
+			err := fmt.Errorf("[ERROR] Image %s is encrypted; an encryption_key_crn is required to copy it to %s", config.ImageID, target.Region)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		case sourceKeyCRN == "" && target.EncryptionKeyCRN != "":
+			err := fmt.Errorf("[ERROR] Image %s is not encrypted, so it cannot be copied to %s as an encrypted image; remove its encryption_key_crn or build the image with an encryption_key_crn", config.ImageID, target.Region)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	newService := step.newService
+	if newService == nil {
+		newService = func(target ImageCopyTarget) (*vpcv1.VpcV1, error) {
+			return NewVPCService(client.IBMApiKey, config.IAMEndpoint, target.Endpoint)
+		}
+	}
+	wrapper := step.wrapper
+	if wrapper == nil {
+		wrapper = newKMSDataKeyWrapper(client.IBMApiKey, config.IAMEndpoint)
+	}
+
+	copied := map[string]string{}
+	for _, target := range step.Targets {
+		if target.Endpoint == "" {
+			target.Endpoint = "https://" + target.Region + ".iaas.cloud.ibm.com/v1/"
+		}
+		if target.ImageName == "" {
+			target.ImageName = *source.Name
+		}
+		ui.Say(fmt.Sprintf("Copying image %s to region %s...", config.ImageID, target.Region))
+		targetService, err := newService(target)
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error creating VPC service for region %s: %s", target.Region, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		prototype := &vpcv1.ImagePrototypeImageByFile{
+			Name: &target.ImageName,
+			File: &vpcv1.ImageFilePrototype{Href: &href},
+			OperatingSystem: &vpcv1.OperatingSystemIdentityByName{
+				Name: source.OperatingSystem.Name,
+			},
+		}
+		if target.EncryptionKeyCRN != "" {
+			dataKey, err := rewrapDataKey(sourceKeyCRN, target.EncryptionKeyCRN, encryptedDataKey, wrapper)
+			if err != nil {
+				err := fmt.Errorf("[ERROR] Error rewrapping the image data key for region %s: %s", target.Region, err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			prototype.EncryptedDataKey = &dataKey
+			prototype.EncryptionKey = &vpcv1.EncryptionKeyIdentityByCRN{CRN: &target.EncryptionKeyCRN}
+		}
+		if target.ResourceGroupID != "" {
+			prototype.ResourceGroup = &vpcv1.ResourceGroupIdentityByID{ID: &target.ResourceGroupID}
+		}
+
+		image, _, err := targetService.CreateImage(targetService.NewCreateImageOptions(prototype))
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error importing image in region %s: %s", target.Region, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		step.created = append(step.created, copiedImage{region: target.Region, imageID: *image.ID, service: targetService})
+
+		ui.Say(fmt.Sprintf("Waiting for image %s in region %s to become AVAILABLE...", *image.ID, target.Region))
+		if err := client.waitForResourceReady(*image.ID, "images", step.Timeout, regionState(ui, targetService)); err != nil {
+			err := fmt.Errorf("[ERROR] Error waiting for image %s in region %s to become AVAILABLE: %s", *image.ID, target.Region, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		ui.Say(fmt.Sprintf("Image copied to region %s: %s", target.Region, *image.ID))
+		copied[target.Region] = *image.ID
+	}
+
+	state.Put("copied_image_ids", copied)
+	return multistep.ActionContinue
+}
+
+// Cleanup deletes the copies made so far when the build did not complete, so a
+// failed copy to one region does not leave images behind in the others. The
+// exported object is only an intermediate of the copy, so it is always deleted.
+func (step *StepImageCopy) Cleanup(state multistep.StateBag) {
+	client := state.Get("client").(*IBMCloudClient)
+	ui := state.Get("ui").(packer.Ui)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if cancelled || halted {
+		for _, image := range step.created {
+			ui.Say(fmt.Sprintf("Deleting image %s copied to region %s...", image.imageID, image.region))
+			if err := client.destroyImage(image.imageID, step.Timeout, regionState(ui, image.service)); err != nil {
+				ui.Error(fmt.Sprintf("Error deleting image %s in region %s, it must be deleted manually: %s", image.imageID, image.region, err))
+			}
+		}
+	}
+
+	href, _ := state.Get("image_export_storage_href").(string)
+	if href == "" {
+		return
+	}
+	deleter := step.deleter
+	if deleter == nil {
+		config := state.Get("config").(Config)
+		deleter = newCOSObjectDeleter(client.IBMApiKey, config.IAMEndpoint)
+	}
+	ui.Say(fmt.Sprintf("Deleting the exported image object %s...", href))
+	if err := deleter.deleteObject(href); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting the exported image object %s, it must be deleted manually: %s", href, err))
+	}
+}
+
+// regionState is a state bag for the client helpers (waitForResourceReady,
+// destroyImage) that talks to another region's VPC service.
+func regionState(ui packer.Ui, svc *vpcv1.VpcV1) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", ui)
+	state.Put("vpcService", svc)
+	return state
+}
@@ -0,0 +1,246 @@
+package vpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// sourceImageHandler serves GET /images/src-1; keyCRN, when set, makes it an
+// encrypted image.
+func sourceImageHandler(keyCRN string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		image := map[string]interface{}{
+			"id":               "src-1",
+			"name":             "golden",
+			"status":           "available",
+			"operating_system": map[string]string{"name": "red-9-amd64"},
+		}
+		if keyCRN != "" {
+			image["encryption_key"] = map[string]string{"crn": keyCRN}
+		}
+		_ = json.NewEncoder(w).Encode(image)
+	}
+}
+
+// copyRegion fakes a target region: POST /images answers with status (and
+// records the prototype), GET /images/copy-1 reports it available until it is
+// deleted, and /instances is empty so destroyImage may delete it.
+type copyRegion struct {
+	status int
+
+	mu        sync.Mutex
+	prototype map[string]interface{}
+	deleted   bool
+}
+
+func (c *copyRegion) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/images"):
+		_ = json.NewDecoder(r.Body).Decode(&c.prototype)
+		w.WriteHeader(c.status)
+		_, _ = w.Write([]byte(`{"id":"copy-1","status":"pending"}`))
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/instances"):
+		_, _ = w.Write([]byte(`{"instances":[],"limit":50}`))
+	case r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, "/images/copy-1"):
+		c.deleted = true
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/images/copy-1"):
+		if c.deleted {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"not_found"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"copy-1","status":"available"}`))
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func newImageCopyState(t *testing.T, sourceURL string, encryptedDataKey []byte) *multistep.BasicStateBag {
+	t.Helper()
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packer.TestUi(t))
+	state.Put("client", &IBMCloudClient{})
+	state.Put("config", Config{ImageID: "src-1"})
+	state.Put("vpcService", newTestVpcService(t, sourceURL))
+	state.Put("image_export_storage_href", "cos://us-south/bucket/golden.qcow2")
+	if encryptedDataKey != nil {
+		state.Put("image_export_encrypted_data_key", encryptedDataKey)
+	}
+	return state
+}
+
+// fakeObjectDeleter records the exported objects deleted.
+type fakeObjectDeleter struct {
+	deleted []string
+}
+
+func (f *fakeObjectDeleter) deleteObject(href string) error {
+	f.deleted = append(f.deleted, href)
+	return nil
+}
+
+// newImageCopyStep routes each target region to its fake server.
+func newImageCopyStep(t *testing.T, regions map[string]*httptest.Server, targets ...ImageCopyTarget) *StepImageCopy {
+	return &StepImageCopy{
+		Targets: targets,
+		Timeout: 30 * time.Second,
+		newService: func(target ImageCopyTarget) (*vpcv1.VpcV1, error) {
+			return newTestVpcService(t, regions[target.Region].URL), nil
+		},
+		wrapper: &fakeDataKeyWrapper{},
+		deleter: &fakeObjectDeleter{},
+	}
+}
+
+func TestStepImageCopyUnencrypted(t *testing.T) {
+	source := httptest.NewServer(sourceImageHandler(""))
+	defer source.Close()
+	euDE := &copyRegion{status: http.StatusCreated}
+	euDESrv := httptest.NewServer(euDE)
+	defer euDESrv.Close()
+
+	state := newImageCopyState(t, source.URL, nil)
+	step := newImageCopyStep(t, map[string]*httptest.Server{"eu-de": euDESrv}, ImageCopyTarget{Region: "eu-de"})
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run halted: %v", state.Get("error"))
+	}
+
+	copied := state.Get("copied_image_ids").(map[string]string)
+	if copied["eu-de"] != "copy-1" {
+		t.Errorf("expected eu-de to map to copy-1, got %v", copied)
+	}
+	if euDE.prototype["name"] != "golden" {
+		t.Errorf("expected the copy to default to the source name, got %v", euDE.prototype["name"])
+	}
+	if file, _ := euDE.prototype["file"].(map[string]interface{}); file["href"] != "cos://us-south/bucket/golden.qcow2" {
+		t.Errorf("expected the exported object to be imported, got %v", euDE.prototype["file"])
+	}
+	if _, ok := euDE.prototype["encryption_key"]; ok {
+		t.Errorf("an unencrypted image must be imported unencrypted, got %v", euDE.prototype)
+	}
+
+	step.Cleanup(state)
+	if deleted := step.deleter.(*fakeObjectDeleter).deleted; len(deleted) != 1 || deleted[0] != "cos://us-south/bucket/golden.qcow2" {
+		t.Errorf("expected the exported object to be deleted once copied, got %v", deleted)
+	}
+	if euDE.deleted {
+		t.Error("the copy of a successful build must be kept")
+	}
+}
+
+// An unencrypted image is exported unencrypted, and importing it with a root
+// key but no data key would not encrypt the copy; the step halts before
+// importing anything.
+func TestStepImageCopyUnencryptedRejectsTargetKey(t *testing.T) {
+	source := httptest.NewServer(sourceImageHandler(""))
+	defer source.Close()
+	euDE := &copyRegion{status: http.StatusCreated}
+	euDESrv := httptest.NewServer(euDE)
+	defer euDESrv.Close()
+	jpTok := &copyRegion{status: http.StatusCreated}
+	jpTokSrv := httptest.NewServer(jpTok)
+	defer jpTokSrv.Close()
+
+	state := newImageCopyState(t, source.URL, nil)
+	step := newImageCopyStep(t, map[string]*httptest.Server{"eu-de": euDESrv, "jp-tok": jpTokSrv},
+		ImageCopyTarget{Region: "eu-de"},
+		ImageCopyTarget{Region: "jp-tok", EncryptionKeyCRN: "crn:v1:bluemix:public:kms:jp-tok:a/acc:inst-dst:key:key-dst"})
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatal("expected Run to halt for an encryption_key_crn on an unencrypted image")
+	}
+	if err := state.Get("error").(error); !strings.Contains(err.Error(), "is not encrypted, so it cannot be copied to jp-tok as an encrypted image") {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if euDE.prototype != nil || jpTok.prototype != nil {
+		t.Errorf("no image should be imported, got %v and %v", euDE.prototype, jpTok.prototype)
+	}
+}
+
+func TestStepImageCopyEncrypted(t *testing.T) {
+	source := httptest.NewServer(sourceImageHandler("crn:v1:bluemix:public:kms:us-south:a/acc:inst-src:key:key-src"))
+	defer source.Close()
+	euDE := &copyRegion{status: http.StatusCreated}
+	euDESrv := httptest.NewServer(euDE)
+	defer euDESrv.Close()
+
+	targetKey := "crn:v1:bluemix:public:kms:eu-de:a/acc:inst-dst:key:key-dst"
+	state := newImageCopyState(t, source.URL, []byte("secret"))
+	step := newImageCopyStep(t, map[string]*httptest.Server{"eu-de": euDESrv},
+		ImageCopyTarget{Region: "eu-de", EncryptionKeyCRN: targetKey, ImageName: "golden-eu"})
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run halted: %v", state.Get("error"))
+	}
+
+	if key, _ := euDE.prototype["encryption_key"].(map[string]interface{}); key["crn"] != targetKey {
+		t.Errorf("expected the copy to use the target root key, got %v", euDE.prototype["encryption_key"])
+	}
+	// fakeDataKeyWrapper wraps as "<key id>:<plaintext>".
+	if euDE.prototype["encrypted_data_key"] != "key-dst:plain" {
+		t.Errorf("expected the data key rewrapped by the target key, got %v", euDE.prototype["encrypted_data_key"])
+	}
+	if euDE.prototype["name"] != "golden-eu" {
+		t.Errorf("expected the configured image name, got %v", euDE.prototype["name"])
+	}
+}
+
+// An encrypted image cannot be imported without a key in the target region;
+// the step halts before importing anything.
+func TestStepImageCopyEncryptedRequiresTargetKey(t *testing.T) {
+	source := httptest.NewServer(sourceImageHandler("crn:v1:bluemix:public:kms:us-south:a/acc:inst-src:key:key-src"))
+	defer source.Close()
+	euDE := &copyRegion{status: http.StatusCreated}
+	euDESrv := httptest.NewServer(euDE)
+	defer euDESrv.Close()
+
+	state := newImageCopyState(t, source.URL, []byte("secret"))
+	step := newImageCopyStep(t, map[string]*httptest.Server{"eu-de": euDESrv}, ImageCopyTarget{Region: "eu-de"})
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatal("expected Run to halt without a target encryption_key_crn")
+	}
+	if euDE.prototype != nil {
+		t.Errorf("no image should be imported, got %v", euDE.prototype)
+	}
+}
+
+// When a later region fails, Cleanup deletes the copies already made.
+func TestStepImageCopyCleanupDeletesCopies(t *testing.T) {
+	source := httptest.NewServer(sourceImageHandler(""))
+	defer source.Close()
+	euDE := &copyRegion{status: http.StatusCreated}
+	euDESrv := httptest.NewServer(euDE)
+	defer euDESrv.Close()
+	jpTok := &copyRegion{status: http.StatusBadRequest}
+	jpTokSrv := httptest.NewServer(jpTok)
+	defer jpTokSrv.Close()
+
+	state := newImageCopyState(t, source.URL, nil)
+	step := newImageCopyStep(t, map[string]*httptest.Server{"eu-de": euDESrv, "jp-tok": jpTokSrv},
+		ImageCopyTarget{Region: "eu-de"}, ImageCopyTarget{Region: "jp-tok"})
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatal("expected Run to halt when the jp-tok import fails")
+	}
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+
+	if !euDE.deleted {
+		t.Error("expected the eu-de copy to be deleted")
+	}
+	if deleted := step.deleter.(*fakeObjectDeleter).deleted; len(deleted) != 1 {
+		t.Errorf("expected the exported object to be deleted, got %v", deleted)
+	}
+}
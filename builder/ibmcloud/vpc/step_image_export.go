@@ -70,6 +70,25 @@ func (step *StepImageExport) Run(_ context.Context, state multistep.StateBag) mu
 	ui.Say("Image export job succeeded!")
 	state.Put("image_id", config.ImageID)
 	state.Put("image_export_job_id", jobId)
+
+	// StepImageCopy imports the exported file in other regions: it needs the
+	// object's COS location and, for an encrypted image, its wrapped data key.
+	exportJob, _, err := vpcService.GetImageExportJob(&vpcv1.GetImageExportJobOptions{
+		ImageID: &config.ImageID,
+		ID:      &jobId,
+	})
+	if err != nil {
+		err := fmt.Errorf("[ERROR] Error fetching the image export job: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if exportJob.StorageHref != nil {
+		state.Put("image_export_storage_href", *exportJob.StorageHref)
+	}
+	if exportJob.EncryptedDataKey != nil {
+		state.Put("image_export_encrypted_data_key", *exportJob.EncryptedDataKey)
+	}
 	ui.Say("Image export job created successfully!") // Image exported job created successfully
 	ui.Say(fmt.Sprintf("Image Export Job's ID: %s", *imageExportJob.ID))
 	return multistep.ActionContinue
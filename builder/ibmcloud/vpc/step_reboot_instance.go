@@ -16,13 +16,17 @@ func (s *stepRebootInstance) Run(_ context.Context, state multistep.StateBag) mu
 	config := state.Get("config").(Config)
 	ui := state.Get("ui").(packer.Ui)
 
+	if _, generalized := state.GetOk("generalized"); config.SkipReboot && generalized {
+		ui.Say("Not rebooting the generalized instance: it would undo the generalization.")
+		return multistep.ActionContinue
+	}
 	if config.SkipReboot {
 		ui.Say("Rebooting instance to cleanly complete any installed software components...")
 
 		instanceData := state.Get("instance_data").(*vpcv1.Instance)
 		instanceID := *instanceData.ID
 
-		status, err := client.manageInstance(instanceID, "reboot", state)
+		status, err := client.manageInstance(instanceID, "reboot", false, state)
 		if err != nil {
 			err := fmt.Errorf("[ERROR] Error rebooting the instance: %s", err)
 			state.Put("error", err)
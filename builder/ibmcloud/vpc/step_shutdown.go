@@ -0,0 +1,76 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepShutdown stops the instance so its boot volume can be captured. With
+// shutdown_command, the guest is asked to power itself off through the
+// communicator, so it can flush its disks, clean up cloud-init or run sysprep.
+// Without it, or when the guest is not stopped within shutdown_timeout, the
+// instance is stopped through the API: softly first, then forcibly if it is
+// not stopped within timeout.
+type stepShutdown struct{}
+
+func (s *stepShutdown) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*IBMCloudClient)
+	config := state.Get("config").(Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	instanceData := state.Get("instance_data").(*vpcv1.Instance)
+	instanceID := *instanceData.ID
+
+	if config.ShutdownCommand != "" {
+		comm := state.Get("communicator").(packer.Communicator)
+		ui.Say(fmt.Sprintf("Gracefully shutting down instance ID: %s ...", instanceID))
+		cmd := &packer.RemoteCmd{Command: config.ShutdownCommand}
+		if err := comm.Start(ctx, cmd); err != nil {
+			err := fmt.Errorf("[ERROR] Error sending the shutdown command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		err := client.waitForResourceDown(instanceID, "instances", config.ShutdownTimeout, state)
+		if err == nil {
+			ui.Say("Instance successfully stopped!")
+			return multistep.ActionContinue
+		}
+		ui.Say(fmt.Sprintf("The instance did not power off within shutdown_timeout (%s): %s", config.ShutdownTimeout, err))
+	}
+
+	ui.Say(fmt.Sprintf("Stopping instance ID: %s ...", instanceID))
+	err := stopInstance(client, instanceID, false, config.StateTimeout, state)
+	if err != nil {
+		ui.Say(fmt.Sprintf("The instance did not stop within timeout (%s): %s. Forcing it to stop...", config.StateTimeout, err))
+		err = stopInstance(client, instanceID, true, config.StateTimeout, state)
+	}
+	if err != nil {
+		err := fmt.Errorf("[ERROR] Error stopping the instance: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	ui.Say("Instance successfully stopped!")
+	return multistep.ActionContinue
+}
+
+func (s *stepShutdown) Cleanup(state multistep.StateBag) {}
+
+// stopInstance stops the instance through the API, forcibly when force is
+// set, and waits up to timeout for it to be stopped.
+func stopInstance(client *IBMCloudClient, instanceID string, force bool, timeout time.Duration, state multistep.StateBag) error {
+	status, err := client.manageInstance(instanceID, "stop", force, state)
+	if err != nil {
+		return err
+	}
+	if status == vpcv1.InstanceStatusStoppedConst {
+		return nil
+	}
+	return client.waitForResourceDown(instanceID, "instances", timeout, state)
+}
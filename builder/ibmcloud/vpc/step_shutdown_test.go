@@ -0,0 +1,111 @@
+package vpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fakeStoppingInstance serves an instance that is running until it is
+// stopped: by its guest once the shutdown command was sent if guestStops, or
+// by the stop actions posted with force in stopsOn.
+type fakeStoppingInstance struct {
+	mu         sync.Mutex
+	comm       *packer.MockCommunicator
+	guestStops bool
+	stopsOn    []bool
+	actions    []string
+	stopped    bool
+}
+
+func (f *fakeStoppingInstance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/instances/vsi-1":
+		if f.guestStops && f.comm.StartCalled {
+			f.stopped = true
+		}
+		status := "running"
+		if f.stopped {
+			status = "stopped"
+		}
+		fmt.Fprintf(w, `{"id":"vsi-1","status":%q}`, status)
+	case r.Method == http.MethodPost && r.URL.Path == "/instances/vsi-1/actions":
+		var body struct {
+			Type  string `json:"type"`
+			Force bool   `json:"force"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.actions = append(f.actions, fmt.Sprintf("%s force=%t", body.Type, body.Force))
+		for _, force := range f.stopsOn {
+			if force == body.Force {
+				f.stopped = true
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":"action-%d","type":%q,"status":"pending"}`, len(f.actions), body.Type)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestStepShutdown(t *testing.T) {
+	cases := []struct {
+		name            string
+		shutdownCommand string
+		guestStops      bool
+		stopsOn         []bool
+		wantActions     string
+	}{
+		{name: "guest powers off", shutdownCommand: "shutdown -P now", guestStops: true},
+		{name: "guest does not power off in time", shutdownCommand: "shutdown -P now", stopsOn: []bool{false}, wantActions: "stop force=false"},
+		{name: "soft stop", stopsOn: []bool{false}, wantActions: "stop force=false"},
+		{name: "soft stop times out", stopsOn: []bool{true}, wantActions: "stop force=false,stop force=true"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			comm := new(packer.MockCommunicator)
+			instance := &fakeStoppingInstance{comm: comm, guestStops: tc.guestStops, stopsOn: tc.stopsOn}
+			srv := httptest.NewServer(instance)
+			defer srv.Close()
+
+			config := Config{StateTimeout: 100 * time.Millisecond}
+			config.ShutdownCommand = tc.shutdownCommand
+			config.ShutdownTimeout = 100 * time.Millisecond
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packer.TestUi(t))
+			state.Put("vpcService", newTestVpcService(t, srv.URL))
+			state.Put("client", &IBMCloudClient{})
+			state.Put("config", config)
+			state.Put("communicator", comm)
+			state.Put("instance_data", &vpcv1.Instance{ID: &[]string{"vsi-1"}[0]})
+
+			if action := new(stepShutdown).Run(context.Background(), state); action != multistep.ActionContinue {
+				t.Fatalf("expected ActionContinue, got %v: %v", action, state.Get("error"))
+			}
+			if comm.StartCalled != (tc.shutdownCommand != "") {
+				t.Errorf("shutdown command sent: %t, want %t", comm.StartCalled, tc.shutdownCommand != "")
+			}
+			if comm.StartCalled && comm.StartCmd.Command != tc.shutdownCommand {
+				t.Errorf("sent %q, want %q", comm.StartCmd.Command, tc.shutdownCommand)
+			}
+			instance.mu.Lock()
+			defer instance.mu.Unlock()
+			if got := strings.Join(instance.actions, ","); got != tc.wantActions {
+				t.Errorf("instance actions = %q, want %q", got, tc.wantActions)
+			}
+		})
+	}
+}
@@ -98,6 +98,30 @@ func (s *stepVerifyInput) Run(_ context.Context, state multistep.StateBag) multi
 		}
 	}
 
+	// trusted profile name resolution
+	if config.TrustedProfileName != "" {
+		iam, err := newIAMIdentityService(client.IBMApiKey, config.IAMEndpoint)
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error creating instance of IamIdentityV1 for trusted profile: %s: %s", config.TrustedProfileName, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		profileID, err := resolveTrustedProfileName(iam, client.IBMApiKey, config.TrustedProfileName)
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error fetching trusted profile : %s: %s", config.TrustedProfileName, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("derived_trusted_profile_id", profileID)
+		ui.Say(fmt.Sprintf("Trusted profile %s found: %s", config.TrustedProfileName, profileID))
+	}
+
+	// source, sourceArchitecture and sourceAllowedUse describe what the
+	// instance is created from, for the checks below.
+	var source, sourceArchitecture, sourceAllowedUse string
+
 	// boot volume id validation
 	if config.VSIBootVolumeID != "" {
 		getVolumeOptions := &vpcv1.GetVolumeOptions{
@@ -128,6 +152,10 @@ func (s *stepVerifyInput) Run(_ context.Context, state multistep.StateBag) multi
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
+		source, sourceArchitecture = "boot volume "+config.VSIBootVolumeID, *bootVolume.OperatingSystem.Architecture
+		if bootVolume.AllowedUse != nil {
+			sourceAllowedUse = stringValue(bootVolume.AllowedUse.Instance)
+		}
 	}
 
 	//boot snapshot support
@@ -154,6 +182,102 @@ func (s *stepVerifyInput) Run(_ context.Context, state multistep.StateBag) multi
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
+		source, sourceArchitecture = "boot snapshot "+config.VSIBootSnapshotID, *bootSnapshot.OperatingSystem.Architecture
+		if bootSnapshot.AllowedUse != nil {
+			sourceAllowedUse = stringValue(bootSnapshot.AllowedUse.Instance)
+		}
+	}
+
+	// base image
+	var baseImage *vpcv1.Image
+	if config.VSIBaseImageID != "" {
+		baseImage, _, err = vpcService.GetImage(&vpcv1.GetImageOptions{ID: &config.VSIBaseImageID})
+	} else if config.VSIBaseImageName != "" {
+		var images *vpcv1.ImageCollection
+		images, _, err = vpcService.ListImages(&vpcv1.ListImagesOptions{Name: &config.VSIBaseImageName})
+		if err == nil && len(images.Images) > 0 {
+			baseImage = &images.Images[0]
+		}
+	}
+	if err != nil {
+		err := fmt.Errorf("[ERROR] Error fetching base image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if baseImage != nil {
+		source = "base image " + stringValue(baseImage.Name)
+		if baseImage.OperatingSystem != nil {
+			sourceArchitecture = stringValue(baseImage.OperatingSystem.Architecture)
+		}
+		if baseImage.AllowedUse != nil {
+			sourceAllowedUse = stringValue(baseImage.AllowedUse.Instance)
+		}
+	}
+
+	// the source's allowed_use must admit the secure boot and confidential
+	// computing settings
+	if err := checkAllowedUse(config, source, sourceAllowedUse); err != nil {
+		err := fmt.Errorf("[ERROR] %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// profile validation: each of vsi_profiles must run the source's
+	// architecture, and the secure boot and confidential computing settings.
+	for _, name := range config.VSIProfiles {
+		profile, _, err := vpcService.GetInstanceProfile(&vpcv1.GetInstanceProfileOptions{Name: &name})
+		if err != nil {
+			err := fmt.Errorf("[ERROR] Error fetching instance profile %s: %s", name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if err := checkProfileArchitecture(profile, source, sourceArchitecture); err != nil {
+			err := fmt.Errorf("[ERROR] %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if config.EnableSecureBoot != nil || config.ConfidentialComputeMode != "" {
+			if err := checkSecureBootAndConfidentialCompute(config, profile); err != nil {
+				err := fmt.Errorf("[ERROR] %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+	}
+
+	// placement target and reservation validation
+	if config.DedicatedHostID != "" || config.DedicatedHostGroupID != "" || config.PlacementGroupID != "" || config.ReservationID != "" {
+		zone, pinnedBy, err := placementZone(vpcService, &config)
+		if err != nil {
+			err := fmt.Errorf("[ERROR] %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if zone != "" {
+			outside, err := subnetsOutsideZone(vpcService, config.SubnetIDs, zone)
+			if err != nil {
+				err := fmt.Errorf("[ERROR] Error fetching subnet: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			if len(outside) == len(config.SubnetIDs) {
+				err := fmt.Errorf("[ERROR] The instance must be created in zone %s of %s, but none of the subnets is in that zone: %s", zone, pinnedBy, strings.Join(outside, ", "))
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			if len(outside) > 0 {
+				ui.Say(fmt.Sprintf("Not using subnets outside zone %s of %s: %s", zone, pinnedBy, strings.Join(outside, ", ")))
+			}
+			state.Put("placement_zone", zone)
+		}
 	}
 
 	// image check
@@ -321,3 +445,16 @@ func verifyCRN(search crnSearcher, crn, separator, noun, label string, ui packer
 	ui.Say(fmt.Sprintf("%s %s information successfully retrieved ...", res.Items[0].GetProperty("name"), noun))
 	return multistep.ActionContinue
 }
+
+// checkProfileArchitecture fails unless profile runs the vCPU architecture of
+// the instance's source. An unknown architecture, e.g. of a catalog offering,
+// is left to IBM Cloud.
+func checkProfileArchitecture(profile *vpcv1.InstanceProfile, source, architecture string) error {
+	if architecture == "" || profile.VcpuArchitecture == nil || profile.VcpuArchitecture.Value == nil {
+		return nil
+	}
+	if *profile.VcpuArchitecture.Value != architecture {
+		return fmt.Errorf("vsi_profile %s runs %s instances, but %s is %s", stringValue(profile.Name), *profile.VcpuArchitecture.Value, source, architecture)
+	}
+	return nil
+}
@@ -2,10 +2,12 @@ package vpc
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/IBM/go-sdk-core/v5/core"
 	searchv2 "github.com/IBM/platform-services-go-sdk/globalsearchv2"
+	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -197,3 +199,34 @@ func TestVerifyCRNs_NoCRNConfigured(t *testing.T) {
 		t.Fatalf("expected ActionContinue when no CRN is configured, got %v", action)
 	}
 }
+
+func TestCheckProfileArchitecture(t *testing.T) {
+	profile := func(name, arch string) *vpcv1.InstanceProfile {
+		return &vpcv1.InstanceProfile{Name: &name, VcpuArchitecture: &vpcv1.InstanceProfileVcpuArchitecture{Value: &arch}}
+	}
+	tests := []struct {
+		name         string
+		profile      *vpcv1.InstanceProfile
+		architecture string
+		wantErr      string
+	}{
+		{"same architecture", profile("bx2-2x8", "amd64"), "amd64", ""},
+		{"other architecture", profile("bz2-2x8", "s390x"), "amd64", "vsi_profile bz2-2x8 runs s390x instances, but base image ibm-ubuntu is amd64"},
+		{"unknown source architecture", profile("bz2-2x8", "s390x"), "", ""},
+		{"profile without architecture", &vpcv1.InstanceProfile{}, "amd64", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkProfileArchitecture(tc.profile, "base image ibm-ubuntu", tc.architecture)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
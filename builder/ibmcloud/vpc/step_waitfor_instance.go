@@ -7,6 +7,7 @@ import (
 	"github.com/IBM/vpc-go-sdk/vpcv1"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 type stepWaitforInstance struct{}
@@ -31,10 +32,51 @@ func (s *stepWaitforInstance) Run(_ context.Context, state multistep.StateBag) m
 	// Update instance_data with new information unavailable at creation time (Private_IP, etc..)
 	newInstanceData, _ := client.retrieveResource(instanceID, state)
 	state.Put("instance_data", newInstanceData)
+	recordInstanceData(state, newInstanceData)
 	ui.Say("Instance is ACTIVE!")
 	return multistep.ActionContinue
 }
 
+// recordInstanceData publishes the builder instance's details as generated data
+// (build.* variables for provisioners; see generatedDataKeys). Fields the
+// instance does not have, e.g. the source image of a boot-volume build, are left
+// unset.
+func recordInstanceData(state multistep.StateBag, instance *vpcv1.Instance) {
+	if instance == nil {
+		return
+	}
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("InstanceID", stringValue(instance.ID))
+	generatedData.Put("InstanceName", stringValue(instance.Name))
+	if instance.Zone != nil {
+		generatedData.Put("Zone", stringValue(instance.Zone.Name))
+	}
+	if instance.VPC != nil {
+		generatedData.Put("VpcID", stringValue(instance.VPC.ID))
+	}
+	subnetID, address := primarySubnetAndIP(instance)
+	if subnetID != "" {
+		generatedData.Put("SubnetID", subnetID)
+	}
+	if address != "" {
+		generatedData.Put("PrivateIP", address)
+	}
+	if instance.Image != nil {
+		generatedData.Put("SourceImageID", stringValue(instance.Image.ID))
+		generatedData.Put("SourceImageName", stringValue(instance.Image.Name))
+	}
+	if instance.ResourceGroup != nil {
+		generatedData.Put("ResourceGroupID", stringValue(instance.ResourceGroup.ID))
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 // Cleanup is empty for this step.
 func (s *stepWaitforInstance) Cleanup(state multistep.StateBag) {
 	// No cleanup needed here, stepCreateInstance handles all resource deletion
@@ -0,0 +1,96 @@
+package vpc
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestRecordInstanceData(t *testing.T) {
+	str := func(s string) *string { return &s }
+	instance := &vpcv1.Instance{
+		ID:   str("0717_ins"),
+		Name: str("packer-vsi"),
+		Zone: &vpcv1.ZoneReference{Name: str("us-south-2")},
+		VPC:  &vpcv1.VPCReference{ID: str("r006-vpc")},
+		PrimaryNetworkInterface: &vpcv1.NetworkInterfaceInstanceContextReference{
+			Subnet:    &vpcv1.SubnetReference{ID: str("0717-subnet")},
+			PrimaryIP: &vpcv1.ReservedIPReference{Address: str("10.240.64.4")},
+		},
+		Image:         &vpcv1.ImageReference{ID: str("r006-base"), Name: str("ibm-redhat-9")},
+		ResourceGroup: &vpcv1.ResourceGroupReference{ID: str("rg-1")},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("generated_data", map[string]interface{}{})
+
+	recordInstanceData(state, instance)
+
+	got := state.Get("generated_data").(map[string]interface{})
+	want := map[string]string{
+		"InstanceID":      "0717_ins",
+		"InstanceName":    "packer-vsi",
+		"Zone":            "us-south-2",
+		"VpcID":           "r006-vpc",
+		"SubnetID":        "0717-subnet",
+		"PrivateIP":       "10.240.64.4",
+		"SourceImageID":   "r006-base",
+		"SourceImageName": "ibm-redhat-9",
+		"ResourceGroupID": "rg-1",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s: expected %q, got %v", key, value, got[key])
+		}
+		// Every published key must be advertised by Prepare, or Packer will not
+		// expose it as a build.* variable.
+		if !slices.Contains(generatedDataKeys, key) {
+			t.Errorf("%s is published but missing from generatedDataKeys", key)
+		}
+	}
+}
+
+// An instance created with a virtual network interface has no primary network
+// interface; its subnet and address come from the network attachment.
+func TestRecordInstanceDataWithNetworkAttachment(t *testing.T) {
+	str := func(s string) *string { return &s }
+	instance := &vpcv1.Instance{
+		ID:   str("0717_ins"),
+		Name: str("packer-vsi"),
+		PrimaryNetworkAttachment: &vpcv1.InstanceNetworkAttachmentReference{
+			Subnet:                  &vpcv1.SubnetReference{ID: str("0717-subnet")},
+			PrimaryIP:               &vpcv1.ReservedIPReference{Address: str("10.240.64.5")},
+			VirtualNetworkInterface: &vpcv1.VirtualNetworkInterfaceReferenceAttachmentContext{ID: str("0717-vni")},
+		},
+	}
+	state := new(multistep.BasicStateBag)
+
+	recordInstanceData(state, instance)
+
+	got := state.Get("generated_data").(map[string]interface{})
+	if got["SubnetID"] != "0717-subnet" || got["PrivateIP"] != "10.240.64.5" {
+		t.Errorf("expected the attachment's subnet and address, got %v and %v", got["SubnetID"], got["PrivateIP"])
+	}
+	// Floating IPs and security groups are bound to the VNI.
+	if id := primaryNetworkTargetID(instance); id != "0717-vni" {
+		t.Errorf("primaryNetworkTargetID = %q, want 0717-vni", id)
+	}
+}
+
+// A boot-volume or snapshot build has no source image; the keys are left unset
+// instead of panicking on the nil reference.
+func TestRecordInstanceDataWithoutImage(t *testing.T) {
+	id, name := "0717_ins", "packer-vsi"
+	state := new(multistep.BasicStateBag)
+
+	recordInstanceData(state, &vpcv1.Instance{ID: &id, Name: &name})
+
+	got := state.Get("generated_data").(map[string]interface{})
+	if got["InstanceID"] != id {
+		t.Errorf("expected InstanceID %q, got %v", id, got["InstanceID"])
+	}
+	if _, ok := got["SourceImageID"]; ok {
+		t.Errorf("expected SourceImageID to be unset, got %v", got["SourceImageID"])
+	}
+}
@@ -0,0 +1,294 @@
+package vpc
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	globaltaggingv1 "github.com/IBM/platform-services-go-sdk/globaltaggingv1"
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/go-openapi/strfmt"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Kinds of temporary resources a build creates, in the order they have to be
+// deleted: a floating IP is bound to the instance, a virtual network interface
+// cannot be deleted while it is attached to the instance (it is normally
+// deleted with it), and a security group cannot be deleted while the
+// instance's interface is still a member. They are also the resource types of
+// the build's ledger.
+const (
+	TemporaryFloatingIP              = "floating_ip"
+	TemporaryInstance                = "instance"
+	TemporaryVirtualNetworkInterface = "virtual_network_interface"
+	TemporaryKey                     = "key"
+	TemporarySecurityGroup           = "security_group"
+)
+
+// temporaryKindOrder is the position of each kind in the deletion order.
+var temporaryKindOrder = map[string]int{
+	TemporaryFloatingIP:              0,
+	TemporaryInstance:                1,
+	TemporaryVirtualNetworkInterface: 2,
+	TemporaryKey:                     3,
+	TemporarySecurityGroup:           4,
+}
+
+// buildIDTagPrefix starts the tag every temporary resource is given (see
+// Config.temporaryResourceTags).
+const buildIDTagPrefix = "packer-build-id:"
+
+// SweepOptions selects the temporary resources FindLeakedResources returns.
+type SweepOptions struct {
+	// NamePrefix is the temporary_resource_name_prefix of the builds to sweep.
+	NamePrefix      string
+	ResourceGroupID string
+	// OlderThan skips resources created more recently, which may still belong
+	// to a running build.
+	OlderThan time.Duration
+	// BuildID limits the sweep to the resources tagged by one build.
+	BuildID string
+}
+
+// LeakedResource is a temporary resource whose build did not delete it,
+// typically because Packer was killed before the steps' Cleanup ran.
+type LeakedResource struct {
+	Kind      string
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	// BuildID is taken from the resource's packer-build-id tag. It is empty for
+	// resources created before builds tagged them.
+	BuildID string
+}
+
+// FindLeakedResources lists the floating IPs, instances, virtual network
+// interfaces, SSH keys and security groups named like a build's temporary resources (<prefix>-vsi-<timestamp>
+// and so on) and created before now minus OlderThan. buildIDOf returns the
+// build ID tagged on a CRN; it is only called for resources that match by
+// name. The result is in deletion order.
+func FindLeakedResources(svc *vpcv1.VpcV1, opts SweepOptions, buildIDOf func(crn string) (string, error), now time.Time) ([]LeakedResource, error) {
+	var rg *string
+	if opts.ResourceGroupID != "" {
+		rg = &opts.ResourceGroupID
+	}
+	cutoff := now.Add(-opts.OlderThan)
+	leaked := []LeakedResource{}
+	add := func(kind, suffix string, id, name, crn *string, createdAt *strfmt.DateTime) error {
+		if !temporaryResourceNameRegexp(opts.NamePrefix, suffix).MatchString(stringValue(name)) {
+			return nil
+		}
+		if createdAt == nil || !time.Time(*createdAt).Before(cutoff) {
+			return nil
+		}
+		buildID, err := buildIDOf(stringValue(crn))
+		if err != nil {
+			return fmt.Errorf("reading the tags of %s %s: %s", kind, stringValue(name), err)
+		}
+		if opts.BuildID != "" && buildID != opts.BuildID {
+			return nil
+		}
+		leaked = append(leaked, LeakedResource{
+			Kind:      kind,
+			ID:        stringValue(id),
+			Name:      stringValue(name),
+			CreatedAt: time.Time(*createdAt),
+			BuildID:   buildID,
+		})
+		return nil
+	}
+
+	fipPager, err := svc.NewFloatingIpsPager(&vpcv1.ListFloatingIpsOptions{ResourceGroupID: rg})
+	if err != nil {
+		return nil, err
+	}
+	fips, err := fipPager.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("listing floating IPs: %s", err)
+	}
+	for _, fip := range fips {
+		if err := add(TemporaryFloatingIP, "floating-ip", fip.ID, fip.Name, fip.CRN, fip.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	instancePager, err := svc.NewInstancesPager(&vpcv1.ListInstancesOptions{ResourceGroupID: rg})
+	if err != nil {
+		return nil, err
+	}
+	instances, err := instancePager.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("listing instances: %s", err)
+	}
+	for _, instance := range instances {
+		if err := add(TemporaryInstance, "vsi", instance.ID, instance.Name, instance.CRN, instance.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	vniPager, err := svc.NewVirtualNetworkInterfacesPager(&vpcv1.ListVirtualNetworkInterfacesOptions{ResourceGroupID: rg})
+	if err != nil {
+		return nil, err
+	}
+	vnis, err := vniPager.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("listing virtual network interfaces: %s", err)
+	}
+	for _, vni := range vnis {
+		if err := add(TemporaryVirtualNetworkInterface, "vni", vni.ID, vni.Name, vni.CRN, vni.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	keyPager, err := svc.NewKeysPager(&vpcv1.ListKeysOptions{ResourceGroupID: rg})
+	if err != nil {
+		return nil, err
+	}
+	keys, err := keyPager.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("listing SSH keys: %s", err)
+	}
+	for _, key := range keys {
+		if err := add(TemporaryKey, "ssh-key", key.ID, key.Name, key.CRN, key.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	sgPager, err := svc.NewSecurityGroupsPager(&vpcv1.ListSecurityGroupsOptions{ResourceGroupID: rg})
+	if err != nil {
+		return nil, err
+	}
+	groups, err := sgPager.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("listing security groups: %s", err)
+	}
+	for _, group := range groups {
+		if err := add(TemporarySecurityGroup, "security-group", group.ID, group.Name, group.CRN, group.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	return leaked, nil
+}
+
+// temporaryResourceNameRegexp matches the names Config.Prepare gives temporary
+// resources, e.g. packer-vpc-vsi-1700000000000000000.
+func temporaryResourceNameRegexp(prefix, suffix string) *regexp.Regexp {
+	return regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + "-" + suffix + `-\d+$`)
+}
+
+// DeleteLeakedResources deletes resources in the order FindLeakedResources
+// returns them, waiting for each instance to be gone before moving on so its
+// security groups can be deleted. A resource that is already gone counts as
+// deleted; any other failure is reported and the rest are still attempted.
+// deleted, when not nil, is called for every resource that is gone.
+func DeleteLeakedResources(svc *vpcv1.VpcV1, ui packer.Ui, resources []LeakedResource, timeout time.Duration, deleted func(LeakedResource)) error {
+	var errs []error
+	for _, r := range resources {
+		ui.Say(fmt.Sprintf("Deleting %s %s (%s)...", r.Kind, r.Name, r.ID))
+		var response *core.DetailedResponse
+		var err error
+		switch r.Kind {
+		case TemporaryFloatingIP:
+			response, err = svc.DeleteFloatingIP(svc.NewDeleteFloatingIPOptions(r.ID))
+		case TemporaryInstance:
+			err = deleteInstanceAndWait(svc, ui, r.ID, timeout)
+		case TemporaryVirtualNetworkInterface:
+			_, response, err = svc.DeleteVirtualNetworkInterfaces(svc.NewDeleteVirtualNetworkInterfacesOptions(r.ID))
+		case TemporaryKey:
+			response, err = svc.DeleteKey(svc.NewDeleteKeyOptions(r.ID))
+		case TemporarySecurityGroup:
+			response, err = svc.DeleteSecurityGroup(svc.NewDeleteSecurityGroupOptions(r.ID))
+		default:
+			err = fmt.Errorf("unknown resource kind %q", r.Kind)
+		}
+		if err != nil {
+			if response == nil || response.StatusCode != 404 {
+				err := fmt.Errorf("[ERROR] Error deleting %s %s (%s): %s", r.Kind, r.Name, r.ID, err)
+				ui.Error(err.Error())
+				errs = append(errs, err)
+				continue
+			}
+			ui.Say(fmt.Sprintf("The %s was already deleted.", r.Kind))
+		}
+		if deleted != nil {
+			deleted(r)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewBuildIDLookup returns a function reading the build ID a resource was
+// tagged with, through the Global Tagging API. It returns "" for a resource
+// without a packer-build-id tag.
+func NewBuildIDLookup(apiKey, iamURL, ghostURL string) (func(crn string) (string, error), error) {
+	options := globaltaggingv1.GlobalTaggingV1Options{
+		Authenticator: &core.IamAuthenticator{
+			ApiKey: apiKey,
+			URL:    iamURL,
+		},
+	}
+	if ghostURL != "" {
+		options.URL = ghostURL
+	}
+	tagging, err := globaltaggingv1.NewGlobalTaggingV1(&options)
+	if err != nil {
+		return nil, err
+	}
+	return func(crn string) (string, error) {
+		listOptions := tagging.NewListTagsOptions()
+		listOptions.SetAttachedTo(crn)
+		listOptions.SetTagType(globaltaggingv1.ListTagsOptionsTagTypeUserConst)
+		listOptions.SetLimit(1000)
+		tagList, _, err := tagging.ListTags(listOptions)
+		if err != nil {
+			return "", err
+		}
+		for _, tag := range tagList.Items {
+			if name := stringValue(tag.Name); strings.HasPrefix(name, buildIDTagPrefix) {
+				return strings.TrimPrefix(name, buildIDTagPrefix), nil
+			}
+		}
+		return "", nil
+	}, nil
+}
+
+// CleanupLedger deletes the resources a VPC build recorded in its ledger but
+// did not delete, in dependency order, and marks each one deleted in the
+// ledger as it goes.
+func CleanupLedger(l *ledger.Ledger, apiKey string, ui packer.Ui, timeout time.Duration) error {
+	svc, err := NewVPCService(apiKey, l.IAMURL, l.Endpoint)
+	if err != nil {
+		return fmt.Errorf("creating VPC service: %s", err)
+	}
+	resources := LedgerResources(l)
+	return DeleteLeakedResources(svc, ui, resources, timeout, func(r LeakedResource) {
+		if err := l.Deleted(r.Kind, r.ID); err != nil {
+			ui.Error(fmt.Sprintf("Warning: could not record the deletion of %s %s in %s: %s", r.Kind, r.ID, l.Path(), err))
+		}
+	})
+}
+
+// LedgerResources returns the resources a VPC build's ledger still lists, in
+// deletion order.
+func LedgerResources(l *ledger.Ledger) []LeakedResource {
+	resources := []LeakedResource{}
+	for _, r := range l.Pending() {
+		resources = append(resources, LeakedResource{
+			Kind:      r.Type,
+			ID:        r.ID,
+			Name:      r.Name,
+			CreatedAt: r.CreatedAt,
+			BuildID:   l.BuildID,
+		})
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		return temporaryKindOrder[resources[i].Kind] < temporaryKindOrder[resources[j].Kind]
+	})
+	return resources
+}
@@ -0,0 +1,203 @@
+package vpc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+var sweepNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// sweepItem renders a listed resource created age before sweepNow.
+func sweepItem(id, name string, age time.Duration) string {
+	return fmt.Sprintf(`{"id":%q,"name":%q,"crn":"crn:%s","created_at":%q}`, id, name, id, sweepNow.Add(-age).Format(time.RFC3339))
+}
+
+// sweepListHandler serves the five collections FindLeakedResources lists.
+func sweepListHandler(t *testing.T) http.HandlerFunc {
+	collections := map[string]string{
+		"/floating_ips": sweepItem("fip-old", "packer-vpc-floating-ip-1700000000000000000", 48*time.Hour) + "," +
+			sweepItem("fip-user", "web-floating-ip", 48*time.Hour),
+		"/instances": sweepItem("vsi-old", "packer-vpc-vsi-1700000000000000000", 48*time.Hour) + "," +
+			sweepItem("vsi-running", "packer-vpc-vsi-1800000000000000000", time.Hour) + "," +
+			sweepItem("vsi-other-prefix", "cc1234-vsi-1700000000000000000", 48*time.Hour),
+		"/virtual_network_interfaces": sweepItem("vni-old", "packer-vpc-vni-1700000000000000000", 48*time.Hour),
+		"/keys": sweepItem("key-old", "packer-vpc-ssh-key-1700000000000000000", 48*time.Hour) + "," +
+			sweepItem("key-lookalike", "packer-vpc-ssh-key-backup", 48*time.Hour),
+		"/security_groups": sweepItem("sg-old", "packer-vpc-security-group-1700000000000000000", 48*time.Hour),
+	}
+	names := map[string]string{
+		"/floating_ips":               "floating_ips",
+		"/instances":                  "instances",
+		"/virtual_network_interfaces": "virtual_network_interfaces",
+		"/keys":                       "keys",
+		"/security_groups":            "security_groups",
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		items, ok := collections[path]
+		if !ok || r.Method != http.MethodGet {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"limit":50,"first":{"href":"x"},"%s":[%s]}`, names[path], items)
+	}
+}
+
+func TestFindLeakedResources(t *testing.T) {
+	srv := httptest.NewServer(sweepListHandler(t))
+	defer srv.Close()
+	buildIDs := map[string]string{"crn:vsi-old": "build-1", "crn:vni-old": "build-1", "crn:sg-old": "build-1", "crn:key-old": "build-2"}
+	buildIDOf := func(crn string) (string, error) { return buildIDs[crn], nil }
+
+	cases := []struct {
+		name string
+		opts SweepOptions
+		want []string
+	}{
+		{
+			name: "old resources named like temporary resources, in deletion order",
+			opts: SweepOptions{NamePrefix: "packer-vpc", OlderThan: 24 * time.Hour},
+			want: []string{"floating_ip fip-old", "instance vsi-old", "virtual_network_interface vni-old", "key key-old", "security_group sg-old"},
+		},
+		{
+			name: "recent resources belong to running builds",
+			opts: SweepOptions{NamePrefix: "packer-vpc", OlderThan: 30 * time.Minute},
+			want: []string{"floating_ip fip-old", "instance vsi-old", "instance vsi-running", "virtual_network_interface vni-old", "key key-old", "security_group sg-old"},
+		},
+		{
+			name: "build ID narrows to one build",
+			opts: SweepOptions{NamePrefix: "packer-vpc", OlderThan: 24 * time.Hour, BuildID: "build-1"},
+			want: []string{"instance vsi-old", "virtual_network_interface vni-old", "security_group sg-old"},
+		},
+		{
+			name: "custom prefix",
+			opts: SweepOptions{NamePrefix: "cc1234", OlderThan: 24 * time.Hour},
+			want: []string{"instance vsi-other-prefix"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			leaked, err := FindLeakedResources(newTestVpcService(t, srv.URL), tc.opts, buildIDOf, sweepNow)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := []string{}
+			for _, r := range leaked {
+				got = append(got, r.Kind+" "+r.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeleteLeakedResources(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/instances/vsi-old":
+			// Gone once deleted.
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"not_found"}]}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/virtual_network_interfaces/vni-old":
+			// Deleted with its instance.
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"not_found"}]}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/keys/key-gone":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"not_found"}]}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/security_groups/sg-in-use":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"security_group_in_use"}]}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	resources := []LeakedResource{
+		{Kind: TemporaryFloatingIP, ID: "fip-old"},
+		{Kind: TemporaryInstance, ID: "vsi-old"},
+		{Kind: TemporaryVirtualNetworkInterface, ID: "vni-old"},
+		{Kind: TemporaryKey, ID: "key-gone"},
+		{Kind: TemporarySecurityGroup, ID: "sg-in-use"},
+		{Kind: TemporarySecurityGroup, ID: "sg-old"},
+	}
+	deleted := []string{}
+	err := DeleteLeakedResources(newTestVpcService(t, srv.URL), packer.TestUi(t), resources, time.Minute, func(r LeakedResource) {
+		deleted = append(deleted, r.ID)
+	})
+	if err == nil || !strings.Contains(err.Error(), "sg-in-use") || strings.Contains(err.Error(), "key-gone") {
+		t.Fatalf("expected only the in-use security group to fail, got: %v", err)
+	}
+	want := []string{
+		"DELETE /floating_ips/fip-old",
+		"DELETE /instances/vsi-old",
+		"GET /instances/vsi-old",
+		"DELETE /virtual_network_interfaces/vni-old",
+		"DELETE /keys/key-gone",
+		"DELETE /security_groups/sg-in-use",
+		"DELETE /security_groups/sg-old",
+	}
+	if strings.Join(requests, ",") != strings.Join(want, ",") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+	if got := strings.Join(deleted, ","); got != "fip-old,vsi-old,vni-old,key-gone,sg-old" {
+		t.Errorf("reported deleted %s, want every resource but the in-use security group", got)
+	}
+}
+
+func TestLedgerResourcesDeletionOrder(t *testing.T) {
+	l, err := ledger.New(t.TempDir(), &ledger.Ledger{Builder: "vpc", BuildID: "build-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The order the build creates them in.
+	for _, r := range [][2]string{
+		{TemporaryKey, "key-1"},
+		{TemporaryInstance, "vsi-1"},
+		{TemporaryInstance, "vsi-2"},
+		{TemporaryVirtualNetworkInterface, "vni-2"},
+		{TemporaryFloatingIP, "fip-1"},
+		{TemporarySecurityGroup, "sg-1"},
+	} {
+		if err := l.Created(r[0], r[1], ""); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	// The first instance was deleted by the zone fallback.
+	if err := l.Deleted(TemporaryInstance, "vsi-1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := []string{}
+	for _, r := range LedgerResources(l) {
+		got = append(got, r.ID)
+		if r.BuildID != "build-1" {
+			t.Errorf("resource %s has build ID %q", r.ID, r.BuildID)
+		}
+	}
+	if strings.Join(got, ",") != "fip-1,vsi-2,vni-2,key-1,sg-1" {
+		t.Errorf("got %v, want fip-1,vsi-2,vni-2,key-1,sg-1", got)
+	}
+}
@@ -0,0 +1,77 @@
+package vpc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/IBM/platform-services-go-sdk/iamidentityv1"
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// newIAMIdentityService builds an IAM Identity client that authenticates with
+// the build's API key. iam_url is the base URL of both the token endpoint and
+// the Identity API, so a test IAM environment is honored by both.
+func newIAMIdentityService(apiKey, iamURL string) (*iamidentityv1.IamIdentityV1, error) {
+	options := &iamidentityv1.IamIdentityV1Options{
+		Authenticator: &core.IamAuthenticator{ApiKey: apiKey, URL: iamURL},
+	}
+	if iamURL != "" {
+		options.URL = strings.TrimSuffix(strings.TrimSuffix(iamURL, "/"), "/identity/token")
+	}
+	return iamidentityv1.NewIamIdentityV1(options)
+}
+
+// resolveTrustedProfileName returns the ID of the trusted profile named name
+// in the account that owns apiKey. Profile names are unique in an account.
+func resolveTrustedProfileName(iam *iamidentityv1.IamIdentityV1, apiKey, name string) (string, error) {
+	key, _, err := iam.GetAPIKeysDetails(&iamidentityv1.GetAPIKeysDetailsOptions{IamAPIKey: &apiKey})
+	if err != nil {
+		return "", fmt.Errorf("reading the account of the API key: %s", err)
+	}
+	profiles, _, err := iam.ListProfiles(&iamidentityv1.ListProfilesOptions{AccountID: key.AccountID, Name: &name})
+	if err != nil {
+		return "", fmt.Errorf("listing trusted profiles: %s", err)
+	}
+	if len(profiles.Profiles) == 0 {
+		return "", fmt.Errorf("no trusted profile found with name %s", name)
+	}
+	return *profiles.Profiles[0].ID, nil
+}
+
+// metadataServicePrototype returns the builder instance's metadata_service, or
+// nil to keep the account default (disabled).
+func metadataServicePrototype(config *Config) *vpcv1.InstanceMetadataServicePrototype {
+	if !config.MetadataService.Enabled {
+		return nil
+	}
+	metadataService := &vpcv1.InstanceMetadataServicePrototype{
+		Enabled: &[]bool{true}[0],
+	}
+	if config.MetadataService.Protocol != "" {
+		metadataService.Protocol = &[]string{config.MetadataService.Protocol}[0]
+	}
+	if config.MetadataService.ResponseHopLimit != 0 {
+		metadataService.ResponseHopLimit = &[]int64{int64(config.MetadataService.ResponseHopLimit)}[0]
+	}
+	return metadataService
+}
+
+// defaultTrustedProfilePrototype returns the builder instance's default
+// trusted profile from trusted_profile_id, or from the ID stepVerifyInput
+// resolved from trusted_profile_name, or nil when neither is configured.
+func defaultTrustedProfilePrototype(config *Config, state multistep.StateBag) *vpcv1.InstanceDefaultTrustedProfilePrototype {
+	id := config.TrustedProfileID
+	if id == "" {
+		if derived, ok := state.Get("derived_trusted_profile_id").(string); ok {
+			id = derived
+		}
+	}
+	if id == "" {
+		return nil
+	}
+	return &vpcv1.InstanceDefaultTrustedProfilePrototype{
+		Target: &vpcv1.TrustedProfileIdentityByID{ID: &id},
+	}
+}
@@ -0,0 +1,84 @@
+package vpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IBM/platform-services-go-sdk/iamidentityv1"
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestResolveTrustedProfileName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/apikeys/details":
+			if r.Header.Get("IAM-ApiKey") != "test-api-key" {
+				t.Errorf("unexpected IAM-ApiKey header %q", r.Header.Get("IAM-ApiKey"))
+			}
+			_, _ = w.Write([]byte(`{"id":"ApiKey-1","account_id":"acct-1"}`))
+		case "/v1/profiles":
+			if r.URL.Query().Get("account_id") != "acct-1" {
+				t.Errorf("unexpected account_id %q", r.URL.Query().Get("account_id"))
+			}
+			if r.URL.Query().Get("name") == "packer-builder" {
+				_, _ = w.Write([]byte(`{"profiles":[{"id":"Profile-1","name":"packer-builder"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"profiles":[]}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	iam, err := iamidentityv1.NewIamIdentityV1(&iamidentityv1.IamIdentityV1Options{
+		Authenticator: stubAuthenticator{},
+		URL:           srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("failed to build test IAM Identity service: %s", err)
+	}
+
+	id, err := resolveTrustedProfileName(iam, "test-api-key", "packer-builder")
+	if err != nil || id != "Profile-1" {
+		t.Errorf("got %q, %v; want Profile-1", id, err)
+	}
+	if _, err := resolveTrustedProfileName(iam, "test-api-key", "missing"); err == nil {
+		t.Error("expected an error for a missing trusted profile")
+	}
+}
+
+func TestMetadataServicePrototype(t *testing.T) {
+	if got := metadataServicePrototype(&Config{}); got != nil {
+		t.Errorf("expected no metadata_service by default, got %+v", got)
+	}
+	got := metadataServicePrototype(&Config{MetadataService: MetadataServiceConfig{Enabled: true, Protocol: "https", ResponseHopLimit: 2}})
+	if got == nil || !*got.Enabled || *got.Protocol != "https" || *got.ResponseHopLimit != 2 {
+		t.Errorf("unexpected metadata_service %+v", got)
+	}
+	got = metadataServicePrototype(&Config{MetadataService: MetadataServiceConfig{Enabled: true}})
+	if got.Protocol != nil || got.ResponseHopLimit != nil {
+		t.Errorf("unset fields should keep the IBM Cloud defaults, got %+v", got)
+	}
+}
+
+func TestDefaultTrustedProfilePrototype(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	if got := defaultTrustedProfilePrototype(&Config{}, state); got != nil {
+		t.Errorf("expected no trusted profile by default, got %+v", got)
+	}
+
+	got := defaultTrustedProfilePrototype(&Config{TrustedProfileID: "Profile-1"}, state)
+	if got == nil || *got.Target.(*vpcv1.TrustedProfileIdentityByID).ID != "Profile-1" {
+		t.Errorf("expected trusted_profile_id to be used, got %+v", got)
+	}
+
+	state.Put("derived_trusted_profile_id", "Profile-2")
+	got = defaultTrustedProfilePrototype(&Config{TrustedProfileName: "packer-builder"}, state)
+	if got == nil || *got.Target.(*vpcv1.TrustedProfileIdentityByID).ID != "Profile-2" {
+		t.Errorf("expected the profile resolved from trusted_profile_name, got %+v", got)
+	}
+}
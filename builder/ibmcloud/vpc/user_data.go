@@ -0,0 +1,142 @@
+package vpc
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// maxUserDataSize is the most user data, in bytes, VPC accepts for an
+// instance.
+const maxUserDataSize = 64 * 1024
+
+// The content types of a vsi_user_data_part, with the MIME type and file
+// name extension the part is sent with. Cloudbase-Init picks the interpreter
+// of a script from the extension, so PowerShell is a shell script to it.
+var userDataPartTypes = map[string]struct{ mimeType, extension string }{
+	"cloud-config": {"text/cloud-config", "yaml"},
+	"shell-script": {"text/x-shellscript", "sh"},
+	"powershell":   {"text/x-shellscript", "ps1"},
+}
+
+// userDataTemplateData is what vsi_user_data_part contents interpolate, e.g.
+// {{ .SSHPublicKey }}, once the build has created them.
+type userDataTemplateData struct {
+	// SSHPublicKey is the authorized_keys line of the build's key pair.
+	SSHPublicKey string
+	BuildID      string
+	InstanceName string
+	ImageName    string
+	Zone         string
+}
+
+// userDataValues returns the build values of the instance about to be
+// created in zone.
+func userDataValues(state multistep.StateBag, config *Config, zone string) (userDataTemplateData, error) {
+	data := userDataTemplateData{
+		BuildID:      config.BuildID,
+		InstanceName: config.VSIName,
+		ImageName:    config.ImageName,
+		Zone:         zone,
+	}
+	if path, ok := state.GetOk("PUBLIC_KEY"); ok {
+		publicKey, err := os.ReadFile(path.(string))
+		if err != nil {
+			return data, fmt.Errorf("[ERROR] Error reading the public key for the user data: %s", err)
+		}
+		data.SSHPublicKey = strings.TrimSpace(string(publicKey))
+	}
+	return data, nil
+}
+
+// buildUserData returns the user data of the instance: vsi_user_data_file or
+// vsi_user_data (mutually exclusive per Config.Prepare), or the WinRM
+// bootstrap and vsi_user_data_part blocks assembled into multipart MIME. It is
+// nil when none is configured.
+func buildUserData(config *Config, data userDataTemplateData) (*string, error) {
+	if config.VSIUserDataFile != "" {
+		content, err := os.ReadFile(config.VSIUserDataFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading vsi_user_data_file: %s", err)
+		}
+		return &[]string{string(content)}[0], nil
+	}
+	if config.VSIUserDataString != "" {
+		return &config.VSIUserDataString, nil
+	}
+	if len(config.VSIUserDataParts) == 0 && !config.WinRMBootstrap {
+		return nil, nil
+	}
+
+	assembled, err := multipartUserData(config, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(assembled) > maxUserDataSize {
+		return nil, fmt.Errorf("the user data assembled from vsi_user_data_part and winrm_bootstrap is %d bytes, more than the %d VPC accepts", len(assembled), maxUserDataSize)
+	}
+	return &assembled, nil
+}
+
+// multipartUserData renders the vsi_user_data_part blocks with data and
+// assembles them, in order and after the WinRM bootstrap when winrm_bootstrap
+// is set, into a multipart/mixed MIME document, as read by cloud-init and
+// Cloudbase-Init.
+func multipartUserData(config *Config, data userDataTemplateData) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	// parts numbers the parts of the document, for their file names.
+	parts := 0
+	if config.WinRMBootstrap {
+		parts++
+		if err := writeUserDataPart(writer, parts, "powershell", winRMBootstrapScript); err != nil {
+			return "", err
+		}
+	}
+	ctx := config.ctx
+	ctx.Data = data
+	for i, part := range config.VSIUserDataParts {
+		content := part.Content
+		if part.File != "" {
+			raw, err := os.ReadFile(part.File)
+			if err != nil {
+				return "", fmt.Errorf("vsi_user_data_part %d: %s", i+1, err)
+			}
+			content = string(raw)
+		}
+		rendered, err := interpolate.Render(content, &ctx)
+		if err != nil {
+			return "", fmt.Errorf("vsi_user_data_part %d: %s", i+1, err)
+		}
+		parts++
+		if err := writeUserDataPart(writer, parts, part.ContentType, rendered); err != nil {
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\nMIME-Version: 1.0\n\n", writer.Boundary()) + body.String(), nil
+}
+
+// writeUserDataPart adds the n-th part of the multipart document, of the given
+// vsi_user_data_part content_type.
+func writeUserDataPart(writer *multipart.Writer, n int, contentType, content string) error {
+	partType := userDataPartTypes[contentType]
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", fmt.Sprintf("%s; charset=\"utf-8\"", partType.mimeType))
+	header.Set("MIME-Version", "1.0")
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"part-%03d.%s\"", n, partType.extension))
+	w, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(content))
+	return err
+}
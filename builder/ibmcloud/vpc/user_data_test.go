@@ -0,0 +1,73 @@
+package vpc
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildUserData(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "setup.ps1")
+	if err := os.WriteFile(script, []byte("Write-Output '{{ .InstanceName }}'"), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	config := &Config{VSIUserDataParts: []UserDataPart{
+		{ContentType: "cloud-config", Content: "#cloud-config\nssh_authorized_keys:\n  - {{ .SSHPublicKey }}\n"},
+		{ContentType: "shell-script", Content: "#!/bin/sh\necho {{ .Zone }}\n"},
+		{ContentType: "powershell", File: script},
+	}}
+	data := userDataTemplateData{SSHPublicKey: "ssh-ed25519 AAAA packer", InstanceName: "packer-vpc-vsi-1", Zone: "us-south-1"}
+
+	userData, err := buildUserData(config, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	message, err := mail.ReadMessage(strings.NewReader(*userData))
+	if err != nil {
+		t.Fatalf("the user data is not a MIME document: %s", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/mixed", message.Header.Get("Content-Type"), err)
+	}
+
+	want := []struct{ contentType, filename, content string }{
+		{`text/cloud-config; charset="utf-8"`, "part-001.yaml", "#cloud-config\nssh_authorized_keys:\n  - ssh-ed25519 AAAA packer\n"},
+		{`text/x-shellscript; charset="utf-8"`, "part-002.sh", "#!/bin/sh\necho us-south-1\n"},
+		{`text/x-shellscript; charset="utf-8"`, "part-003.ps1", "Write-Output 'packer-vpc-vsi-1'"},
+	}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for i, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %s", i+1, err)
+		}
+		content, _ := io.ReadAll(part)
+		if got := part.Header.Get("Content-Type"); got != w.contentType {
+			t.Errorf("part %d: Content-Type = %q, want %q", i+1, got, w.contentType)
+		}
+		if part.FileName() != w.filename {
+			t.Errorf("part %d: filename = %q, want %q", i+1, part.FileName(), w.filename)
+		}
+		if string(content) != w.content {
+			t.Errorf("part %d: content = %q, want %q", i+1, content, w.content)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected %d parts, got more: %v", len(want), err)
+	}
+}
+
+func TestBuildUserDataTooLarge(t *testing.T) {
+	config := &Config{VSIUserDataParts: []UserDataPart{
+		{ContentType: "shell-script", Content: "#!/bin/sh\n# " + strings.Repeat("x", maxUserDataSize) + "\n"},
+	}}
+	if _, err := buildUserData(config, userDataTemplateData{}); err == nil || !strings.Contains(err.Error(), "more than the 65536 VPC accepts") {
+		t.Errorf("expected the size limit to be enforced, got: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// winRMBootstrapPort is the port of the HTTPS listener winrm_bootstrap
+// enables, the only one opened to WinRM.
+const winRMBootstrapPort = 5986
+
+// winRMBootstrapScript is the user data part, run by Cloudbase-Init at first
+// boot, that enables WinRM over HTTPS only, with a self-signed certificate
+// and basic authentication, and opens the firewall to it.
+const winRMBootstrapScript = `$ErrorActionPreference = 'Stop'
+Set-Service -Name WinRM -StartupType Automatic
+Start-Service -Name WinRM
+$cert = New-SelfSignedCertificate -CertStoreLocation Cert:\LocalMachine\My -DnsName 'packer-ibmcloud'
+Get-ChildItem WSMan:\localhost\Listener | Where-Object { $_.Keys -contains 'Transport=HTTPS' } | Remove-Item -Recurse -Force
+New-Item -Path WSMan:\localhost\Listener -Transport HTTPS -Address * -CertificateThumbPrint $cert.Thumbprint -Force | Out-Null
+Set-Item -Path WSMan:\localhost\Service\AllowUnencrypted -Value $false
+Set-Item -Path WSMan:\localhost\Service\Auth\Basic -Value $true
+Set-Item -Path WSMan:\localhost\MaxTimeoutms -Value 1800000
+New-NetFirewallRule -Name packer-winrm-https -DisplayName 'Packer WinRM HTTPS' -Direction Inbound -Protocol TCP -LocalPort 5986 -Action Allow | Out-Null
+Restart-Service -Name WinRM
+`
+
+// winRMTeardownScript is the teardown registration script. Removing the
+// listener would cut the build's own WinRM connection, so, as
+// scripts/undo_winrm.ps1 does, the teardown is registered as a local Group
+// Policy shutdown script that removes the listener, the firewall rule and the
+// certificate when stepShutdown powers the instance off, then unregisters
+// itself.
+const winRMTeardownScript = `$ErrorActionPreference = 'Stop'
+$scripts = "$env:SystemRoot\System32\GroupPolicy\Machine\Scripts"
+$keys = @(
+  'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Group Policy\Scripts\Shutdown\0',
+  'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Group Policy\State\Machine\Scripts\Shutdown\0'
+)
+New-Item -ItemType Directory -Force -Path "$scripts\Shutdown" | Out-Null
+Set-Content -Path "$scripts\Shutdown\packer-winrm-teardown.ps1" -Value @"
+Get-ChildItem WSMan:\localhost\Listener | Where-Object { ` + "`" + `$_.Keys -contains 'Transport=HTTPS' } | Remove-Item -Recurse -Force
+Remove-NetFirewallRule -Name packer-winrm-https -ErrorAction SilentlyContinue
+Get-ChildItem Cert:\LocalMachine\My | Where-Object { ` + "`" + `$_.Subject -eq 'CN=packer-ibmcloud' } | Remove-Item -Force
+Remove-Item -Recurse -Force -ErrorAction SilentlyContinue -Path '$($keys[0])', '$($keys[1])'
+Remove-Item -Force -ErrorAction SilentlyContinue -Path '$scripts\psscripts.ini', '$scripts\Shutdown\packer-winrm-teardown.ps1'
+"@
+Set-Content -Path "$scripts\psscripts.ini" -Encoding Unicode -Value "[Shutdown]", "0CmdLine=packer-winrm-teardown.ps1", "0Parameters="
+foreach ($key in $keys) {
+  New-Item -Path "$key\0" -Force | Out-Null
+  Set-ItemProperty -Path $key -Name GPO-ID -Value LocalGPO
+  Set-ItemProperty -Path $key -Name SOM-ID -Value Local
+  Set-ItemProperty -Path $key -Name FileSysPath -Value "$env:SystemRoot\System32\GroupPolicy\Machine"
+  Set-ItemProperty -Path $key -Name DisplayName -Value 'Local Group Policy'
+  Set-ItemProperty -Path $key -Name GPOName -Value 'Local Group Policy'
+  New-ItemProperty -Path $key -Name PSScriptOrder -Value 1 -PropertyType DWord -Force | Out-Null
+  Set-ItemProperty -Path "$key\0" -Name Script -Value packer-winrm-teardown.ps1
+  Set-ItemProperty -Path "$key\0" -Name Parameters -Value ''
+  New-ItemProperty -Path "$key\0" -Name IsPowershell -Value 1 -PropertyType DWord -Force | Out-Null
+  New-ItemProperty -Path "$key\0" -Name ExecTime -Value 0 -PropertyType QWord -Force | Out-Null
+}
+Remove-Item -Force $PSCommandPath
+`
+
+// windowsWinRMTeardownScript is where the teardown registration script is
+// uploaded to.
+const windowsWinRMTeardownScript = `C:\Windows\Temp\packer-winrm-teardown.ps1`
+
+// stepWinRMTeardown registers the removal of the WinRM listener enabled by
+// winrm_bootstrap, so the captured image does not keep it. It runs after
+// stepRebootInstance, whose reboot would otherwise run the teardown early.
+type stepWinRMTeardown struct{}
+
+func (s *stepWinRMTeardown) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	if !config.WinRMBootstrap {
+		return multistep.ActionContinue
+	}
+	comm := state.Get("communicator").(packer.Communicator)
+
+	ui.Say("Registering the removal of the WinRM HTTPS listener at shutdown...")
+	if err := comm.Upload(windowsWinRMTeardownScript, strings.NewReader(winRMTeardownScript), nil); err != nil {
+		err := fmt.Errorf("[ERROR] Error uploading the WinRM teardown script: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	cmd := &packer.RemoteCmd{Command: "powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File " + windowsWinRMTeardownScript}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		err := fmt.Errorf("[ERROR] Error registering the WinRM teardown: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if status := cmd.ExitStatus(); status != 0 {
+		err := fmt.Errorf("[ERROR] The WinRM teardown script exited with status %d", status)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	ui.Say("The WinRM HTTPS listener will be removed when the instance shuts down.")
+	return multistep.ActionContinue
+}
+
+func (s *stepWinRMTeardown) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,68 @@
+package vpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestWinRMBootstrapUserData(t *testing.T) {
+	config := &Config{WinRMBootstrap: true}
+	userData, err := buildUserData(config, userDataTemplateData{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if userData == nil || !strings.Contains(*userData, "-Transport HTTPS") || !strings.Contains(*userData, `filename="part-001.ps1"`) {
+		t.Fatalf("the user data does not enable the HTTPS listener: %v", userData)
+	}
+
+	config.VSIUserDataParts = []UserDataPart{{ContentType: "shell-script", Content: "#!/bin/sh\necho user\n"}}
+	userData, err = buildUserData(config, userDataTemplateData{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	bootstrap, user := strings.Index(*userData, "New-SelfSignedCertificate"), strings.Index(*userData, "echo user")
+	if bootstrap < 0 || user < 0 || bootstrap > user {
+		t.Errorf("the bootstrap should come before the user's parts:\n%s", *userData)
+	}
+	if !strings.Contains(*userData, `filename="part-002.sh"`) {
+		t.Errorf("the user's part should be the second of the document:\n%s", *userData)
+	}
+
+	// Errors name the vsi_user_data_part blocks as configured.
+	config.VSIUserDataParts = []UserDataPart{{ContentType: "shell-script", File: "does-not-exist.sh"}}
+	if _, err := buildUserData(config, userDataTemplateData{}); err == nil || !strings.Contains(err.Error(), "vsi_user_data_part 1:") {
+		t.Errorf("expected an error for vsi_user_data_part 1, got %v", err)
+	}
+}
+
+func TestStepWinRMTeardown(t *testing.T) {
+	for _, bootstrap := range []bool{false, true} {
+		comm := new(packer.MockCommunicator)
+		state := new(multistep.BasicStateBag)
+		state.Put("ui", packer.TestUi(t))
+		state.Put("config", Config{WinRMBootstrap: bootstrap})
+		state.Put("communicator", comm)
+
+		if action := new(stepWinRMTeardown).Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("winrm_bootstrap %t: expected ActionContinue, got %v: %v", bootstrap, action, state.Get("error"))
+		}
+		if comm.StartCalled != bootstrap {
+			t.Errorf("winrm_bootstrap %t: teardown registered: %t", bootstrap, comm.StartCalled)
+		}
+		if !bootstrap {
+			continue
+		}
+		if comm.UploadPath != windowsWinRMTeardownScript || !strings.HasSuffix(comm.StartCmd.Command, "-File "+windowsWinRMTeardownScript) {
+			t.Errorf("uploaded %s and ran %q", comm.UploadPath, comm.StartCmd.Command)
+		}
+		for _, want := range []string{`Scripts\Shutdown\0`, "0CmdLine=packer-winrm-teardown.ps1", "Remove-NetFirewallRule -Name packer-winrm-https"} {
+			if !strings.Contains(comm.UploadData, want) {
+				t.Errorf("the teardown script does not contain %q", want)
+			}
+		}
+	}
+}
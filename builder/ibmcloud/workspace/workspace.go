@@ -0,0 +1,59 @@
+// Package workspace gives each build a private directory for the key material
+// and scratch files it generates, instead of the working directory Packer was
+// started in, where parallel builds collided and a killed build left private
+// keys behind.
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Workspace is the directory of one build.
+type Workspace struct {
+	Dir  string
+	keep bool
+}
+
+// New creates the workspace of a build. Unless keepDir is set, it is a new
+// directory in the system temp directory, readable only by the user, that
+// Remove deletes. When keepDir is set, it is the <buildID> directory inside
+// keepDir, created likewise, that Remove leaves in place so the files can be
+// inspected after the build.
+func New(keepDir, buildID string) (*Workspace, error) {
+	if keepDir == "" {
+		dir, err := os.MkdirTemp("", "packer-ibmcloud-")
+		if err != nil {
+			return nil, fmt.Errorf("creating the build's temporary directory: %s", err)
+		}
+		return &Workspace{Dir: dir}, nil
+	}
+
+	if err := os.MkdirAll(keepDir, 0700); err != nil {
+		return nil, fmt.Errorf("creating %s: %s", keepDir, err)
+	}
+	dir := filepath.Join(keepDir, buildID)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating the build's directory: %s", err)
+	}
+	return &Workspace{Dir: dir, keep: true}, nil
+}
+
+// Path returns the path of the file name in the workspace.
+func (w *Workspace) Path(name string) string {
+	return filepath.Join(w.Dir, name)
+}
+
+// Kept reports whether the workspace outlives the build.
+func (w *Workspace) Kept() bool {
+	return w.keep
+}
+
+// Remove deletes the workspace and everything in it, unless it is kept.
+func (w *Workspace) Remove() error {
+	if w.keep {
+		return nil
+	}
+	return os.RemoveAll(w.Dir)
+}
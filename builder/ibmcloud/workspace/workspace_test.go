@@ -0,0 +1,72 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemporaryWorkspace(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	first, err := New("", "build-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	second, err := New("", "build-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if first.Dir == second.Dir {
+		t.Errorf("parallel builds share the workspace %s", first.Dir)
+	}
+	info, err := os.Stat(first.Dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("workspace permissions = %v, want 0700", info.Mode().Perm())
+	}
+	if first.Kept() {
+		t.Error("a temporary workspace should not be kept")
+	}
+
+	if err := os.WriteFile(first.Path("id_rsa"), []byte("key"), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := first.Remove(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(first.Dir); !os.IsNotExist(err) {
+		t.Errorf("the workspace should be removed, got: %v", err)
+	}
+}
+
+func TestKeptWorkspace(t *testing.T) {
+	keepDir := filepath.Join(t.TempDir(), "keys")
+
+	w, err := New(keepDir, "build-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if w.Dir != filepath.Join(keepDir, "build-1") || !w.Kept() {
+		t.Errorf("got %s (kept %t), want %s kept", w.Dir, w.Kept(), filepath.Join(keepDir, "build-1"))
+	}
+	info, err := os.Stat(w.Dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("workspace permissions = %v, want 0700", info.Mode().Perm())
+	}
+	if err := w.Remove(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(w.Dir); err != nil {
+		t.Errorf("a kept workspace should survive Remove: %s", err)
+	}
+
+	if _, err := New(keepDir, "build-1"); err == nil {
+		t.Error("expected an error reusing the directory of another build")
+	}
+}
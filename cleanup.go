@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"packer-plugin-ibmcloud/builder/ibmcloud/classic"
+	"packer-plugin-ibmcloud/builder/ibmcloud/ledger"
+	"packer-plugin-ibmcloud/builder/ibmcloud/vpc"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const cleanupUsage = `Usage: packer-plugin-ibmcloud cleanup -region <region> [options]
+       packer-plugin-ibmcloud cleanup -ledger <file or directory> [options]
+
+  Finds the temporary resources that builds leave behind when Packer is killed
+  before it can clean up, prints them, and deletes them on confirmation.
+
+  With -region, the VPC floating IPs, instances, virtual network interfaces,
+  SSH keys and security groups named like a build's temporary resources are
+  swept. With -ledger, exactly the resources recorded in the resource ledgers
+  of crashed builds are deleted.
+
+Options:
+`
+
+// runCleanup implements the cleanup subcommand. It is run instead of the
+// plugin server when the binary is invoked as "packer-plugin-ibmcloud cleanup".
+func runCleanup(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, cleanupUsage)
+		flags.PrintDefaults()
+	}
+	apiKey := flags.String("api-key", "", "IBM Cloud API key (defaults to $IBM_API_KEY)")
+	region := flags.String("region", "", "region to sweep")
+	endpoint := flags.String("vpc-endpoint-url", "", "VPC API endpoint (defaults to the region's public endpoint)")
+	iamURL := flags.String("iam-url", "", "IAM token endpoint")
+	ghostURL := flags.String("ghost-endpoint-url", "", "Global Tagging API endpoint")
+	resourceGroupID := flags.String("resource-group-id", "", "only sweep this resource group")
+	prefix := flags.String("prefix", "packer-vpc", "temporary_resource_name_prefix of the builds")
+	buildID := flags.String("build-id", "", "only sweep the resources tagged with this build ID")
+	olderThan := flags.Duration("older-than", 24*time.Hour, "only sweep resources older than this, so running builds are left alone")
+	ledgerPath := flags.String("ledger", "", "a resource ledger, or a directory of them such as "+ledger.DefaultDir()+" (-older-than applies to a directory)")
+	classicUsername := flags.String("classic-username", "", "classic infrastructure user name for classic ledgers (defaults to $SOFTLAYER_USER_NAME)")
+	classicAPIKey := flags.String("classic-api-key", "", "classic infrastructure API key for classic ledgers (defaults to $SOFTLAYER_API_KEY)")
+	timeout := flags.Duration("timeout", 15*time.Minute, "how long to wait for each instance to be deleted")
+	yes := flags.Bool("yes", false, "delete without asking for confirmation")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// The environment is read after parsing so -h never prints the keys.
+	if *apiKey == "" {
+		*apiKey = os.Getenv("IBM_API_KEY")
+	}
+	if *classicUsername == "" {
+		*classicUsername = os.Getenv("SOFTLAYER_USER_NAME")
+	}
+	if *classicAPIKey == "" {
+		*classicAPIKey = os.Getenv("SOFTLAYER_API_KEY")
+	}
+
+	ui := &packer.BasicUi{Reader: stdin, Writer: stdout, ErrorWriter: stderr}
+	confirm := func(count int) bool {
+		if *yes {
+			return true
+		}
+		answer, err := ui.Ask(fmt.Sprintf("Delete these %d resources? Only 'yes' will be accepted:", count))
+		if err != nil || answer != "yes" {
+			ui.Say("Nothing was deleted.")
+			return false
+		}
+		return true
+	}
+
+	if *ledgerPath != "" {
+		return cleanupLedgers(ui, stdout, *ledgerPath, *olderThan, *apiKey, *classicUsername, *classicAPIKey, *timeout, confirm)
+	}
+
+	if *apiKey == "" {
+		return errors.New("an api key must be given with -api-key or $IBM_API_KEY")
+	}
+	if *region == "" {
+		return errors.New("-region or -ledger must be given")
+	}
+	if *endpoint == "" {
+		*endpoint = "https://" + *region + ".iaas.cloud.ibm.com/v1/"
+	}
+
+	svc, err := vpc.NewVPCService(*apiKey, *iamURL, *endpoint)
+	if err != nil {
+		return fmt.Errorf("creating VPC service: %s", err)
+	}
+	buildIDOf, err := vpc.NewBuildIDLookup(*apiKey, *iamURL, *ghostURL)
+	if err != nil {
+		return fmt.Errorf("creating global tagging client: %s", err)
+	}
+
+	ui.Say(fmt.Sprintf("Looking for %s-* resources older than %s in %s...", *prefix, *olderThan, *region))
+	resources, err := vpc.FindLeakedResources(svc, vpc.SweepOptions{
+		NamePrefix:      *prefix,
+		ResourceGroupID: *resourceGroupID,
+		OlderThan:       *olderThan,
+		BuildID:         *buildID,
+	}, buildIDOf, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		ui.Say("No leaked resources found.")
+		return nil
+	}
+
+	ui.Say("The following resources will be deleted, in this order:")
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tID\tCREATED\tBUILD ID")
+	for _, r := range resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Kind, r.Name, r.ID, r.CreatedAt.Format(time.RFC3339), r.BuildID)
+	}
+	w.Flush()
+
+	if !confirm(len(resources)) {
+		return nil
+	}
+	return vpc.DeleteLeakedResources(svc, ui, resources, *timeout, nil)
+}
+
+// cleanupLedgers deletes the resources still listed in the ledger at path, or
+// in every ledger of the directory at path older than olderThan; a directory
+// also holds the ledgers of builds that are still running. A ledger whose
+// resources are all deleted is removed.
+func cleanupLedgers(ui packer.Ui, stdout io.Writer, path string, olderThan time.Duration, apiKey, classicUsername, classicAPIKey string, timeout time.Duration, confirm func(int) bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	var ledgers []*ledger.Ledger
+	if info.IsDir() {
+		all, err := ledger.List(path)
+		if err != nil {
+			return err
+		}
+		cutoff := time.Now().Add(-olderThan)
+		for _, l := range all {
+			if l.CreatedAt.After(cutoff) {
+				ui.Say(fmt.Sprintf("Skipping %s: the build started less than %s ago and may still be running.", l.Path(), olderThan))
+				continue
+			}
+			ledgers = append(ledgers, l)
+		}
+	} else {
+		l, err := ledger.Open(path)
+		if err != nil {
+			return err
+		}
+		ledgers = []*ledger.Ledger{l}
+	}
+
+	count := 0
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BUILDER\tREGION\tTYPE\tNAME\tID\tCREATED\tBUILD ID")
+	for _, l := range ledgers {
+		pending := l.Pending()
+		if len(pending) == 0 {
+			continue
+		}
+		switch l.Builder {
+		case "vpc":
+			if apiKey == "" {
+				return fmt.Errorf("%s is a VPC ledger: an api key must be given with -api-key or $IBM_API_KEY", l.Path())
+			}
+		case "classic":
+			if classicUsername == "" || classicAPIKey == "" {
+				return fmt.Errorf("%s is a classic ledger: -classic-username and -classic-api-key (or $SOFTLAYER_USER_NAME and $SOFTLAYER_API_KEY) must be given", l.Path())
+			}
+		default:
+			return fmt.Errorf("%s was written by an unknown builder %q", l.Path(), l.Builder)
+		}
+		for _, r := range pending {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", l.Builder, l.Region, r.Type, r.Name, r.ID, r.CreatedAt.Format(time.RFC3339), l.BuildID)
+			count++
+		}
+	}
+	if count == 0 {
+		ui.Say("The ledgers list no resources left to delete.")
+	} else {
+		ui.Say("The following resources will be deleted:")
+		w.Flush()
+		if !confirm(count) {
+			return nil
+		}
+	}
+
+	var errs []error
+	for _, l := range ledgers {
+		if len(l.Pending()) > 0 {
+			var err error
+			if l.Builder == "vpc" {
+				err = vpc.CleanupLedger(l, apiKey, ui, timeout)
+			} else {
+				err = classic.CleanupLedger(l, classicUsername, classicAPIKey, ui, timeout)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if _, err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
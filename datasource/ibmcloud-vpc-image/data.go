@@ -0,0 +1,277 @@
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput
+
+package ibmcloudimage
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+	"packer-plugin-ibmcloud/builder/ibmcloud/vpc"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	globaltaggingv1 "github.com/IBM/platform-services-go-sdk/globaltaggingv1"
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type Config struct {
+	IBMApiKey     string `mapstructure:"api_key"`
+	Region        string `mapstructure:"region"`
+	Endpoint      string `mapstructure:"vpc_endpoint_url"`
+	IAMEndpoint   string `mapstructure:"iam_url"`
+	GhostEndpoint string `mapstructure:"ghost_endpoint_url"`
+
+	// Filters. An image must match every filter that is set.
+	NameRegex       string   `mapstructure:"name_regex"`
+	Visibility      string   `mapstructure:"visibility"`
+	Status          []string `mapstructure:"status"`
+	OSFamily        string   `mapstructure:"os_family"`
+	Architecture    string   `mapstructure:"architecture"`
+	ResourceGroupID string   `mapstructure:"resource_group_id"`
+	UserTags        []string `mapstructure:"user_tags"`
+
+	// MostRecent picks the newest matching image instead of failing when more
+	// than one image matches.
+	MostRecent bool `mapstructure:"most_recent"`
+
+	nameRegex *regexp.Regexp
+}
+
+type Datasource struct {
+	config Config
+}
+
+type DatasourceOutput struct {
+	ID           string `mapstructure:"id"`
+	Name         string `mapstructure:"name"`
+	CRN          string `mapstructure:"crn"`
+	OS           string `mapstructure:"os"`
+	Architecture string `mapstructure:"architecture"`
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+	redact.Secrets(d.config.IBMApiKey)
+	errs := new(packersdk.MultiError)
+
+	if d.config.IBMApiKey == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("api_key must be specified"))
+	}
+	if d.config.Region == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("region must be specified"))
+	}
+	if d.config.Endpoint == "" {
+		d.config.Endpoint = "https://" + d.config.Region + ".iaas.cloud.ibm.com/v1/"
+	}
+
+	if d.config.NameRegex != "" {
+		re, err := regexp.Compile(d.config.NameRegex)
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("name_regex is not a valid regular expression: %s", err))
+		}
+		d.config.nameRegex = re
+	}
+	if d.config.Visibility != "" && d.config.Visibility != vpcv1.ListImagesOptionsVisibilityPublicConst && d.config.Visibility != vpcv1.ListImagesOptionsVisibilityPrivateConst {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("visibility must be one of: public, private"))
+	}
+	// Only usable images are returned unless other statuses are asked for.
+	if len(d.config.Status) == 0 {
+		d.config.Status = []string{vpcv1.ListImagesOptionsStatusAvailableConst}
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	emptyOutput := hcl2helper.HCL2ValueFromConfig(DatasourceOutput{}, d.OutputSpec())
+
+	vpcService, err := vpc.NewVPCService(d.config.IBMApiKey, d.config.IAMEndpoint, d.config.Endpoint)
+	if err != nil {
+		return emptyOutput, fmt.Errorf("[ERROR] Error creating VPC service %s", err)
+	}
+
+	images, err := listImages(vpcService, d.config)
+	if err != nil {
+		return emptyOutput, fmt.Errorf("[ERROR] Error listing images: %s", err)
+	}
+
+	var userTags func(crn string) ([]string, error)
+	if len(d.config.UserTags) > 0 {
+		userTags, err = newUserTagLister(d.config)
+		if err != nil {
+			return emptyOutput, fmt.Errorf("[ERROR] Error creating global tagging client: %s", err)
+		}
+	}
+
+	images, err = filterImages(images, d.config, userTags)
+	if err != nil {
+		return emptyOutput, err
+	}
+	image, err := selectImage(images, d.config.MostRecent)
+	if err != nil {
+		return emptyOutput, err
+	}
+
+	output := DatasourceOutput{
+		ID:   *image.ID,
+		Name: *image.Name,
+		CRN:  *image.CRN,
+	}
+	if image.OperatingSystem != nil {
+		output.OS = stringValue(image.OperatingSystem.Name)
+		output.Architecture = stringValue(image.OperatingSystem.Architecture)
+	}
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+// listImages pages through the images in the region, applying the filters the
+// VPC API supports server-side (visibility, status and resource group).
+func listImages(vpcService *vpcv1.VpcV1, c Config) ([]vpcv1.Image, error) {
+	options := &vpcv1.ListImagesOptions{
+		Status: c.Status,
+	}
+	if c.Visibility != "" {
+		options.Visibility = &c.Visibility
+	}
+	if c.ResourceGroupID != "" {
+		options.ResourceGroupID = &c.ResourceGroupID
+	}
+	pager, err := vpcService.NewImagesPager(options)
+	if err != nil {
+		return nil, err
+	}
+	return pager.GetAll()
+}
+
+// filterImages applies the filters the VPC API cannot: name_regex, os_family,
+// architecture and user_tags. userTags returns the user tags attached to a CRN
+// and is only called, once per remaining candidate, when user_tags is set.
+func filterImages(images []vpcv1.Image, c Config, userTags func(crn string) ([]string, error)) ([]vpcv1.Image, error) {
+	matched := []vpcv1.Image{}
+	for _, image := range images {
+		if c.nameRegex != nil && !c.nameRegex.MatchString(stringValue(image.Name)) {
+			continue
+		}
+		var family, architecture string
+		if image.OperatingSystem != nil {
+			family = stringValue(image.OperatingSystem.Family)
+			architecture = stringValue(image.OperatingSystem.Architecture)
+		}
+		if c.OSFamily != "" && !strings.EqualFold(c.OSFamily, family) {
+			continue
+		}
+		if c.Architecture != "" && !strings.EqualFold(c.Architecture, architecture) {
+			continue
+		}
+		if len(c.UserTags) > 0 {
+			tags, err := userTags(stringValue(image.CRN))
+			if err != nil {
+				return nil, fmt.Errorf("[ERROR] Error fetching the user tags of image %s: %s", stringValue(image.ID), err)
+			}
+			if !hasAllTags(tags, c.UserTags) {
+				continue
+			}
+		}
+		matched = append(matched, image)
+	}
+	return matched, nil
+}
+
+// selectImage returns the single matching image. With mostRecent, the newest
+// image wins; otherwise more than one match is an error so a loose filter does
+// not silently pick an arbitrary image.
+func selectImage(images []vpcv1.Image, mostRecent bool) (vpcv1.Image, error) {
+	switch {
+	case len(images) == 0:
+		return vpcv1.Image{}, fmt.Errorf("[ERROR] No image matched the filters")
+	case len(images) > 1 && !mostRecent:
+		return vpcv1.Image{}, fmt.Errorf("[ERROR] %d images matched the filters; refine them or set most_recent = true", len(images))
+	}
+	newest := images[0]
+	for _, image := range images[1:] {
+		if createdAt(image).After(createdAt(newest)) {
+			newest = image
+		}
+	}
+	return newest, nil
+}
+
+func createdAt(image vpcv1.Image) time.Time {
+	if image.CreatedAt == nil {
+		return time.Time{}
+	}
+	return time.Time(*image.CreatedAt)
+}
+
+// hasAllTags reports whether every wanted tag is attached. Tags are compared
+// case-insensitively, as IBM Cloud stores them lower-cased.
+func hasAllTags(attached, wanted []string) bool {
+	for _, tag := range wanted {
+		if !slices.ContainsFunc(attached, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// newUserTagLister returns a function listing the user tags attached to a
+// resource CRN through the Global Tagging API.
+func newUserTagLister(c Config) (func(crn string) ([]string, error), error) {
+	options := globaltaggingv1.GlobalTaggingV1Options{
+		Authenticator: &core.IamAuthenticator{
+			ApiKey: c.IBMApiKey,
+			URL:    c.IAMEndpoint,
+		},
+	}
+	if c.GhostEndpoint != "" {
+		options.URL = c.GhostEndpoint
+	}
+	tagging, err := globaltaggingv1.NewGlobalTaggingV1(&options)
+	if err != nil {
+		return nil, err
+	}
+	return func(crn string) ([]string, error) {
+		listOptions := tagging.NewListTagsOptions()
+		listOptions.SetAttachedTo(crn)
+		listOptions.SetTagType(globaltaggingv1.ListTagsOptionsTagTypeUserConst)
+		listOptions.SetLimit(1000)
+		tagList, _, err := tagging.ListTags(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		tags := []string{}
+		for _, tag := range tagList.Items {
+			tags = append(tags, stringValue(tag.Name))
+		}
+		return tags, nil
+	}, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
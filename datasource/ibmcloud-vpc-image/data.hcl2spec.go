@@ -0,0 +1,86 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package ibmcloudimage
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	IBMApiKey       *string  `mapstructure:"api_key" cty:"api_key" hcl:"api_key"`
+	Region          *string  `mapstructure:"region" cty:"region" hcl:"region"`
+	Endpoint        *string  `mapstructure:"vpc_endpoint_url" cty:"vpc_endpoint_url" hcl:"vpc_endpoint_url"`
+	IAMEndpoint     *string  `mapstructure:"iam_url" cty:"iam_url" hcl:"iam_url"`
+	GhostEndpoint   *string  `mapstructure:"ghost_endpoint_url" cty:"ghost_endpoint_url" hcl:"ghost_endpoint_url"`
+	NameRegex       *string  `mapstructure:"name_regex" cty:"name_regex" hcl:"name_regex"`
+	Visibility      *string  `mapstructure:"visibility" cty:"visibility" hcl:"visibility"`
+	Status          []string `mapstructure:"status" cty:"status" hcl:"status"`
+	OSFamily        *string  `mapstructure:"os_family" cty:"os_family" hcl:"os_family"`
+	Architecture    *string  `mapstructure:"architecture" cty:"architecture" hcl:"architecture"`
+	ResourceGroupID *string  `mapstructure:"resource_group_id" cty:"resource_group_id" hcl:"resource_group_id"`
+	UserTags        []string `mapstructure:"user_tags" cty:"user_tags" hcl:"user_tags"`
+	MostRecent      *bool    `mapstructure:"most_recent" cty:"most_recent" hcl:"most_recent"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"api_key":            &hcldec.AttrSpec{Name: "api_key", Type: cty.String, Required: false},
+		"region":             &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
+		"vpc_endpoint_url":   &hcldec.AttrSpec{Name: "vpc_endpoint_url", Type: cty.String, Required: false},
+		"iam_url":            &hcldec.AttrSpec{Name: "iam_url", Type: cty.String, Required: false},
+		"ghost_endpoint_url": &hcldec.AttrSpec{Name: "ghost_endpoint_url", Type: cty.String, Required: false},
+		"name_regex":         &hcldec.AttrSpec{Name: "name_regex", Type: cty.String, Required: false},
+		"visibility":         &hcldec.AttrSpec{Name: "visibility", Type: cty.String, Required: false},
+		"status":             &hcldec.AttrSpec{Name: "status", Type: cty.List(cty.String), Required: false},
+		"os_family":          &hcldec.AttrSpec{Name: "os_family", Type: cty.String, Required: false},
+		"architecture":       &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+		"resource_group_id":  &hcldec.AttrSpec{Name: "resource_group_id", Type: cty.String, Required: false},
+		"user_tags":          &hcldec.AttrSpec{Name: "user_tags", Type: cty.List(cty.String), Required: false},
+		"most_recent":        &hcldec.AttrSpec{Name: "most_recent", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	ID           *string `mapstructure:"id" cty:"id" hcl:"id"`
+	Name         *string `mapstructure:"name" cty:"name" hcl:"name"`
+	CRN          *string `mapstructure:"crn" cty:"crn" hcl:"crn"`
+	OS           *string `mapstructure:"os" cty:"os" hcl:"os"`
+	Architecture *string `mapstructure:"architecture" cty:"architecture" hcl:"architecture"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"id":           &hcldec.AttrSpec{Name: "id", Type: cty.String, Required: false},
+		"name":         &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"crn":          &hcldec.AttrSpec{Name: "crn", Type: cty.String, Required: false},
+		"os":           &hcldec.AttrSpec{Name: "os", Type: cty.String, Required: false},
+		"architecture": &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package ibmcloudimage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/go-openapi/strfmt"
+)
+
+func testImage(id, name, family, arch string, created time.Time) vpcv1.Image {
+	crn := "crn:v1:bluemix:public:is:us-south:a/acct::image:" + id
+	createdAt := strfmt.DateTime(created)
+	return vpcv1.Image{
+		ID:        &id,
+		Name:      &name,
+		CRN:       &crn,
+		CreatedAt: &createdAt,
+		OperatingSystem: &vpcv1.OperatingSystem{
+			Family:       &family,
+			Architecture: &arch,
+		},
+	}
+}
+
+func testImages() []vpcv1.Image {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []vpcv1.Image{
+		testImage("img-1", "golden-rhel-9-001", "Red Hat Enterprise Linux", "amd64", base),
+		testImage("img-2", "golden-rhel-9-002", "Red Hat Enterprise Linux", "amd64", base.Add(48*time.Hour)),
+		testImage("img-3", "golden-rhel-9-003", "Red Hat Enterprise Linux", "s390x", base.Add(72*time.Hour)),
+		testImage("img-4", "golden-ubuntu-001", "Ubuntu Linux", "amd64", base.Add(24*time.Hour)),
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{name: "minimal", raw: map[string]interface{}{"api_key": "key", "region": "us-south"}},
+		{name: "missing api_key", raw: map[string]interface{}{"region": "us-south"}, wantErr: "api_key"},
+		{name: "missing region", raw: map[string]interface{}{"api_key": "key"}, wantErr: "region"},
+		{name: "invalid name_regex", raw: map[string]interface{}{"api_key": "key", "region": "us-south", "name_regex": "golden-("}, wantErr: "name_regex"},
+		{name: "invalid visibility", raw: map[string]interface{}{"api_key": "key", "region": "us-south", "visibility": "shared"}, wantErr: "visibility"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Datasource{}
+			err := d.Configure(tc.raw)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected an error mentioning %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigureDefaults(t *testing.T) {
+	d := &Datasource{}
+	if err := d.Configure(map[string]interface{}{"api_key": "key", "region": "eu-de"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d.config.Endpoint != "https://eu-de.iaas.cloud.ibm.com/v1/" {
+		t.Errorf("unexpected default endpoint: %s", d.config.Endpoint)
+	}
+	if len(d.config.Status) != 1 || d.config.Status[0] != "available" {
+		t.Errorf("expected status to default to [available], got %v", d.config.Status)
+	}
+}
+
+func TestFilterAndSelectImage(t *testing.T) {
+	tags := map[string][]string{
+		"img-1": {"team:platform", "golden"},
+		"img-2": {"team:platform"},
+	}
+	userTags := func(crn string) ([]string, error) {
+		return tags[crn[strings.LastIndex(crn, ":")+1:]], nil
+	}
+
+	cases := []struct {
+		name       string
+		raw        map[string]interface{}
+		mostRecent bool
+		wantID     string
+		wantErr    string
+	}{
+		{name: "name regex, most recent", raw: map[string]interface{}{"name_regex": "^golden-rhel-9-"}, mostRecent: true, wantID: "img-3"},
+		{name: "architecture narrows the match", raw: map[string]interface{}{"name_regex": "^golden-rhel-9-", "architecture": "amd64"}, mostRecent: true, wantID: "img-2"},
+		{name: "os family is case-insensitive", raw: map[string]interface{}{"os_family": "ubuntu linux"}, wantID: "img-4"},
+		{name: "user tags must all be attached", raw: map[string]interface{}{"user_tags": []string{"Team:Platform", "golden"}}, wantID: "img-1"},
+		{name: "several matches without most_recent", raw: map[string]interface{}{"name_regex": "^golden-rhel-9-"}, wantErr: "3 images matched"},
+		{name: "no match", raw: map[string]interface{}{"name_regex": "^windows-"}, wantErr: "No image matched"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{"api_key": "key", "region": "us-south"}
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+			d := &Datasource{}
+			if err := d.Configure(raw); err != nil {
+				t.Fatalf("unexpected Configure error: %s", err)
+			}
+
+			images, err := filterImages(testImages(), d.config, userTags)
+			if err != nil {
+				t.Fatalf("unexpected filter error: %s", err)
+			}
+			image, err := selectImage(images, tc.mostRecent)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected an error mentioning %q, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected select error: %s", err)
+			}
+			if *image.ID != tc.wantID {
+				t.Errorf("expected image %s, got %s", tc.wantID, *image.ID)
+			}
+		})
+	}
+}
+
+func TestFilterImagesTagLookupError(t *testing.T) {
+	d := &Datasource{}
+	if err := d.Configure(map[string]interface{}{"api_key": "key", "region": "us-south", "user_tags": []string{"golden"}}); err != nil {
+		t.Fatalf("unexpected Configure error: %s", err)
+	}
+	_, err := filterImages(testImages(), d.config, func(string) ([]string, error) {
+		return nil, errors.New("forbidden")
+	})
+	if err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expected the tag lookup error to be returned, got: %v", err)
+	}
+}
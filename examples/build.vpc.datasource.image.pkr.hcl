@@ -0,0 +1,61 @@
+packer {
+  required_plugins {
+    ibmcloud = {
+      version = ">=v3.0.0"
+      source  = "github.com/IBM/ibmcloud"
+    }
+  }
+}
+
+variable "ibm_api_key" {
+  type    = string
+  default = "${env("IBM_API_KEY")}"
+}
+
+locals {
+  timestamp = regex_replace(timestamp(), "[- TZ:]", "")
+}
+
+data "ibmcloud-vpc-image" "golden" {
+  api_key      = "${var.ibm_api_key}"
+  region       = "us-south"
+  name_regex   = "^ibm-redhat-9-.*-minimal-amd64-"
+  visibility   = "public"
+  architecture = "amd64"
+  most_recent  = true
+}
+
+source "ibmcloud-vpc" "rhel" {
+  api_key = "${var.ibm_api_key}"
+  region  = "us-south"
+
+  subnet_id         = "0717-4ad0af5f-8084-469d-a10e-49c444caa312"
+  resource_group_id = "1984ce401571473492918ea987dd1e6f"
+  security_group_id = ""
+
+  vsi_base_image_id = data.ibmcloud-vpc-image.golden.id
+  vsi_profile       = "bx2-4x16"
+  vsi_interface     = "public"
+
+  image_name = "packer-${local.timestamp}"
+
+  communicator = "ssh"
+  ssh_username = "root"
+  ssh_port     = 22
+  ssh_timeout  = "15m"
+
+  timeout = "30m"
+}
+
+build {
+  sources = [
+    "source.ibmcloud-vpc.rhel"
+  ]
+
+  provisioner "shell" {
+    execute_command = "{{.Vars}} bash '{{.Path}}'"
+    inline = [
+      "echo 'Built from ${data.ibmcloud-vpc-image.golden.name}'"
+    ]
+  }
+}
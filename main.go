@@ -1,10 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
 	"packer-plugin-ibmcloud/version"
 
 	"github.com/hashicorp/packer-plugin-sdk/plugin"
@@ -12,14 +14,32 @@ import (
 	"packer-plugin-ibmcloud/builder/ibmcloud/classic"
 	"packer-plugin-ibmcloud/builder/ibmcloud/vpc"
 
+	ibmcloudimage "packer-plugin-ibmcloud/datasource/ibmcloud-vpc-image"
+
+	ibmcloudcopy "packer-plugin-ibmcloud/post-processor/ibmcloud-copy-image"
 	ibmcloudexport "packer-plugin-ibmcloud/post-processor/ibmcloud-export-image"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cleanup" {
+		err := runCleanup(os.Args[2:], os.Stdin, os.Stdout, os.Stderr)
+		if err != nil && err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Credentials registered by the builders and post-processors are scrubbed
+	// from the plugin's log output.
+	redact.Logs(os.Stderr)
+
 	pps := plugin.NewSet()
 	pps.RegisterBuilder("vpc", new(vpc.Builder))
 	pps.RegisterBuilder("classic", new(classic.Builder))
 	pps.RegisterPostProcessor("export-image", new(ibmcloudexport.PostProcessor))
+	pps.RegisterPostProcessor("copy-image", new(ibmcloudcopy.PostProcessor))
+	pps.RegisterDatasource("vpc-image", new(ibmcloudimage.Datasource))
 	pps.SetVersion(version.IBMCloudPluginVersion)
 	err := pps.Run()
 	log.Println("IBM Cloud Packer Plugin Version", version.IBMCloudPluginVersion)
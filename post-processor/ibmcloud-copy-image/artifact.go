@@ -0,0 +1,116 @@
+package ibmcloudcopy
+
+import (
+	"fmt"
+	"log"
+	"packer-plugin-ibmcloud/builder/ibmcloud/vpc"
+	"sort"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+)
+
+const BuilderId = "ibmcloud.post-processor.vpc-copy"
+
+type Artifact struct {
+	sourceRegion  string
+	sourceImageId string
+	imageName     string
+	// regionImages maps each target region to the ID of the copy made there.
+	regionImages map[string]string
+
+	// Connection details for Destroy, which deletes the copies (not the source).
+	apiKey      string
+	iamEndpoint string
+	endpoints   map[string]string
+	timeout     time.Duration
+
+	// StateData should store data such as GeneratedData
+	StateData map[string]interface{}
+}
+
+var _ packersdk.Artifact = new(Artifact)
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+// Id returns every image as comma-separated region:id pairs, source region first.
+func (a *Artifact) Id() string {
+	ids := []string{fmt.Sprintf("%s:%s", a.sourceRegion, a.sourceImageId)}
+	for _, region := range a.regions() {
+		ids = append(ids, fmt.Sprintf("%s:%s", region, a.regionImages[region]))
+	}
+	return strings.Join(ids, ",")
+}
+
+func (a *Artifact) Files() []string {
+	return nil
+}
+
+func (a *Artifact) String() string {
+	copies := []string{}
+	for _, region := range a.regions() {
+		copies = append(copies, fmt.Sprintf("%s: %s", region, a.regionImages[region]))
+	}
+	return fmt.Sprintf("Image Name: %s || Image ID: %s (%s) || Copies: %s", a.imageName, a.sourceImageId, a.sourceRegion, strings.Join(copies, ", "))
+}
+
+// State returns the StateData entry for name. "region_image_ids" is the
+// region to image ID map, source included, and registryimage.ArtifactStateURI
+// reports every image to HCP Packer.
+func (a *Artifact) State(name string) interface{} {
+	switch name {
+	case "region_image_ids":
+		return a.allImages()
+	case registryimage.ArtifactStateURI:
+		images, err := registryimage.FromMappedData(a.allImages(), func(key, value interface{}) (*registryimage.Image, error) {
+			return &registryimage.Image{
+				ImageID:        value.(string),
+				ProviderName:   "ibmcloud",
+				ProviderRegion: key.(string),
+				SourceImageID:  a.sourceImageId,
+			}, nil
+		})
+		if err != nil {
+			log.Printf("[DEBUG] error encountered when creating HCP Packer registry image metadata: %s", err)
+			return nil
+		}
+		return images
+	}
+	return a.StateData[name]
+}
+
+// Destroy deletes the copies. The source image belongs to the builder's artifact.
+func (a *Artifact) Destroy() error {
+	log.Printf("Destroying artifacts: %s", a.String())
+	errs := new(packersdk.MultiError)
+	for _, region := range a.regions() {
+		if err := vpc.DestroyImage(a.apiKey, a.iamEndpoint, a.endpoints[region], a.regionImages[region], a.timeout); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("region %s: %s", region, err))
+		}
+	}
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (a *Artifact) allImages() map[string]string {
+	images := map[string]string{a.sourceRegion: a.sourceImageId}
+	for region, id := range a.regionImages {
+		images[region] = id
+	}
+	return images
+}
+
+func (a *Artifact) regions() []string {
+	regions := []string{}
+	for region := range a.regionImages {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
@@ -0,0 +1,51 @@
+package ibmcloudcopy
+
+import (
+	"testing"
+
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+)
+
+func testArtifact() *Artifact {
+	return &Artifact{
+		sourceRegion:  "us-south",
+		sourceImageId: "r006-src",
+		imageName:     "golden",
+		regionImages:  map[string]string{"jp-tok": "r022-copy", "eu-de": "r010-copy"},
+		StateData:     map[string]interface{}{"image_name": "golden"},
+	}
+}
+
+func TestArtifactId(t *testing.T) {
+	if got, want := testArtifact().Id(), "us-south:r006-src,eu-de:r010-copy,jp-tok:r022-copy"; got != want {
+		t.Errorf("Id() = %q, want %q", got, want)
+	}
+}
+
+func TestArtifactString(t *testing.T) {
+	want := "Image Name: golden || Image ID: r006-src (us-south) || Copies: eu-de: r010-copy, jp-tok: r022-copy"
+	if got := testArtifact().String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestArtifactState(t *testing.T) {
+	a := testArtifact()
+	images := a.State("region_image_ids").(map[string]string)
+	if len(images) != 3 || images["us-south"] != "r006-src" || images["eu-de"] != "r010-copy" {
+		t.Errorf("unexpected region_image_ids %v", images)
+	}
+	if a.State("image_name") != "golden" {
+		t.Errorf("expected StateData to be returned, got %v", a.State("image_name"))
+	}
+
+	registry, ok := a.State(registryimage.ArtifactStateURI).([]*registryimage.Image)
+	if !ok || len(registry) != 3 {
+		t.Fatalf("expected an HCP Packer image per region, got %#v", a.State(registryimage.ArtifactStateURI))
+	}
+	for _, image := range registry {
+		if image.SourceImageID != "r006-src" || image.ImageID != images[image.ProviderRegion] {
+			t.Errorf("unexpected HCP Packer image %+v", image)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,TargetRegion
+
+package ibmcloudcopy
+
+import (
+	"context"
+	"fmt"
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
+	"packer-plugin-ibmcloud/builder/ibmcloud/vpc"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// TargetRegion is a region the image is copied to.
+type TargetRegion struct {
+	Region   string `mapstructure:"region" required:"true"`
+	Endpoint string `mapstructure:"vpc_endpoint_url"`
+	// The root key to encrypt the copy with. Required when the source image is
+	// encrypted, and not allowed otherwise: an unencrypted image can only be
+	// copied unencrypted.
+	EncryptionKeyCRN string `mapstructure:"encryption_key_crn"`
+	ImageName        string `mapstructure:"image_name"`
+	ResourceGroupID  string `mapstructure:"resource_group_id"`
+}
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+	IBMApiKey           string `mapstructure:"api_key"`
+	Region              string `mapstructure:"region"`
+	Endpoint            string `mapstructure:"vpc_endpoint_url"`
+	IAMEndpoint         string `mapstructure:"iam_url"`
+	ImageID             string `mapstructure:"image_id"`
+	ImageExportJobName  string `mapstructure:"image_export_job_name"`
+	ExportTimeout       string `mapstructure:"export_timeout"`
+
+	//The Cloud Object Storage bucket the image is exported to, and imported from in every target region. The bucket must exist and an IAM service authorization must grant Image Service for VPC of VPC Infrastructure Services writer access to the bucket.
+	StorageBucketName string `mapstructure:"storage_bucket_name"`
+	StorageBucketCRN  string `mapstructure:"storage_bucket_crn"`
+
+	TargetRegions  []TargetRegion `mapstructure:"target_region"`
+	RawCopyTimeout string         `mapstructure:"copy_timeout"`
+	CopyTimeout    time.Duration  `mapstructure-to-hcl2:",skip"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter:  &interpolate.RenderFilter{},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+	redact.Secrets(p.config.IBMApiKey)
+	errs := new(packersdk.MultiError)
+
+	if p.config.ImageID != "" {
+		if p.config.IBMApiKey == "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("api_key must be provided when image_id is given.."))
+		}
+		if p.config.Region == "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("region must be provided when image_id is given.."))
+		}
+		if p.config.Endpoint == "" {
+			p.config.Endpoint = "https://" + p.config.Region + ".iaas.cloud.ibm.com/v1/"
+		}
+	} else {
+		if p.config.IBMApiKey != "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("api_key must not be provided when image_id is not given.."))
+		}
+		if p.config.Region != "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("region must not be provided when image_id is not given.."))
+		}
+		if p.config.Endpoint != "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("vpc_endpoint_url must not be provided when image_id is not given.."))
+		}
+	}
+	if p.config.StorageBucketName == "" && p.config.StorageBucketCRN == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("either storage_bucket_name or storage_bucket_crn must be provided.."))
+	}
+	if p.config.StorageBucketName != "" && p.config.StorageBucketCRN != "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("storage_bucket_name and storage_bucket_crn cann't be provided together.."))
+	}
+
+	if len(p.config.TargetRegions) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("at least one target_region must be provided.."))
+	}
+	seen := map[string]bool{}
+	for i, target := range p.config.TargetRegions {
+		if target.Region == "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("every target_region must have a region.."))
+			continue
+		}
+		if seen[target.Region] {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("target_region %s is given more than once..", target.Region))
+		}
+		seen[target.Region] = true
+		if target.Endpoint == "" {
+			p.config.TargetRegions[i].Endpoint = "https://" + target.Region + ".iaas.cloud.ibm.com/v1/"
+		}
+	}
+
+	if p.config.RawCopyTimeout == "" {
+		p.config.RawCopyTimeout = "60m"
+	}
+	copyTimeout, err := time.ParseDuration(p.config.RawCopyTimeout)
+	if err != nil {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("failed parsing copy_timeout: %s", err))
+	}
+	p.config.CopyTimeout = copyTimeout
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, source packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	switch source.BuilderId() {
+	case vpc.BuilderId:
+		break
+	default:
+		err := fmt.Errorf(
+			"Unknown artifact type: %s\nCan only copy images built by the IBM Cloud VPC builder. ",
+			source.BuilderId())
+		return nil, false, false, err
+	}
+	ui = redact.UI(ui)
+	ibmApiKey := source.State("ibmApiKey").(string)
+	redact.Secrets(ibmApiKey)
+	region := source.State("region").(string)
+	vpc_endpoint_url := source.State("vpc_endpoint_url").(string)
+	iam_url := source.State("iam_url").(string)
+	imageId := source.State("image_id").(string)
+	imageName := source.State("image_name").(string)
+
+	if p.config.ImageID == "" {
+		// take info from source
+		p.config.IBMApiKey = ibmApiKey
+		p.config.Region = region
+		p.config.Endpoint = vpc_endpoint_url
+		p.config.IAMEndpoint = iam_url
+		p.config.ImageID = imageId
+	}
+	for _, target := range p.config.TargetRegions {
+		if target.Region == p.config.Region {
+			return nil, false, false, fmt.Errorf("target_region %s is the region of the source image", target.Region)
+		}
+	}
+
+	// The file is exported once and imported in every target region. qcow2 is
+	// the only format an encrypted image can be exported in.
+	exporterConfig := vpc.Config{
+		IBMApiKey:          p.config.IBMApiKey,
+		Region:             p.config.Region,
+		Endpoint:           p.config.Endpoint,
+		IAMEndpoint:        p.config.IAMEndpoint,
+		ImageID:            p.config.ImageID,
+		ImageExportJobName: p.config.ImageExportJobName,
+		ExportTimeout:      p.config.ExportTimeout,
+		StorageBucketName:  p.config.StorageBucketName,
+		StorageBucketCRN:   p.config.StorageBucketCRN,
+		Format:             "qcow2",
+	}
+	client := vpc.IBMCloudClient{}.New(p.config.IBMApiKey)
+
+	targets := []vpc.ImageCopyTarget{}
+	for _, target := range p.config.TargetRegions {
+		targets = append(targets, vpc.ImageCopyTarget{
+			Region:           target.Region,
+			Endpoint:         target.Endpoint,
+			EncryptionKeyCRN: target.EncryptionKeyCRN,
+			ImageName:        target.ImageName,
+			ResourceGroupID:  target.ResourceGroupID,
+		})
+	}
+
+	// Set up the state which is used to share state between the steps
+	state := new(multistep.BasicStateBag)
+	state.Put("config", exporterConfig)
+	state.Put("client", client)
+	state.Put("ui", ui)
+
+	// Build the steps
+	steps := []multistep.Step{
+		new(vpc.StepGreeting),
+		new(vpc.StepCreateVPCServiceInstance),
+		new(vpc.StepImageExport),
+		&vpc.StepImageCopy{
+			Targets: targets,
+			Timeout: p.config.CopyTimeout,
+		},
+	}
+	p.runner = &multistep.BasicRunner{Steps: steps}
+	p.runner.Run(ctx, state)
+
+	// If there was an error, return that
+	if err, ok := state.GetOk("error"); ok {
+		return nil, false, false, err.(error)
+	}
+
+	endpoints := map[string]string{}
+	for _, target := range targets {
+		endpoints[target.Region] = target.Endpoint
+	}
+
+	// Create an artifact and return it
+	result := &Artifact{
+		sourceRegion:  p.config.Region,
+		sourceImageId: p.config.ImageID,
+		imageName:     imageName,
+		regionImages:  state.Get("copied_image_ids").(map[string]string),
+		apiKey:        p.config.IBMApiKey,
+		iamEndpoint:   p.config.IAMEndpoint,
+		endpoints:     endpoints,
+		timeout:       p.config.CopyTimeout,
+		StateData: map[string]interface{}{
+			"ibmApiKey":        ibmApiKey,
+			"region":           region,
+			"vpc_endpoint_url": vpc_endpoint_url,
+			"iam_url":          iam_url,
+			"image_id":         imageId,
+			"image_name":       imageName,
+		},
+	}
+	// The copies are made alongside the source image, which is kept by default
+	// (keep_input_artifact = false still opts out).
+	return result, true, false, nil
+}
@@ -0,0 +1,145 @@
+package ibmcloudcopy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestPostProcessorConfigure(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "image of the build",
+			raw: map[string]interface{}{
+				"storage_bucket_name": "exports",
+				"target_region":       []map[string]interface{}{{"region": "eu-de"}},
+			},
+		},
+		{
+			name: "given image",
+			raw: map[string]interface{}{
+				"api_key":               "key",
+				"region":                "us-south",
+				"image_id":              "r006-image",
+				"storage_bucket_crn":    "crn:v1:bluemix:public:cloud-object-storage:global:a/acc:inst:bucket:exports",
+				"target_region":         []map[string]interface{}{{"region": "eu-de", "encryption_key_crn": "crn:v1:bluemix:public:kms:eu-de:a/acc:inst:key:key"}},
+				"copy_timeout":          "90m",
+				"image_export_job_name": "copy",
+			},
+		},
+		{
+			name: "given image without credentials",
+			raw: map[string]interface{}{
+				"image_id":            "r006-image",
+				"storage_bucket_name": "exports",
+				"target_region":       []map[string]interface{}{{"region": "eu-de"}},
+			},
+			wantErr: "api_key must be provided",
+		},
+		{
+			name: "api_key without image",
+			raw: map[string]interface{}{
+				"api_key":             "key",
+				"storage_bucket_name": "exports",
+				"target_region":       []map[string]interface{}{{"region": "eu-de"}},
+			},
+			wantErr: "api_key must not be provided",
+		},
+		{
+			name: "no bucket",
+			raw: map[string]interface{}{
+				"target_region": []map[string]interface{}{{"region": "eu-de"}},
+			},
+			wantErr: "either storage_bucket_name or storage_bucket_crn",
+		},
+		{
+			name: "both buckets",
+			raw: map[string]interface{}{
+				"storage_bucket_name": "exports",
+				"storage_bucket_crn":  "crn:v1:bluemix:public:cloud-object-storage:global:a/acc:inst:bucket:exports",
+				"target_region":       []map[string]interface{}{{"region": "eu-de"}},
+			},
+			wantErr: "cann't be provided together",
+		},
+		{
+			name:    "no target region",
+			raw:     map[string]interface{}{"storage_bucket_name": "exports"},
+			wantErr: "at least one target_region",
+		},
+		{
+			name: "target region without region",
+			raw: map[string]interface{}{
+				"storage_bucket_name": "exports",
+				"target_region":       []map[string]interface{}{{"image_name": "copy"}},
+			},
+			wantErr: "every target_region must have a region",
+		},
+		{
+			name: "duplicate target region",
+			raw: map[string]interface{}{
+				"storage_bucket_name": "exports",
+				"target_region":       []map[string]interface{}{{"region": "eu-de"}, {"region": "eu-de"}},
+			},
+			wantErr: "target_region eu-de is given more than once",
+		},
+		{
+			name: "bad copy_timeout",
+			raw: map[string]interface{}{
+				"storage_bucket_name": "exports",
+				"target_region":       []map[string]interface{}{{"region": "eu-de"}},
+				"copy_timeout":        "soon",
+			},
+			wantErr: "failed parsing copy_timeout",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var p PostProcessor
+			err := p.Configure(tc.raw)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPostProcessorConfigureDefaults(t *testing.T) {
+	var p PostProcessor
+	err := p.Configure(map[string]interface{}{
+		"api_key":             "key",
+		"region":              "us-south",
+		"image_id":            "r006-image",
+		"storage_bucket_name": "exports",
+		"target_region":       []map[string]interface{}{{"region": "eu-de"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.config.Endpoint != "https://us-south.iaas.cloud.ibm.com/v1/" {
+		t.Errorf("unexpected source endpoint %q", p.config.Endpoint)
+	}
+	if got := p.config.TargetRegions[0].Endpoint; got != "https://eu-de.iaas.cloud.ibm.com/v1/" {
+		t.Errorf("unexpected target endpoint %q", got)
+	}
+	if p.config.CopyTimeout != 60*time.Minute {
+		t.Errorf("expected copy_timeout to default to 60m, got %s", p.config.CopyTimeout)
+	}
+}
+
+func TestPostProcessRejectsOtherArtifacts(t *testing.T) {
+	var p PostProcessor
+	source := &packersdk.MockArtifact{BuilderIdValue: "ibmcloud.classic"}
+	if _, _, _, err := p.PostProcess(context.Background(), packersdk.TestUi(t), source); err == nil || !strings.Contains(err.Error(), "Unknown artifact type") {
+		t.Fatalf("expected an unknown artifact error, got %v", err)
+	}
+}
@@ -8,6 +8,7 @@ package ibmcloudexport
 import (
 	"context"
 	"fmt"
+	"packer-plugin-ibmcloud/builder/ibmcloud/redact"
 	"packer-plugin-ibmcloud/builder/ibmcloud/vpc"
 
 	// "strings"
@@ -57,6 +58,7 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 	if err != nil {
 		return err
 	}
+	redact.Secrets(p.config.IBMApiKey)
 	errs := new(packersdk.MultiError)
 
 	if p.config.ImageID != "" {
@@ -107,7 +109,9 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, source
 			source.BuilderId())
 		return nil, false, false, err
 	}
+	ui = redact.UI(ui)
 	ibmApiKey := source.State("ibmApiKey").(string)
+	redact.Secrets(ibmApiKey)
 	region := source.State("region").(string)
 	vpc_endpoint_url := source.State("vpc_endpoint_url").(string)
 	iam_url := source.State("iam_url").(string)
@@ -173,5 +177,8 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, source
 			"image_name":       imageName,
 		},
 	}
-	return result, false, false, nil
+	// Exporting copies the image to COS; it does not replace it. Keep the source
+	// image by default so Packer does not Destroy it once the export succeeds
+	// (keep_input_artifact = false still opts out).
+	return result, true, false, nil
 }